@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"melodia/internal/backup"
+	"melodia/internal/database"
+	"melodia/internal/models"
+	"melodia/internal/repositories"
+)
+
+// runBackup dumps the catalog into a gzip-compressed JSON archive
+func runBackup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	output := flags.String("o", "", "archive path, or - for stdout (default melodia-backup-<timestamp>.json.gz)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: melodia backup [-o file]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	path := *output
+	if path == "" {
+		path = fmt.Sprintf("melodia-backup-%s.json.gz", time.Now().UTC().Format("20060102-150405"))
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	dump, err := repositories.NewBackupRepository().Export()
+	if err != nil {
+		return err
+	}
+
+	if path == "-" {
+		if err := backup.Write(os.Stdout, dump); err != nil {
+			return err
+		}
+	} else if err := writeFileAtomic(path, func(w io.Writer) error { return backup.Write(w, dump) }); err != nil {
+		return err
+	}
+
+	log.Printf("Backup complete: %d songs, %d lyrics, %d playlists, %d playlist songs, %d song likes, %d playlist likes",
+		len(dump.Songs), len(dump.Lyrics), len(dump.Playlists), len(dump.PlaylistSongs), len(dump.SongLikes), len(dump.PlaylistLikes))
+	if path != "-" {
+		log.Printf("Archive written to %s", path)
+	}
+	log.Println("Uploaded audio files and covers live in STORAGE_PATH and must be backed up separately")
+	return nil
+}
+
+// runRestore loads a backup archive into the database
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	onConflict := flags.String("on-conflict", models.ConflictFail, "what to do with rows that already exist: skip, overwrite or fail")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: melodia restore [-on-conflict skip|overwrite|fail] file")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("restore needs exactly one archive file, or - for stdin")
+	}
+
+	switch *onConflict {
+	case models.ConflictSkip, models.ConflictOverwrite, models.ConflictFail:
+	default:
+		return fmt.Errorf("invalid -on-conflict %q: must be skip, overwrite or fail", *onConflict)
+	}
+
+	// Read the whole archive before touching the database so a corrupt file changes nothing
+	var input io.Reader = os.Stdin
+	if path := flags.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("error opening archive: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	dump, err := backup.Read(input)
+	if err != nil {
+		return err
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	// Restoring into an empty database needs the tables in place first
+	if err := database.CreateTablesIfNotExist(); err != nil {
+		return err
+	}
+
+	result, err := repositories.NewBackupRepository().Restore(dump, *onConflict)
+	if err != nil {
+		return fmt.Errorf("restore aborted, nothing was changed: %v", err)
+	}
+
+	log.Printf("Restore of backup from %s complete (on conflict: %s)", dump.CreatedAt.Format(time.RFC3339), *onConflict)
+	for _, table := range []struct {
+		name   string
+		counts models.RestoreCounts
+	}{
+		{"songs", result.Songs},
+		{"lyrics", result.Lyrics},
+		{"playlists", result.Playlists},
+		{"playlist songs", result.PlaylistSongs},
+		{"song likes", result.SongLikes},
+		{"playlist likes", result.PlaylistLikes},
+	} {
+		log.Printf("  %-15s %d inserted, %d updated, %d skipped", table.name, table.counts.Inserted, table.counts.Updated, table.counts.Skipped)
+	}
+	return nil
+}
+
+// writeFileAtomic writes a file through a temporary file in the same directory,
+// so an interrupted backup never leaves a truncated archive behind
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".melodia-backup-*")
+	if err != nil {
+		return fmt.Errorf("error creating archive: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing archive: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error writing archive: %v", err)
+	}
+	return nil
+}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"fmt"
+	"log"
+	"os"
+
 	"melodia/internal/server"
 
 	_ "melodia/docs" // Importar docs generados por swag
@@ -27,8 +31,49 @@ import (
 // @tag.name playlists
 // @tag.description Operaciones relacionadas con playlists
 
+// @tag.name plays
+// @tag.description Historial de reproducciones de cada oyente
+
+// @tag.name likes
+// @tag.description Canciones y playlists que le gustan a cada oyente
+
+// @tag.name listenbrainz
+// @tag.description API compatible con ListenBrainz para registrar reproducciones desde reproductores existentes
+
+// @tag.name charts
+// @tag.description Rankings de canciones, artistas y playlists más escuchados
+
+// @tag.name queue
+// @tag.description Cola de reproducción de cada oyente
+
+// @tag.name radio
+// @tag.description Estaciones de radio infinitas generadas a partir de una semilla
+
 func main() {
+	command := "serve"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
 
-	// Iniciar el servidor
-	server.Start()
+	switch command {
+	case "serve":
+		// Iniciar el servidor
+		server.Start()
+	case "backup":
+		if err := runBackup(os.Args[2:]); err != nil {
+			log.Fatalf("Backup failed: %v", err)
+		}
+	case "restore":
+		if err := runRestore(os.Args[2:]); err != nil {
+			log.Fatalf("Restore failed: %v", err)
+		}
+	case "scan":
+		if err := runScan(os.Args[2:]); err != nil {
+			log.Fatalf("Scan failed: %v", err)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		fmt.Fprintln(os.Stderr, "Usage: melodia [serve | backup [-o file] | restore [-on-conflict skip|overwrite|fail] file | scan [-workers n] [-watch] dir]")
+		os.Exit(2)
+	}
 }
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"melodia/internal/database"
+	"melodia/internal/library"
+)
+
+// runScan builds the catalog from a music folder, once or repeatedly with -watch
+func runScan(args []string) error {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	workers := flags.Int("workers", 0, "files read at the same time (default one per CPU)")
+	watch := flags.Bool("watch", false, "keep running and rescan the folder periodically")
+	interval := flags.Duration("interval", time.Minute, "time between scans with -watch")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: melodia scan [-workers n] [-watch [-interval 1m]] dir")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("scan needs exactly one directory")
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("invalid -interval %s: must be positive", *interval)
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	if err := database.CreateTablesIfNotExist(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scanner := library.NewScanner(*workers)
+	for {
+		summary, err := scanner.Scan(ctx, flags.Arg(0))
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Println("Scan interrupted")
+				return nil
+			}
+			if !*watch {
+				return err
+			}
+			log.Printf("Scan failed: %v", err)
+		} else {
+			printScanSummary(summary)
+		}
+
+		if !*watch {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// printScanSummary logs what a scan did
+func printScanSummary(summary *library.Summary) {
+	log.Printf("Scan complete in %s: %d audio files", summary.Duration.Round(time.Millisecond), summary.Files)
+	for _, line := range []struct {
+		label string
+		count int64
+	}{
+		{"created", int64(summary.Created)},
+		{"updated", int64(summary.Updated)},
+		{"linked", int64(summary.Linked)},
+		{"moved", int64(summary.Moved)},
+		{"unchanged", int64(summary.Unchanged)},
+		{"duplicates", int64(summary.Duplicates)},
+		{"unsupported", int64(summary.Unsupported)},
+		{"failed", int64(summary.Failed)},
+		{"missing", summary.Missing},
+	} {
+		log.Printf("  %-12s %d", line.label, line.count)
+	}
+	log.Printf("  %-12s %d (%d created, %d updated)", "playlists", summary.Playlists, summary.PlaylistsCreated, summary.PlaylistsUpdated)
+}
@@ -0,0 +1,138 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FormatMP3 identifies MPEG audio files, optionally with ID3 tags
+	FormatMP3 = "mp3"
+	// FormatFLAC identifies native FLAC files
+	FormatFLAC = "flac"
+	// FormatOGG identifies Ogg Vorbis and Ogg Opus files
+	FormatOGG = "ogg"
+
+	// maxMetadataSize caps how much tag data is read into memory, embedded covers included
+	maxMetadataSize = 32 << 20
+)
+
+var (
+	// ErrUnsupportedFormat is returned when the file is not MP3, FLAC or Ogg
+	ErrUnsupportedFormat = errors.New("unsupported audio format")
+	// ErrCorrupt is returned when the file looks like a supported format but cannot be parsed
+	ErrCorrupt = errors.New("corrupt audio file")
+)
+
+// Picture is an embedded cover image
+type Picture struct {
+	MimeType string
+	Data     []byte
+}
+
+// Metadata holds the tags and technical information read from an audio file
+type Metadata struct {
+	Format      string
+	MimeType    string
+	Title       string
+	Artist      string
+	Album       string
+	TrackNumber int
+	Year        int
+	Genre       string
+	DurationMs  int64
+	Cover       *Picture
+}
+
+// MimeTypes maps each supported format to its MIME type
+var MimeTypes = map[string]string{
+	FormatMP3:  "audio/mpeg",
+	FormatFLAC: "audio/flac",
+	FormatOGG:  "audio/ogg",
+}
+
+// Detect returns the audio format of a file from its first bytes, or "" if unknown
+func Detect(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return FormatMP3
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return FormatFLAC
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return FormatOGG
+	case len(header) >= 4:
+		if _, ok := parseFrameHeader(header[:4]); ok {
+			return FormatMP3
+		}
+	}
+	return ""
+}
+
+// Read detects the format of r and extracts its tags, duration and embedded cover
+func Read(r io.ReadSeeker) (*Metadata, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("error reading audio file: %v", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error reading audio file: %v", err)
+	}
+
+	header := make([]byte, 10)
+	n, _ := io.ReadFull(r, header)
+	header = header[:n]
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error reading audio file: %v", err)
+	}
+
+	format := Detect(header)
+	m := &Metadata{Format: format, MimeType: MimeTypes[format]}
+
+	switch format {
+	case FormatMP3:
+		err = readMP3(r, size, m)
+	case FormatFLAC:
+		err = readFLAC(r, m)
+	case FormatOGG:
+		err = readOGG(r, size, m)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	if err != nil {
+		if errors.Is(err, ErrUnsupportedFormat) || errors.Is(err, ErrCorrupt) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+
+	return m, nil
+}
+
+// corrupt wraps a parsing failure as ErrCorrupt
+func corrupt(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrCorrupt, fmt.Sprintf(format, args...))
+}
+
+// leadingNumber parses the number at the start of values such as "3/12" or "1999-05-01"
+func leadingNumber(value string) int {
+	value = strings.TrimSpace(value)
+	end := 0
+	for end < len(value) && value[end] >= '0' && value[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(value[:end])
+	return n
+}
+
+// setIfEmpty assigns value to field unless the field already holds a value
+func setIfEmpty(field *string, value string) {
+	value = strings.TrimSpace(value)
+	if *field == "" && value != "" {
+		*field = value
+	}
+}
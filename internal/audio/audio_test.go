@@ -0,0 +1,214 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// id3Frame builds an ID3v2.3 frame
+func id3Frame(id string, data []byte) []byte {
+	frame := []byte(id)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(data)))
+	frame = append(frame, 0, 0)
+	return append(frame, data...)
+}
+
+func id3TextFrame(id, text string) []byte {
+	return id3Frame(id, append([]byte{3}, text...))
+}
+
+// buildMP3 returns an ID3v2.3 tagged file followed by CBR MPEG1 Layer III frames
+// at 128kbps/44.1kHz, each 417 bytes long and 1152 samples
+func buildMP3(frames int) []byte {
+	var body []byte
+	body = append(body, id3TextFrame("TIT2", "Bohemian Rhapsody")...)
+	body = append(body, id3TextFrame("TPE1", "Queen")...)
+	body = append(body, id3TextFrame("TALB", "A Night at the Opera")...)
+	body = append(body, id3TextFrame("TRCK", "11/12")...)
+	body = append(body, id3TextFrame("TYER", "1975")...)
+	body = append(body, id3TextFrame("TCON", "(17)")...)
+	picture := append([]byte{0}, "image/jpeg"...)
+	picture = append(picture, 0, 3, 0)
+	picture = append(picture, 0xFF, 0xD8, 0xFF)
+	body = append(body, id3Frame("APIC", picture)...)
+
+	size := len(body)
+	file := []byte{'I', 'D', '3', 3, 0, 0,
+		byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F)}
+	file = append(file, body...)
+
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	for i := 0; i < frames; i++ {
+		file = append(file, frame...)
+	}
+	return file
+}
+
+func TestReadMP3(t *testing.T) {
+	m, err := Read(bytes.NewReader(buildMP3(100)))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if m.Format != FormatMP3 || m.MimeType != "audio/mpeg" {
+		t.Errorf("Expected mp3 audio/mpeg, got %s %s", m.Format, m.MimeType)
+	}
+	if m.Title != "Bohemian Rhapsody" || m.Artist != "Queen" || m.Album != "A Night at the Opera" {
+		t.Errorf("Unexpected tags: %+v", m)
+	}
+	if m.TrackNumber != 11 || m.Year != 1975 {
+		t.Errorf("Expected track 11 from 1975, got %d from %d", m.TrackNumber, m.Year)
+	}
+	if m.Genre != "Rock" {
+		t.Errorf("Expected genre Rock, got %q", m.Genre)
+	}
+	// 100 frames * 417 bytes * 8 bits / 128kbps = 2606ms
+	if m.DurationMs != 2606 {
+		t.Errorf("Expected duration of 2606ms, got %d", m.DurationMs)
+	}
+	if m.Cover == nil || m.Cover.MimeType != "image/jpeg" || len(m.Cover.Data) != 3 {
+		t.Errorf("Expected embedded JPEG cover, got %+v", m.Cover)
+	}
+}
+
+func TestReadMP3UTF16Text(t *testing.T) {
+	text := []byte{1, 0xFF, 0xFE, 'C', 0, 0xE9, 0, 0, 0}
+	if got := id3Text(text); got != "Cé" {
+		t.Errorf("Expected 'Cé', got %q", got)
+	}
+}
+
+func TestID3Genre(t *testing.T) {
+	tests := map[string]string{
+		"Rock":             "Rock",
+		"17":               "Rock",
+		"(9)":              "Metal",
+		"(9)Thrash Metal":  "Thrash Metal",
+		"(4)(9)":           "Disco",
+		"((Not a number))": "(Not a number))",
+		"(999)":            "",
+	}
+	for text, expected := range tests {
+		if got := id3Genre(text); got != expected {
+			t.Errorf("id3Genre(%q) = %q, expected %q", text, got, expected)
+		}
+	}
+}
+
+func vorbisComments(comments ...string) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, 4)
+	data = append(data, "test"...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(comments)))
+	for _, c := range comments {
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(c)))
+		data = append(data, c...)
+	}
+	return data
+}
+
+func buildFLAC() []byte {
+	streamInfo := make([]byte, 34)
+	// 44100Hz (0x0AC44) and 441000 total samples = 10 seconds
+	streamInfo[10], streamInfo[11], streamInfo[12] = 0x0A, 0xC4, 0x40
+	binary.BigEndian.PutUint32(streamInfo[14:18], 441000)
+
+	comments := vorbisComments("TITLE=Clair de Lune", "ARTIST=Debussy", "TRACKNUMBER=3", "DATE=1905-01-01")
+
+	file := []byte("fLaC")
+	file = append(file, 0x00, 0, 0, byte(len(streamInfo)))
+	file = append(file, streamInfo...)
+	file = append(file, 0x80|flacVorbisComment, 0, byte(len(comments)>>8), byte(len(comments)))
+	file = append(file, comments...)
+	return file
+}
+
+func TestReadFLAC(t *testing.T) {
+	m, err := Read(bytes.NewReader(buildFLAC()))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if m.Format != FormatFLAC || m.Title != "Clair de Lune" || m.Artist != "Debussy" {
+		t.Errorf("Unexpected metadata: %+v", m)
+	}
+	if m.TrackNumber != 3 || m.Year != 1905 {
+		t.Errorf("Expected track 3 from 1905, got %d from %d", m.TrackNumber, m.Year)
+	}
+	if m.DurationMs != 10000 {
+		t.Errorf("Expected duration of 10000ms, got %d", m.DurationMs)
+	}
+}
+
+func oggPageBytes(serial uint32, granule int64, packet []byte) []byte {
+	page := []byte("OggS")
+	page = append(page, 0, 0)
+	page = binary.LittleEndian.AppendUint64(page, uint64(granule))
+	page = binary.LittleEndian.AppendUint32(page, serial)
+	page = append(page, make([]byte, 8)...) // sequence and CRC
+	var lacing []byte
+	remaining := len(packet)
+	for remaining >= 255 {
+		lacing = append(lacing, 255)
+		remaining -= 255
+	}
+	lacing = append(lacing, byte(remaining))
+	page = append(page, byte(len(lacing)))
+	page = append(page, lacing...)
+	return append(page, packet...)
+}
+
+func TestReadOGGVorbis(t *testing.T) {
+	identification := append([]byte{1}, "vorbis"...)
+	identification = append(identification, 0, 0, 0, 0, 2)
+	identification = binary.LittleEndian.AppendUint32(identification, 48000)
+	identification = append(identification, make([]byte, 14)...)
+
+	comments := append([]byte{3}, "vorbis"...)
+	comments = append(comments, vorbisComments("TITLE=Song", "ARTIST=Band", "ALBUM=Record", "GENRE=Jazz")...)
+	comments = append(comments, 1)
+
+	var file []byte
+	file = append(file, oggPageBytes(7, 0, identification)...)
+	file = append(file, oggPageBytes(7, 0, comments)...)
+	file = append(file, oggPageBytes(7, 96000, []byte{0})...)
+
+	m, err := Read(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if m.Format != FormatOGG || m.Title != "Song" || m.Artist != "Band" || m.Album != "Record" || m.Genre != "Jazz" {
+		t.Errorf("Unexpected metadata: %+v", m)
+	}
+	if m.DurationMs != 2000 {
+		t.Errorf("Expected duration of 2000ms, got %d", m.DurationMs)
+	}
+}
+
+func TestReadUnsupported(t *testing.T) {
+	_, err := Read(bytes.NewReader([]byte("this is not an audio file")))
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestReadCorrupt(t *testing.T) {
+	truncated := buildFLAC()[:20]
+	if _, err := Read(bytes.NewReader(truncated)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected ErrCorrupt for truncated FLAC, got %v", err)
+	}
+
+	noFrames := buildMP3(0)
+	if _, err := Read(bytes.NewReader(noFrames)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected ErrCorrupt for MP3 without frames, got %v", err)
+	}
+
+	// An extended header size byte with its high bit set is not syncsafe
+	badExtended := []byte("ID3\x040A\x00\x00\x00\x10\xbe000000000000000")
+	if _, err := Read(bytes.NewReader(badExtended)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected ErrCorrupt for invalid extended header, got %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package audio
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+const (
+	flacStreamInfo    = 0
+	flacVorbisComment = 4
+	flacPicture       = 6
+)
+
+func readFLAC(r io.ReadSeeker, m *Metadata) error {
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+
+	var frontCover bool
+	for {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return corrupt("truncated FLAC metadata")
+		}
+
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		switch blockType {
+		case flacStreamInfo, flacVorbisComment, flacPicture:
+			if length > maxMetadataSize {
+				return corrupt("FLAC metadata block too large")
+			}
+			block := make([]byte, length)
+			if _, err := io.ReadFull(r, block); err != nil {
+				return corrupt("truncated FLAC metadata block")
+			}
+
+			switch blockType {
+			case flacStreamInfo:
+				if len(block) < 18 {
+					return corrupt("invalid FLAC STREAMINFO block")
+				}
+				sampleRate := int64(block[10])<<12 | int64(block[11])<<4 | int64(block[12])>>4
+				totalSamples := int64(block[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(block[14:18]))
+				if sampleRate == 0 {
+					return corrupt("invalid FLAC sample rate")
+				}
+				m.DurationMs = totalSamples * 1000 / sampleRate
+			case flacVorbisComment:
+				if err := parseVorbisComments(block, m); err != nil {
+					return err
+				}
+			case flacPicture:
+				picture, pictureType := parseFLACPicture(block)
+				if picture != nil && (m.Cover == nil || (pictureType == 3 && !frontCover)) {
+					m.Cover = picture
+					frontCover = pictureType == 3
+				}
+			}
+		default:
+			if _, err := r.Seek(length, io.SeekCurrent); err != nil {
+				return corrupt("truncated FLAC metadata block")
+			}
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// parseVorbisComments reads a little endian Vorbis comment list (vendor string,
+// then KEY=value entries), as used by FLAC and Ogg
+func parseVorbisComments(data []byte, m *Metadata) error {
+	readString := func() (string, bool) {
+		if len(data) < 4 {
+			return "", false
+		}
+		length := binary.LittleEndian.Uint32(data)
+		if uint64(length) > uint64(len(data)-4) {
+			return "", false
+		}
+		value := string(data[4 : 4+length])
+		data = data[4+length:]
+		return value, true
+	}
+
+	if _, ok := readString(); !ok {
+		return corrupt("invalid Vorbis comment vendor")
+	}
+	if len(data) < 4 {
+		return corrupt("invalid Vorbis comment count")
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+
+	var legacyCover, legacyMime string
+	for i := uint32(0); i < count; i++ {
+		comment, ok := readString()
+		if !ok {
+			return corrupt("truncated Vorbis comments")
+		}
+
+		key, value, found := strings.Cut(comment, "=")
+		if !found {
+			continue
+		}
+
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			setIfEmpty(&m.Title, value)
+		case "ARTIST":
+			setIfEmpty(&m.Artist, value)
+		case "ALBUM":
+			setIfEmpty(&m.Album, value)
+		case "GENRE":
+			setIfEmpty(&m.Genre, value)
+		case "TRACKNUMBER":
+			m.TrackNumber = leadingNumber(value)
+		case "DATE", "YEAR":
+			if year := leadingNumber(value); year > 0 && m.Year == 0 {
+				m.Year = year
+			}
+		case "METADATA_BLOCK_PICTURE":
+			if raw, err := base64.StdEncoding.DecodeString(value); err == nil {
+				if picture, pictureType := parseFLACPicture(raw); picture != nil && (m.Cover == nil || pictureType == 3) {
+					m.Cover = picture
+				}
+			}
+		case "COVERART":
+			legacyCover = value
+		case "COVERARTMIME":
+			legacyMime = value
+		}
+	}
+
+	if m.Cover == nil && legacyCover != "" {
+		if raw, err := base64.StdEncoding.DecodeString(legacyCover); err == nil {
+			if legacyMime == "" {
+				legacyMime = "image/jpeg"
+			}
+			m.Cover = &Picture{MimeType: legacyMime, Data: raw}
+		}
+	}
+
+	return nil
+}
+
+// parseFLACPicture decodes a FLAC PICTURE block, returning the picture and its type
+func parseFLACPicture(data []byte) (*Picture, uint32) {
+	readUint32 := func() (uint32, bool) {
+		if len(data) < 4 {
+			return 0, false
+		}
+		v := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		return v, true
+	}
+	readBytes := func() ([]byte, bool) {
+		length, ok := readUint32()
+		if !ok || uint64(length) > uint64(len(data)) {
+			return nil, false
+		}
+		v := data[:length]
+		data = data[length:]
+		return v, true
+	}
+
+	pictureType, ok := readUint32()
+	if !ok {
+		return nil, 0
+	}
+	mimeType, ok := readBytes()
+	if !ok {
+		return nil, 0
+	}
+	if _, ok := readBytes(); !ok { // description
+		return nil, 0
+	}
+	if len(data) < 16 { // width, height, depth, colors
+		return nil, 0
+	}
+	data = data[16:]
+	picture, ok := readBytes()
+	if !ok || len(picture) == 0 {
+		return nil, 0
+	}
+
+	return &Picture{MimeType: strings.ToLower(string(mimeType)), Data: picture}, pictureType
+}
@@ -0,0 +1,523 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// frameHeader is a decoded MPEG audio frame header
+type frameHeader struct {
+	mpeg1           bool
+	layer           int
+	bitrate         int // bits per second
+	sampleRate      int
+	samplesPerFrame int
+	mono            bool
+	length          int
+}
+
+var (
+	bitratesV1 = [4][15]int{
+		3: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		2: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		1: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	}
+	bitratesV2 = [4][15]int{
+		3: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		2: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		1: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	}
+	sampleRates = map[int][3]int{
+		3: {44100, 48000, 32000}, // MPEG 1
+		2: {22050, 24000, 16000}, // MPEG 2
+		0: {11025, 12000, 8000},  // MPEG 2.5
+	}
+)
+
+// parseFrameHeader decodes a 4 byte MPEG frame header, rejecting reserved values
+func parseFrameHeader(b []byte) (frameHeader, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return frameHeader{}, false
+	}
+
+	version := int(b[1]>>3) & 3
+	layerBits := int(b[1]>>1) & 3
+	bitrateIndex := int(b[2] >> 4)
+	rateIndex := int(b[2]>>2) & 3
+	if version == 1 || layerBits == 0 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return frameHeader{}, false
+	}
+
+	h := frameHeader{
+		mpeg1:      version == 3,
+		layer:      4 - layerBits,
+		sampleRate: sampleRates[version][rateIndex],
+		mono:       b[3]>>6 == 3,
+	}
+
+	if h.mpeg1 {
+		h.bitrate = bitratesV1[layerBits][bitrateIndex] * 1000
+	} else {
+		h.bitrate = bitratesV2[layerBits][bitrateIndex] * 1000
+	}
+
+	padding := int(b[2]>>1) & 1
+	switch {
+	case h.layer == 1:
+		h.samplesPerFrame = 384
+		h.length = (12*h.bitrate/h.sampleRate + padding) * 4
+	case h.layer == 3 && !h.mpeg1:
+		h.samplesPerFrame = 576
+		h.length = 72*h.bitrate/h.sampleRate + padding
+	default:
+		h.samplesPerFrame = 1152
+		h.length = 144*h.bitrate/h.sampleRate + padding
+	}
+
+	return h, true
+}
+
+func readMP3(r io.ReadSeeker, size int64, m *Metadata) error {
+	audioStart, err := readID3v2(r, m)
+	if err != nil {
+		return err
+	}
+
+	// ID3v1 lives in the last 128 bytes and only fills in what ID3v2 left empty
+	audioEnd := size
+	if size-audioStart >= 128 {
+		tail := make([]byte, 128)
+		if _, err := r.Seek(size-128, io.SeekStart); err == nil {
+			if _, err := io.ReadFull(r, tail); err == nil && bytes.HasPrefix(tail, []byte("TAG")) {
+				readID3v1(tail, m)
+				audioEnd -= 128
+			}
+		}
+	}
+
+	return readMPEGDuration(r, audioStart, audioEnd, m)
+}
+
+// readMPEGDuration locates the first audio frame and computes the duration from a
+// Xing/Info or VBRI header when present, or from the bitrate for CBR files
+func readMPEGDuration(r io.ReadSeeker, start, end int64, m *Metadata) error {
+	const searchWindow = 64 << 10
+
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	window := make([]byte, searchWindow)
+	n, _ := io.ReadFull(r, window)
+	window = window[:n]
+
+	for i := 0; i+4 <= len(window); i++ {
+		h, ok := parseFrameHeader(window[i:])
+		if !ok {
+			continue
+		}
+
+		// Require the next frame to line up as well, to skip false syncs inside junk data
+		next := i + h.length
+		if next+4 <= len(window) {
+			if _, ok := parseFrameHeader(window[next:]); !ok {
+				continue
+			}
+		} else if int64(next) < end-start {
+			continue
+		}
+
+		frame := window[i:]
+		if frames := vbrFrameCount(frame, h); frames > 0 {
+			m.DurationMs = frames * int64(h.samplesPerFrame) * 1000 / int64(h.sampleRate)
+		} else {
+			audioBytes := end - start - int64(i)
+			m.DurationMs = audioBytes * 8 * 1000 / int64(h.bitrate)
+		}
+		return nil
+	}
+
+	return corrupt("no MPEG audio frames found")
+}
+
+// vbrFrameCount reads the total frame count from a Xing/Info or VBRI header
+func vbrFrameCount(frame []byte, h frameHeader) int64 {
+	sideInfo := 32
+	switch {
+	case h.mpeg1 && h.mono:
+		sideInfo = 17
+	case !h.mpeg1 && !h.mono:
+		sideInfo = 17
+	case !h.mpeg1 && h.mono:
+		sideInfo = 9
+	}
+
+	xing := 4 + sideInfo
+	if len(frame) >= xing+12 {
+		tag := string(frame[xing : xing+4])
+		if tag == "Xing" || tag == "Info" {
+			flags := binary.BigEndian.Uint32(frame[xing+4:])
+			if flags&1 != 0 {
+				return int64(binary.BigEndian.Uint32(frame[xing+8:]))
+			}
+		}
+	}
+
+	const vbri = 36
+	if len(frame) >= vbri+18 && string(frame[vbri:vbri+4]) == "VBRI" {
+		return int64(binary.BigEndian.Uint32(frame[vbri+14:]))
+	}
+
+	return 0
+}
+
+// readID3v2 parses an ID3v2.2/2.3/2.4 tag at the start of the file, if any,
+// and returns the offset where audio data begins
+func readID3v2(r io.ReadSeeker, m *Metadata) (int64, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.HasPrefix(header, []byte("ID3")) {
+		return 0, nil
+	}
+
+	major := header[3]
+	flags := header[5]
+	size := syncsafe(header[6:10])
+	if major < 2 || major > 4 || size < 0 {
+		return 0, corrupt("invalid ID3v2 header")
+	}
+
+	tagEnd := int64(10 + size)
+	if flags&0x10 != 0 {
+		tagEnd += 10 // footer
+	}
+
+	if size > maxMetadataSize {
+		return tagEnd, nil
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, corrupt("truncated ID3v2 tag")
+	}
+
+	if flags&0x80 != 0 && major < 4 {
+		body = removeUnsynchronisation(body)
+	}
+
+	if flags&0x40 != 0 {
+		if len(body) < 4 {
+			return 0, corrupt("truncated ID3v2 extended header")
+		}
+		var extended int
+		if major == 4 {
+			extended = syncsafe(body[:4])
+		} else {
+			extended = int(binary.BigEndian.Uint32(body[:4])) + 4
+		}
+		if extended < 0 || extended > len(body) {
+			return 0, corrupt("invalid ID3v2 extended header")
+		}
+		body = body[extended:]
+	}
+
+	parseID3Frames(body, major, m)
+	return tagEnd, nil
+}
+
+func parseID3Frames(body []byte, major byte, m *Metadata) {
+	idLength, headerLength := 4, 10
+	if major == 2 {
+		idLength, headerLength = 3, 6
+	}
+
+	var covers []*Picture
+	var frontCover *Picture
+
+	for len(body) >= headerLength && body[0] != 0 {
+		id := string(body[:idLength])
+
+		var size int
+		var formatFlags byte
+		switch major {
+		case 2:
+			size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(body[4:8]))
+			formatFlags = body[9]
+		default:
+			size = syncsafe(body[4:8])
+			formatFlags = body[9]
+		}
+
+		if size < 0 || headerLength+size > len(body) {
+			return
+		}
+		data := body[headerLength : headerLength+size]
+		body = body[headerLength+size:]
+
+		if major == 3 {
+			// Compressed or encrypted frames are skipped
+			if formatFlags&0xC0 != 0 {
+				continue
+			}
+			if formatFlags&0x20 != 0 && len(data) > 0 {
+				data = data[1:]
+			}
+		}
+		if major == 4 {
+			if formatFlags&0x0C != 0 {
+				continue
+			}
+			if formatFlags&0x40 != 0 && len(data) > 0 {
+				data = data[1:]
+			}
+			if formatFlags&0x01 != 0 && len(data) >= 4 {
+				data = data[4:]
+			}
+			if formatFlags&0x02 != 0 {
+				data = removeUnsynchronisation(data)
+			}
+		}
+
+		switch id {
+		case "TIT2", "TT2":
+			setIfEmpty(&m.Title, id3Text(data))
+		case "TPE1", "TP1":
+			setIfEmpty(&m.Artist, id3Text(data))
+		case "TALB", "TAL":
+			setIfEmpty(&m.Album, id3Text(data))
+		case "TCON", "TCO":
+			setIfEmpty(&m.Genre, id3Genre(id3Text(data)))
+		case "TRCK", "TRK":
+			m.TrackNumber = leadingNumber(id3Text(data))
+		case "TYER", "TYE", "TDRC":
+			if year := leadingNumber(id3Text(data)); year > 0 {
+				m.Year = year
+			}
+		case "TLEN", "TLE":
+			// TLEN is only a hint, the MPEG stream duration takes precedence later
+			if m.DurationMs == 0 {
+				m.DurationMs = int64(leadingNumber(id3Text(data)))
+			}
+		case "APIC", "PIC":
+			if picture, pictureType := id3Picture(data, major); picture != nil {
+				covers = append(covers, picture)
+				if pictureType == 3 && frontCover == nil {
+					frontCover = picture
+				}
+			}
+		}
+	}
+
+	if frontCover != nil {
+		m.Cover = frontCover
+	} else if len(covers) > 0 {
+		m.Cover = covers[0]
+	}
+}
+
+// id3Text decodes a text frame, keeping the first value of multi-valued frames
+func id3Text(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	text := decodeID3String(data[0], data[1:])
+	if i := strings.IndexByte(text, 0); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
+
+// id3Genre resolves the ID3v1 genre numbers older taggers write in TCON, as
+// "(17)", "(17)Rock" or "17", to genre names
+func id3Genre(text string) string {
+	if n, err := strconv.Atoi(text); err == nil {
+		return id3v1Genre(n)
+	}
+	genre := ""
+	for strings.HasPrefix(text, "(") && !strings.HasPrefix(text, "((") {
+		end := strings.IndexByte(text, ')')
+		if end < 0 {
+			break
+		}
+		if n, err := strconv.Atoi(text[1:end]); err == nil && genre == "" {
+			genre = id3v1Genre(n)
+		}
+		text = text[end+1:]
+	}
+	// A refinement after the references is more precise than the number
+	text = strings.TrimPrefix(text, "(")
+	if text != "" {
+		return text
+	}
+	return genre
+}
+
+// id3v1Genre returns the name of an ID3v1 genre number, or "" when unknown
+func id3v1Genre(n int) string {
+	if n < 0 || n >= len(id3v1Genres) {
+		return ""
+	}
+	return id3v1Genres[n]
+}
+
+// id3v1Genres are the genres numbered by the ID3v1 specification
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop",
+	"Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B", "Rap",
+	"Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska", "Death Metal", "Pranks",
+	"Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance",
+	"Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock",
+	"Ethnic", "Gothic", "Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap", "Pop/Funk", "Jungle",
+	"Native American", "Cabaret", "New Wave", "Psychadelic", "Rave", "Showtunes", "Trailer", "Lo-Fi",
+	"Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+}
+
+// id3Picture decodes an APIC (v2.3/v2.4) or PIC (v2.2) frame
+func id3Picture(data []byte, major byte) (*Picture, byte) {
+	if len(data) < 4 {
+		return nil, 0
+	}
+	encoding := data[0]
+	rest := data[1:]
+
+	var mimeType string
+	if major == 2 {
+		switch strings.ToUpper(string(rest[:3])) {
+		case "PNG":
+			mimeType = "image/png"
+		default:
+			mimeType = "image/jpeg"
+		}
+		rest = rest[3:]
+	} else {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return nil, 0
+		}
+		mimeType = strings.ToLower(string(rest[:end]))
+		rest = rest[end+1:]
+		if !strings.Contains(mimeType, "/") {
+			mimeType = "image/" + strings.TrimPrefix(mimeType, "image/")
+		}
+	}
+
+	if len(rest) < 1 {
+		return nil, 0
+	}
+	pictureType := rest[0]
+	rest = rest[1:]
+
+	// Skip the description, terminated by one or two zero bytes depending on the encoding
+	if encoding == 1 || encoding == 2 {
+		i := 0
+		for ; i+1 < len(rest); i += 2 {
+			if rest[i] == 0 && rest[i+1] == 0 {
+				break
+			}
+		}
+		if i+2 > len(rest) {
+			return nil, 0
+		}
+		rest = rest[i+2:]
+	} else {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return nil, 0
+		}
+		rest = rest[end+1:]
+	}
+
+	if len(rest) == 0 {
+		return nil, 0
+	}
+
+	if mimeType == "image/jpg" {
+		mimeType = "image/jpeg"
+	}
+
+	return &Picture{MimeType: mimeType, Data: rest}, pictureType
+}
+
+// decodeID3String decodes ISO-8859-1, UTF-16 (with BOM), UTF-16BE or UTF-8 text
+func decodeID3String(encoding byte, data []byte) string {
+	switch encoding {
+	case 1, 2:
+		bigEndian := encoding == 2
+		if len(data) >= 2 {
+			if data[0] == 0xFF && data[1] == 0xFE {
+				bigEndian, data = false, data[2:]
+			} else if data[0] == 0xFE && data[1] == 0xFF {
+				bigEndian, data = true, data[2:]
+			}
+		}
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			if bigEndian {
+				units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+			} else {
+				units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+			}
+		}
+		return string(utf16.Decode(units))
+	case 3:
+		return string(data)
+	default:
+		return latin1(data)
+	}
+}
+
+// readID3v1 parses a 128 byte ID3v1/1.1 tag
+func readID3v1(tag []byte, m *Metadata) {
+	field := func(start, length int) string {
+		value := tag[start : start+length]
+		if i := bytes.IndexByte(value, 0); i >= 0 {
+			value = value[:i]
+		}
+		return strings.TrimSpace(latin1(value))
+	}
+
+	setIfEmpty(&m.Title, field(3, 30))
+	setIfEmpty(&m.Artist, field(33, 30))
+	setIfEmpty(&m.Album, field(63, 30))
+	if m.Year == 0 {
+		m.Year = leadingNumber(field(93, 4))
+	}
+	if m.TrackNumber == 0 && tag[125] == 0 && tag[126] != 0 {
+		m.TrackNumber = int(tag[126])
+	}
+	setIfEmpty(&m.Genre, id3v1Genre(int(tag[127])))
+}
+
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// syncsafe decodes a 28 bit integer stored in four 7 bit bytes
+func syncsafe(b []byte) int {
+	if b[0]&0x80 != 0 || b[1]&0x80 != 0 || b[2]&0x80 != 0 || b[3]&0x80 != 0 {
+		return -1
+	}
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+// removeUnsynchronisation reverts the ID3 unsynchronisation scheme (0xFF 0x00 -> 0xFF)
+func removeUnsynchronisation(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		out = append(out, data[i])
+		if data[i] == 0xFF && i+1 < len(data) && data[i+1] == 0x00 {
+			i++
+		}
+	}
+	return out
+}
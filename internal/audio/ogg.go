@@ -0,0 +1,155 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// oggPage is the part of an Ogg page header needed to rebuild packets
+type oggPage struct {
+	granule  int64
+	serial   uint32
+	segments []byte
+}
+
+func readOGGPageHeader(r io.Reader) (*oggPage, error) {
+	header := make([]byte, 27)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(header, []byte("OggS")) || header[4] != 0 {
+		return nil, corrupt("invalid Ogg page")
+	}
+
+	segments := make([]byte, header[26])
+	if _, err := io.ReadFull(r, segments); err != nil {
+		return nil, err
+	}
+
+	return &oggPage{
+		granule:  int64(binary.LittleEndian.Uint64(header[6:14])),
+		serial:   binary.LittleEndian.Uint32(header[14:18]),
+		segments: segments,
+	}, nil
+}
+
+// readOGGPackets returns the first n packets of the first logical stream
+func readOGGPackets(r io.Reader, n int) ([][]byte, uint32, error) {
+	var packets [][]byte
+	var current []byte
+	var serial uint32
+	total := 0
+
+	for first := true; len(packets) < n; first = false {
+		page, err := readOGGPageHeader(r)
+		if err != nil {
+			return nil, 0, corrupt("truncated Ogg stream")
+		}
+		if first {
+			serial = page.serial
+		}
+
+		for _, segment := range page.segments {
+			data := make([]byte, segment)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, 0, corrupt("truncated Ogg page")
+			}
+			if page.serial != serial {
+				continue
+			}
+
+			total += int(segment)
+			if total > maxMetadataSize {
+				return nil, 0, corrupt("Ogg header packets too large")
+			}
+
+			current = append(current, data...)
+			// A lacing value below 255 terminates the packet
+			if segment < 255 {
+				packets = append(packets, current)
+				current = nil
+				if len(packets) == n {
+					break
+				}
+			}
+		}
+	}
+
+	return packets, serial, nil
+}
+
+func readOGG(r io.ReadSeeker, size int64, m *Metadata) error {
+	packets, serial, err := readOGGPackets(r, 2)
+	if err != nil {
+		return err
+	}
+	identification, comments := packets[0], packets[1]
+
+	var sampleRate, preSkip int64
+	switch {
+	case len(identification) >= 16 && identification[0] == 1 && string(identification[1:7]) == "vorbis":
+		sampleRate = int64(binary.LittleEndian.Uint32(identification[12:16]))
+		if len(comments) < 7 || comments[0] != 3 || string(comments[1:7]) != "vorbis" {
+			return corrupt("missing Vorbis comment header")
+		}
+		comments = comments[7:]
+	case len(identification) >= 12 && string(identification[:8]) == "OpusHead":
+		// Opus granule positions always count 48kHz samples
+		sampleRate = 48000
+		preSkip = int64(binary.LittleEndian.Uint16(identification[10:12]))
+		if len(comments) < 8 || string(comments[:8]) != "OpusTags" {
+			return corrupt("missing Opus tags header")
+		}
+		comments = comments[8:]
+	default:
+		return ErrUnsupportedFormat
+	}
+
+	if sampleRate == 0 {
+		return corrupt("invalid Ogg sample rate")
+	}
+
+	if err := parseVorbisComments(comments, m); err != nil {
+		return err
+	}
+
+	if granule := lastOGGGranule(r, size, serial); granule > preSkip {
+		m.DurationMs = (granule - preSkip) * 1000 / sampleRate
+	}
+
+	return nil
+}
+
+// lastOGGGranule returns the granule position of the last page of the stream,
+// which is the total number of samples
+func lastOGGGranule(r io.ReadSeeker, size int64, serial uint32) int64 {
+	const tailSize = 64 << 10
+
+	start := size - tailSize
+	if start < 0 {
+		start = 0
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return 0
+	}
+	tail, err := io.ReadAll(r)
+	if err != nil {
+		return 0
+	}
+
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		if i+27 > len(tail) || tail[i+4] != 0 {
+			continue
+		}
+		if binary.LittleEndian.Uint32(tail[i+14:i+18]) != serial {
+			continue
+		}
+		granule := int64(binary.LittleEndian.Uint64(tail[i+6 : i+14]))
+		if granule >= 0 {
+			return granule
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"melodia/internal/models"
+)
+
+const (
+	// Format identifies melodia backup archives
+	Format = "melodia-backup"
+	// Version is the archive layout written by Write. Read accepts this version
+	// and every earlier one.
+	Version = 1
+)
+
+// Write stamps the backup with the archive format and version and writes it as
+// gzip-compressed JSON
+func Write(w io.Writer, backup *models.Backup) error {
+	backup.Format = Format
+	backup.Version = Version
+	if backup.CreatedAt.IsZero() {
+		backup.CreatedAt = time.Now().UTC()
+	}
+
+	gz := gzip.NewWriter(w)
+	gz.Name = "melodia-backup.json"
+	gz.ModTime = backup.CreatedAt
+
+	if err := json.NewEncoder(gz).Encode(backup); err != nil {
+		gz.Close()
+		return fmt.Errorf("error encoding backup: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("error compressing backup: %v", err)
+	}
+	return nil
+}
+
+// Read decompresses and decodes a backup archive, rejecting files that are not
+// melodia backups or were written by a newer version
+func Read(r io.Reader) (*models.Backup, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("backup is not a gzip archive: %v", err)
+	}
+	defer gz.Close()
+
+	var backup models.Backup
+	if err := json.NewDecoder(gz).Decode(&backup); err != nil {
+		return nil, fmt.Errorf("error decoding backup: %v", err)
+	}
+
+	if backup.Format != Format {
+		return nil, fmt.Errorf("file is not a melodia backup")
+	}
+	if backup.Version < 1 || backup.Version > Version {
+		return nil, fmt.Errorf("unsupported backup version %d (supported up to %d)", backup.Version, Version)
+	}
+
+	return &backup, nil
+}
@@ -0,0 +1,82 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+
+	"melodia/internal/models"
+)
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	publishedAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	original := &models.Backup{
+		Songs: []models.BackupSong{
+			{ID: 7, Title: "Bohemian Rhapsody", Artist: "Queen", DurationMs: 354000, CreatedAt: publishedAt, UpdatedAt: publishedAt},
+		},
+		Lyrics: []models.BackupLyrics{},
+		Playlists: []models.BackupPlaylist{
+			{ID: 3, Name: "Rock", Description: "Classic rock", IsPublished: true, PublishedAt: &publishedAt},
+		},
+		PlaylistSongs: []models.BackupPlaylistSong{
+			{ID: 12, PlaylistID: 3, SongID: 7, AddedAt: publishedAt},
+		},
+		SongLikes: []models.BackupSongLike{
+			{ListenerID: "ana", SongID: 7, CreatedAt: publishedAt},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, original); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	restored, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if restored.Format != Format || restored.Version != Version || restored.CreatedAt.IsZero() {
+		t.Errorf("Expected archive header to be set, got %s v%d at %v", restored.Format, restored.Version, restored.CreatedAt)
+	}
+	if len(restored.Songs) != 1 || restored.Songs[0].ID != 7 || restored.Songs[0].DurationMs != 354000 {
+		t.Errorf("Expected song to keep its ID and fields, got %+v", restored.Songs)
+	}
+	if len(restored.Playlists) != 1 || !restored.Playlists[0].IsPublished || !restored.Playlists[0].PublishedAt.Equal(publishedAt) {
+		t.Errorf("Expected playlist publish state to round trip, got %+v", restored.Playlists)
+	}
+	if len(restored.PlaylistSongs) != 1 || restored.PlaylistSongs[0].PlaylistID != 3 || restored.PlaylistSongs[0].SongID != 7 {
+		t.Errorf("Expected playlist entry to round trip, got %+v", restored.PlaylistSongs)
+	}
+	if len(restored.SongLikes) != 1 || restored.SongLikes[0].ListenerID != "ana" || restored.SongLikes[0].SongID != 7 {
+		t.Errorf("Expected song like to round trip, got %+v", restored.SongLikes)
+	}
+}
+
+func gzipped(t *testing.T, data string) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(data))
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return &buf
+}
+
+func TestReadRejectsInvalidArchives(t *testing.T) {
+	if _, err := Read(bytes.NewBufferString(`{"format":"melodia-backup","version":1}`)); err == nil {
+		t.Error("Expected error for uncompressed file")
+	}
+	if _, err := Read(gzipped(t, `{"format":"other","version":1}`)); err == nil {
+		t.Error("Expected error for foreign format")
+	}
+	if _, err := Read(gzipped(t, `{"format":"melodia-backup","version":99}`)); err == nil {
+		t.Error("Expected error for newer version")
+	}
+	if _, err := Read(gzipped(t, `{"format":"melodia-backup","version":1}`)); err != nil {
+		t.Errorf("Expected empty backup to be accepted, got %v", err)
+	}
+}
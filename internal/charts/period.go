@@ -0,0 +1,61 @@
+// Package charts defines the periods charts are computed over
+package charts
+
+import (
+	"fmt"
+	"time"
+)
+
+// Chart periods
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// Window is a chart period as a range of UTC days, with the period right before
+// it to compare ranks against
+type Window struct {
+	Start         time.Time
+	End           time.Time
+	PreviousStart time.Time
+}
+
+// WindowFor returns the period of the given kind containing date. Weeks start
+// on Monday and days follow UTC, so the same date always gives the same window.
+func WindowFor(period string, date time.Time) (Window, error) {
+	date = date.UTC()
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch period {
+	case PeriodDay:
+		return Window{Start: day, End: day.AddDate(0, 0, 1), PreviousStart: day.AddDate(0, 0, -1)}, nil
+	case PeriodWeek:
+		// Go weeks start on Sunday
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return Window{Start: start, End: start.AddDate(0, 0, 7), PreviousStart: start.AddDate(0, 0, -7)}, nil
+	case PeriodMonth:
+		start := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return Window{Start: start, End: start.AddDate(0, 1, 0), PreviousStart: start.AddDate(0, -1, 0)}, nil
+	default:
+		return Window{}, fmt.Errorf("unknown period %q, expected day, week or month", period)
+	}
+}
+
+// Movement describes how an entry moved since the previous period, returning
+// "new", "up", "down" or "same" and how many places it went up
+func Movement(rank int, previousRank *int) (string, int) {
+	if previousRank == nil {
+		return "new", 0
+	}
+
+	change := *previousRank - rank
+	switch {
+	case change > 0:
+		return "up", change
+	case change < 0:
+		return "down", change
+	default:
+		return "same", 0
+	}
+}
@@ -0,0 +1,69 @@
+package charts
+
+import (
+	"testing"
+	"time"
+)
+
+func date(value string) time.Time {
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestWindowFor(t *testing.T) {
+	tests := []struct {
+		period   string
+		date     time.Time
+		start    string
+		end      string
+		previous string
+	}{
+		{PeriodDay, date("2026-03-01"), "2026-03-01", "2026-03-02", "2026-02-28"},
+		{PeriodWeek, date("2026-10-18"), "2026-10-12", "2026-10-19", "2026-10-05"},
+		{PeriodWeek, date("2026-10-12"), "2026-10-12", "2026-10-19", "2026-10-05"},
+		{PeriodMonth, date("2026-03-31"), "2026-03-01", "2026-04-01", "2026-02-01"},
+		{PeriodMonth, date("2026-01-15"), "2026-01-01", "2026-02-01", "2025-12-01"},
+		// Late in the day west of UTC is already the next UTC day
+		{PeriodDay, time.Date(2026, 5, 4, 22, 0, 0, 0, time.FixedZone("UTC-3", -3*3600)), "2026-05-05", "2026-05-06", "2026-05-04"},
+	}
+
+	for _, test := range tests {
+		window, err := WindowFor(test.period, test.date)
+		if err != nil {
+			t.Fatalf("%s %v: unexpected error %v", test.period, test.date, err)
+		}
+		if !window.Start.Equal(date(test.start)) || !window.End.Equal(date(test.end)) || !window.PreviousStart.Equal(date(test.previous)) {
+			t.Errorf("%s %v: got %v - %v (previous %v)", test.period, test.date, window.Start, window.End, window.PreviousStart)
+		}
+	}
+
+	if _, err := WindowFor("year", date("2026-01-01")); err == nil {
+		t.Error("Expected error for unknown period")
+	}
+}
+
+func TestMovement(t *testing.T) {
+	rank := func(r int) *int { return &r }
+
+	tests := []struct {
+		rank     int
+		previous *int
+		movement string
+		change   int
+	}{
+		{1, nil, "new", 0},
+		{1, rank(4), "up", 3},
+		{5, rank(2), "down", -3},
+		{2, rank(2), "same", 0},
+	}
+
+	for _, test := range tests {
+		movement, change := Movement(test.rank, test.previous)
+		if movement != test.movement || change != test.change {
+			t.Errorf("Rank %d: expected %s %d, got %s %d", test.rank, test.movement, test.change, movement, change)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package compose validates set expressions over playlists and compiles them
+// into SQL set operations on playlist_songs
+package compose
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"melodia/internal/models"
+)
+
+// Operators of a composition
+const (
+	OpUnion     = "union"
+	OpIntersect = "intersect"
+	OpExcept    = "except"
+)
+
+const (
+	// MaxDepth is how deeply operators can be nested
+	MaxDepth = 8
+	// MaxPlaylists is how many playlist references an expression can hold
+	MaxPlaylists = 50
+)
+
+// sqlOperators maps each operator to its SQL set operation. Without ALL every
+// operation removes duplicate songs.
+var sqlOperators = map[string]string{
+	OpUnion:     "UNION",
+	OpIntersect: "INTERSECT",
+	OpExcept:    "EXCEPT",
+}
+
+// Validate checks that an expression is well formed and small enough to run
+func Validate(expr *models.ComposeExpression) error {
+	count := 0
+	if err := validate(expr, 1, &count); err != nil {
+		return err
+	}
+	if count > MaxPlaylists {
+		return fmt.Errorf("an expression cannot reference more than %d playlists", MaxPlaylists)
+	}
+	return nil
+}
+
+func validate(expr *models.ComposeExpression, depth int, count *int) error {
+	if depth > MaxDepth {
+		return fmt.Errorf("expressions cannot be nested more than %d levels deep", MaxDepth)
+	}
+
+	if expr.Op == "" {
+		if expr.Playlist == 0 || len(expr.Operands) > 0 {
+			return fmt.Errorf("each operand must be either a playlist ID or an op with operands")
+		}
+		*count++
+		return nil
+	}
+
+	if expr.Playlist != 0 {
+		return fmt.Errorf("an operand cannot have both a playlist and an op")
+	}
+	if _, ok := sqlOperators[expr.Op]; !ok {
+		return fmt.Errorf("unknown op %q, expected union, intersect or except", expr.Op)
+	}
+	if len(expr.Operands) < 2 {
+		return fmt.Errorf("%s needs at least two operands", expr.Op)
+	}
+	for i := range expr.Operands {
+		if err := validate(&expr.Operands[i], depth+1, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compile returns a query selecting the song_id of every song in the result of a
+// valid expression, appending its parameters to args
+func Compile(expr *models.ComposeExpression, args []interface{}) (string, []interface{}) {
+	if expr.Op == "" {
+		args = append(args, expr.Playlist)
+		return "SELECT song_id FROM playlist_songs WHERE playlist_id = $" + strconv.Itoa(len(args)), args
+	}
+
+	parts := make([]string, len(expr.Operands))
+	for i := range expr.Operands {
+		var part string
+		part, args = Compile(&expr.Operands[i], args)
+		parts[i] = "(" + part + ")"
+	}
+	// Operators are applied left to right, so A except B except C removes both B and C
+	return strings.Join(parts, " "+sqlOperators[expr.Op]+" "), args
+}
+
+// Playlists returns the playlist IDs of an expression from left to right, with
+// repetitions
+func Playlists(expr *models.ComposeExpression) []uint {
+	if expr.Op == "" {
+		return []uint{expr.Playlist}
+	}
+
+	var ids []uint
+	for i := range expr.Operands {
+		ids = append(ids, Playlists(&expr.Operands[i])...)
+	}
+	return ids
+}
+
+// String renders an expression for people, e.g. "(1 union 2) except 3"
+func String(expr *models.ComposeExpression) string {
+	if expr.Op == "" {
+		return strconv.FormatUint(uint64(expr.Playlist), 10)
+	}
+
+	parts := make([]string, len(expr.Operands))
+	for i := range expr.Operands {
+		parts[i] = String(&expr.Operands[i])
+		if expr.Operands[i].Op != "" {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, " "+expr.Op+" ")
+}
@@ -0,0 +1,76 @@
+package compose
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"melodia/internal/models"
+)
+
+func playlist(id uint) models.ComposeExpression {
+	return models.ComposeExpression{Playlist: id}
+}
+
+func op(name string, operands ...models.ComposeExpression) models.ComposeExpression {
+	return models.ComposeExpression{Op: name, Operands: operands}
+}
+
+func TestCompile(t *testing.T) {
+	expr := op(OpExcept, op(OpUnion, playlist(1), playlist(2)), playlist(3))
+
+	if err := Validate(&expr); err != nil {
+		t.Fatalf("Expected valid expression, got %v", err)
+	}
+
+	query, args := Compile(&expr, []interface{}{"existing"})
+	expected := "((SELECT song_id FROM playlist_songs WHERE playlist_id = $2) UNION " +
+		"(SELECT song_id FROM playlist_songs WHERE playlist_id = $3)) EXCEPT " +
+		"(SELECT song_id FROM playlist_songs WHERE playlist_id = $4)"
+	if query != expected {
+		t.Errorf("Unexpected query:\n%s", query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"existing", uint(1), uint(2), uint(3)}) {
+		t.Errorf("Unexpected args %v", args)
+	}
+
+	if got := String(&expr); got != "(1 union 2) except 3" {
+		t.Errorf("Unexpected string %q", got)
+	}
+	if got := Playlists(&expr); !reflect.DeepEqual(got, []uint{1, 2, 3}) {
+		t.Errorf("Unexpected playlists %v", got)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	deep := playlist(1)
+	for i := 0; i < MaxDepth; i++ {
+		deep = op(OpUnion, deep, playlist(2))
+	}
+
+	many := op(OpUnion)
+	for i := 0; i <= MaxPlaylists; i++ {
+		many.Operands = append(many.Operands, playlist(uint(i+1)))
+	}
+
+	tests := []struct {
+		name     string
+		expr     models.ComposeExpression
+		contains string
+	}{
+		{"empty", models.ComposeExpression{}, "either a playlist ID or an op"},
+		{"unknown op", op("xor", playlist(1), playlist(2)), "unknown op"},
+		{"single operand", op(OpIntersect, playlist(1)), "at least two operands"},
+		{"both playlist and op", models.ComposeExpression{Playlist: 1, Op: OpUnion, Operands: []models.ComposeExpression{playlist(2), playlist(3)}}, "both"},
+		{"leaf with operands", models.ComposeExpression{Playlist: 1, Operands: []models.ComposeExpression{playlist(2)}}, "either a playlist ID or an op"},
+		{"too deep", deep, "nested"},
+		{"too many playlists", many, "more than"},
+	}
+
+	for _, test := range tests {
+		err := Validate(&test.expr)
+		if err == nil || !strings.Contains(err.Error(), test.contains) {
+			t.Errorf("%s: expected error containing %q, got %v", test.name, test.contains, err)
+		}
+	}
+}
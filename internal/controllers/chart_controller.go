@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"melodia/internal/charts"
+	"melodia/internal/models"
+	"melodia/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChartController handles chart HTTP requests
+type ChartController struct {
+	chartRepo *repositories.ChartRepository
+}
+
+// NewChartController creates a new chart controller
+func NewChartController() *ChartController {
+	return &ChartController{
+		chartRepo: repositories.NewChartRepository(),
+	}
+}
+
+// GetSongChart handles GET /charts/songs
+// @Summary Get the top songs
+// @Description Ranks songs by plays over a day, week (Monday to Sunday) or month in UTC, with the movement since the previous period. Plays count when completed or longer than 30 seconds. Charts are built from rollups refreshed every few minutes, so the latest plays may be missing.
+// @Tags charts
+// @Produce json
+// @Param period query string false "day, week or month (default week)"
+// @Param date query string false "Any date in the period, as YYYY-MM-DD (default today)"
+// @Param limit query int false "Maximum number of entries (default 50, max 100)"
+// @Success 200 {object} models.ChartResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /charts/songs [get]
+func (cc *ChartController) GetSongChart(c *gin.Context) {
+	cc.getChart(c, models.ChartSongs)
+}
+
+// GetArtistChart handles GET /charts/artists
+// @Summary Get the top artists
+// @Description Ranks artists by plays of their songs over a day, week (Monday to Sunday) or month in UTC, with the movement since the previous period. Artists are grouped ignoring case.
+// @Tags charts
+// @Produce json
+// @Param period query string false "day, week or month (default week)"
+// @Param date query string false "Any date in the period, as YYYY-MM-DD (default today)"
+// @Param limit query int false "Maximum number of entries (default 50, max 100)"
+// @Success 200 {object} models.ChartResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /charts/artists [get]
+func (cc *ChartController) GetArtistChart(c *gin.Context) {
+	cc.getChart(c, models.ChartArtists)
+}
+
+// GetPlaylistChart handles GET /charts/playlists
+// @Summary Get the top playlists
+// @Description Ranks playlists by plays made from them over a day, week (Monday to Sunday) or month in UTC, with the movement since the previous period
+// @Tags charts
+// @Produce json
+// @Param period query string false "day, week or month (default week)"
+// @Param date query string false "Any date in the period, as YYYY-MM-DD (default today)"
+// @Param limit query int false "Maximum number of entries (default 50, max 100)"
+// @Success 200 {object} models.ChartResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /charts/playlists [get]
+func (cc *ChartController) GetPlaylistChart(c *gin.Context) {
+	cc.getChart(c, models.ChartPlaylists)
+}
+
+// getChart responds with the chart of a kind for the requested period
+func (cc *ChartController) getChart(c *gin.Context, kind string) {
+	period := c.DefaultQuery("period", charts.PeriodWeek)
+
+	date := time.Now()
+	if value := c.Query("date"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Date must be formatted as YYYY-MM-DD", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		date = parsed
+	}
+
+	window, err := charts.WindowFor(period, date)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Period must be day, week or month", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 100 {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Limit must be between 1 and 100", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	entries, err := cc.chartRepo.GetChart(kind, window, limit)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve chart", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	response := models.ChartResponse{
+		Data: models.Chart{
+			Kind:    kind,
+			Period:  period,
+			Start:   window.Start.Format("2006-01-02"),
+			End:     window.End.Format("2006-01-02"),
+			Entries: entries,
+		},
+	}
+
+	c.JSON(http.StatusOK, response)
+}
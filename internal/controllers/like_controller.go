@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"melodia/internal/models"
+	"melodia/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultLikedSongsLimit is the page size of liked songs unless requested otherwise
+const defaultLikedSongsLimit = 50
+
+// LikeController handles the likes of the requesting listener
+type LikeController struct {
+	likeRepo *repositories.LikeRepository
+}
+
+// NewLikeController creates a new like controller
+func NewLikeController() *LikeController {
+	return &LikeController{
+		likeRepo: repositories.NewLikeRepository(),
+	}
+}
+
+// LikeSong handles PUT /me/likes/songs/{id}
+// @Summary Like a song
+// @Description Adds a song to the liked songs of the listener identified by the X-Listener-ID header. Liking a song twice keeps the first like.
+// @Tags likes
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param id path int true "Song ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /me/likes/songs/{id} [put]
+func (lc *LikeController) LikeSong(c *gin.Context) {
+	listenerID, id, ok := likeTarget(c, "Invalid song ID")
+	if !ok {
+		return
+	}
+
+	if err := lc.likeRepo.LikeSong(listenerID, id); err != nil {
+		if err.Error() == "song not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Song not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to like song", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// UnlikeSong handles DELETE /me/likes/songs/{id}
+// @Summary Unlike a song
+// @Description Removes a song from the liked songs of the listener identified by the X-Listener-ID header. Songs that were not liked are ignored.
+// @Tags likes
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param id path int true "Song ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /me/likes/songs/{id} [delete]
+func (lc *LikeController) UnlikeSong(c *gin.Context) {
+	listenerID, id, ok := likeTarget(c, "Invalid song ID")
+	if !ok {
+		return
+	}
+
+	if err := lc.likeRepo.UnlikeSong(listenerID, id); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to unlike song", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// LikePlaylist handles PUT /me/likes/playlists/{id}
+// @Summary Like a playlist
+// @Description Records that the listener identified by the X-Listener-ID header likes a playlist. Liking a playlist twice keeps the first like.
+// @Tags likes
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param id path int true "Playlist ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /me/likes/playlists/{id} [put]
+func (lc *LikeController) LikePlaylist(c *gin.Context) {
+	listenerID, id, ok := likeTarget(c, "Invalid playlist ID")
+	if !ok {
+		return
+	}
+
+	if err := lc.likeRepo.LikePlaylist(listenerID, id); err != nil {
+		if err.Error() == "playlist not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to like playlist", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// UnlikePlaylist handles DELETE /me/likes/playlists/{id}
+// @Summary Unlike a playlist
+// @Description Removes the like of the listener identified by the X-Listener-ID header from a playlist. Playlists that were not liked are ignored.
+// @Tags likes
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param id path int true "Playlist ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /me/likes/playlists/{id} [delete]
+func (lc *LikeController) UnlikePlaylist(c *gin.Context) {
+	listenerID, id, ok := likeTarget(c, "Invalid playlist ID")
+	if !ok {
+		return
+	}
+
+	if err := lc.likeRepo.UnlikePlaylist(listenerID, id); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to unlike playlist", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetLikedSongs handles GET /me/likes/songs
+// @Summary Get the liked songs
+// @Description Returns the songs liked by the listener identified by the X-Listener-ID header as a read-only virtual playlist named "Liked songs", most recently liked first, with added_at set to the like time. Pass next_cursor back as cursor to get the following page.
+// @Tags likes
+// @Produce json
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param limit query int false "Maximum number of songs (default 50, max 100)"
+// @Param cursor query string false "Cursor from a previous page"
+// @Success 200 {object} models.LikedSongsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /me/likes/songs [get]
+func (lc *LikeController) GetLikedSongs(c *gin.Context) {
+	listenerID, ok := requireListener(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLikedSongsLimit)))
+	if err != nil || limit < 1 || limit > 100 {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Limit must be between 1 and 100", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	var before *time.Time
+	var beforeID int64
+	if cursor := c.Query("cursor"); cursor != "" {
+		likedAt, id, err := decodePlayCursor(cursor)
+		if err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid cursor", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		before, beforeID = &likedAt, id
+	}
+
+	total, first, last, err := lc.likeRepo.LikedSongsSummary(listenerID)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve liked songs", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	// One extra song tells whether there is a next page
+	songs, err := lc.likeRepo.GetLikedSongs(listenerID, before, beforeID, limit+1)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve liked songs", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	// The playlist exists from the first like and changes with every like
+	playlist := models.Playlist{
+		Name:    models.LikedSongsPlaylistName,
+		Creator: listenerID,
+		Songs:   songs,
+	}
+	if first != nil {
+		playlist.CreatedAt, playlist.UpdatedAt = *first, *last
+	}
+
+	response := models.LikedSongsResponse{
+		Data:  playlist,
+		Total: total,
+	}
+	if len(songs) > limit {
+		response.Data.Songs = songs[:limit]
+		last := songs[limit-1]
+		response.NextCursor = encodePlayCursor(last.AddedAt, int64(last.ID))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// likeTarget returns the listener of a request and the ID in its path,
+// responding with 401 or 400 when either is missing or invalid
+func likeTarget(c *gin.Context, invalidID string) (string, uint, bool) {
+	listenerID, ok := requireListener(c)
+	if !ok {
+		return "", 0, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, invalidID, c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return "", 0, false
+	}
+
+	return listenerID, uint(id), true
+}
@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"melodia/internal/listenbrainz"
+	"melodia/internal/models"
+	"melodia/internal/normalize"
+	"melodia/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxListenSubmissionSize is the largest submission body accepted, in bytes
+const maxListenSubmissionSize = listenbrainz.MaxListensPerRequest * listenbrainz.MaxListenSize
+
+// ListenBrainzController handles the ListenBrainz compatible API and its tokens
+type ListenBrainzController struct {
+	tokenRepo *repositories.ListenTokenRepository
+	playRepo  *repositories.PlayRepository
+	songRepo  *repositories.SongRepository
+}
+
+// NewListenBrainzController creates a new ListenBrainz controller
+func NewListenBrainzController() *ListenBrainzController {
+	return &ListenBrainzController{
+		tokenRepo: repositories.NewListenTokenRepository(),
+		playRepo:  repositories.NewPlayRepository(),
+		songRepo:  repositories.NewSongRepository(),
+	}
+}
+
+// CreateListenToken handles POST /listenbrainz/tokens
+// @Summary Create a ListenBrainz token
+// @Description Creates a token for the listener identified by the X-Listener-ID header. Players that scrobble to ListenBrainz record plays here when pointed at this server with the token. The token is only returned now. With create_missing, listens of songs not in the catalog add them to it; otherwise they are ignored.
+// @Tags listenbrainz
+// @Accept json
+// @Produce json
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param options body models.CreateListenTokenRequest false "Whether unknown songs are created"
+// @Success 201 {object} models.ListenTokenResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /listenbrainz/tokens [post]
+func (lbc *ListenBrainzController) CreateListenToken(c *gin.Context) {
+	listenerID, ok := requireListener(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateListenTokenRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+	}
+
+	value, err := listenbrainz.NewToken()
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to create token", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	token := models.ListenToken{
+		ListenerID:    listenerID,
+		CreateMissing: req.CreateMissing,
+	}
+	if err := lbc.tokenRepo.CreateToken(&token, listenbrainz.HashToken(value)); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to create token", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	token.Token = value
+
+	response := models.ListenTokenResponse{
+		Data: token,
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// DeleteListenToken handles DELETE /listenbrainz/tokens/{id}
+// @Summary Revoke a ListenBrainz token
+// @Description Revokes a token of the listener identified by the X-Listener-ID header. Plays already recorded with it are kept.
+// @Tags listenbrainz
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param id path int true "Token ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /listenbrainz/tokens/{id} [delete]
+func (lbc *ListenBrainzController) DeleteListenToken(c *gin.Context) {
+	listenerID, ok := requireListener(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid token ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if err := lbc.tokenRepo.DeleteToken(listenerID, uint(id)); err != nil {
+		if err.Error() == "token not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Token not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to delete token", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ValidateToken handles GET /1/validate-token
+// @Summary Validate a ListenBrainz token
+// @Description ListenBrainz compatible token check. The token is read from an "Authorization: Token <token>" header or the token query parameter. Unknown tokens are reported with valid false, as ListenBrainz does; user_name is the listener of a valid token.
+// @Tags listenbrainz
+// @Produce json
+// @Param Authorization header string false "Token <token>"
+// @Param token query string false "Token, when not sent in the Authorization header"
+// @Success 200 {object} models.ListenBrainzTokenResponse
+// @Failure 400 {object} models.ListenBrainzError
+// @Router /1/validate-token [get]
+func (lbc *ListenBrainzController) ValidateToken(c *gin.Context) {
+	value := listenbrainz.TokenFromHeader(c.GetHeader("Authorization"))
+	if value == "" {
+		value = strings.TrimSpace(c.Query("token"))
+	}
+	if value == "" {
+		c.JSON(http.StatusBadRequest, models.ListenBrainzError{Code: 400, Error: "You need to provide an Authorization token."})
+		return
+	}
+
+	token, err := lbc.tokenRepo.UseToken(listenbrainz.HashToken(value))
+	if err != nil {
+		if err.Error() == "token not found" {
+			c.JSON(http.StatusOK, models.ListenBrainzTokenResponse{Code: 200, Message: "Token invalid.", Valid: false})
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ListenBrainzError{Code: 400, Error: "Failed to validate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ListenBrainzTokenResponse{Code: 200, Message: "Token valid.", Valid: true, UserName: token.ListenerID})
+}
+
+// SubmitListens handles POST /1/submit-listens
+// @Summary Submit ListenBrainz listens
+// @Description ListenBrainz compatible listen submission, authenticated with an "Authorization: Token <token>" header. single and import listens are matched to songs by normalized title and artist and recorded as completed plays of the token's listener, lasting the duration in additional_info or else the song's. A listen already recorded for the same song and start time is skipped, so resubmitting is safe. Listens matching no song create it when the token allows it, and are counted as unmatched otherwise. playing_now listens are validated but not recorded. The whole submission is rejected when any listen is invalid.
+// @Tags listenbrainz
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Token <token>"
+// @Param submission body listenbrainz.Submission true "Listens"
+// @Success 200 {object} models.ListenBrainzSubmitResponse
+// @Failure 400 {object} models.ListenBrainzError
+// @Failure 401 {object} models.ListenBrainzError
+// @Router /1/submit-listens [post]
+func (lbc *ListenBrainzController) SubmitListens(c *gin.Context) {
+	value := listenbrainz.TokenFromHeader(c.GetHeader("Authorization"))
+	if value == "" {
+		c.JSON(http.StatusUnauthorized, models.ListenBrainzError{Code: 401, Error: "You need to provide an Authorization header."})
+		return
+	}
+	token, err := lbc.tokenRepo.UseToken(listenbrainz.HashToken(value))
+	if err != nil {
+		if err.Error() == "token not found" {
+			c.JSON(http.StatusUnauthorized, models.ListenBrainzError{Code: 401, Error: "Invalid authorization token."})
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ListenBrainzError{Code: 400, Error: "Failed to validate token"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxListenSubmissionSize)
+	var submission listenbrainz.Submission
+	if err := c.ShouldBindJSON(&submission); err != nil {
+		c.JSON(http.StatusBadRequest, models.ListenBrainzError{Code: 400, Error: "Invalid JSON document submitted."})
+		return
+	}
+	if err := listenbrainz.Validate(&submission, time.Now(), maxPlayClockSkew); err != nil {
+		c.JSON(http.StatusBadRequest, models.ListenBrainzError{Code: 400, Error: err.Error()})
+		return
+	}
+
+	response := models.ListenBrainzSubmitResponse{Status: "ok"}
+	if submission.ListenType == listenbrainz.ListenTypePlayingNow {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// Imports often repeat songs, so each title and artist is matched once
+	matched := map[string]*models.Song{}
+	for _, listen := range submission.Payload {
+		metadata := listen.TrackMetadata
+		key := normalize.SongKey(metadata.TrackName, metadata.ArtistName)
+		song, seen := matched[key]
+		if !seen {
+			song, err = lbc.matchListen(metadata, key, token.CreateMissing)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, models.ListenBrainzError{Code: 400, Error: "Failed to record listens"})
+				return
+			}
+			matched[key] = song
+		}
+		if song == nil {
+			response.Unmatched++
+			continue
+		}
+
+		msPlayed := metadata.DurationMs()
+		if msPlayed == 0 {
+			msPlayed = song.DurationMs
+		}
+		play := models.Play{
+			ListenerID: token.ListenerID,
+			SongID:     song.ID,
+			StartedAt:  time.Unix(*listen.ListenedAt, 0),
+			MsPlayed:   int(msPlayed),
+			Completed:  true,
+		}
+		recorded, err := lbc.playRepo.RecordListen(&play)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ListenBrainzError{Code: 400, Error: "Failed to record listens"})
+			return
+		}
+		if recorded {
+			response.Recorded++
+		} else {
+			response.Duplicates++
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// matchListen finds the song of a listen by its normalized title and artist,
+// creating it when createMissing is set. It returns nil when nothing matches.
+func (lbc *ListenBrainzController) matchListen(metadata *listenbrainz.TrackMetadata, key string, createMissing bool) (*models.Song, error) {
+	song, err := lbc.songRepo.FindSongByNormalizedKey(key)
+	if err == nil {
+		return song, nil
+	}
+	if err.Error() != "song not found" {
+		return nil, err
+	}
+	if !createMissing {
+		return nil, nil
+	}
+
+	song = &models.Song{
+		Title:      strings.TrimSpace(metadata.TrackName),
+		Artist:     strings.TrimSpace(metadata.ArtistName),
+		Album:      strings.TrimSpace(metadata.ReleaseName),
+		DurationMs: metadata.DurationMs(),
+	}
+	if err := lbc.songRepo.CreateSong(song); err != nil {
+		return nil, err
+	}
+	return song, nil
+}
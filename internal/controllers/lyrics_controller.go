@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"melodia/internal/lyrics"
+	"melodia/internal/models"
+	"melodia/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxLyricsSize is the largest lyrics document accepted, in bytes
+	maxLyricsSize = 1 << 20
+
+	mimeLRC  = "application/x-lrc"
+	mimeText = "text/plain"
+)
+
+// LyricsController handles lyrics-related HTTP requests
+type LyricsController struct {
+	lyricsRepo *repositories.LyricsRepository
+}
+
+// NewLyricsController creates a new lyrics controller
+func NewLyricsController() *LyricsController {
+	return &LyricsController{
+		lyricsRepo: repositories.NewLyricsRepository(),
+	}
+}
+
+// UpdateLyrics handles PUT /songs/{id}/lyrics
+// @Summary Set the lyrics of a song
+// @Description Accepts plain text or time-synced LRC lyrics, either as JSON or as a raw text/plain or application/x-lrc body. LRC timestamps are validated.
+// @Tags songs
+// @Accept json,plain,application/x-lrc
+// @Produce json
+// @Param id path int true "Song ID"
+// @Param lyrics body models.UpdateLyricsRequest true "Lyrics"
+// @Param format query string false "Lyrics format (lrc or plain), auto-detected when omitted"
+// @Success 200 {object} models.LyricsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /songs/{id}/lyrics [put]
+func (lc *LyricsController) UpdateLyrics(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid song ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxLyricsSize)
+
+	var raw string
+	format := c.Query("format")
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case mimeText, mimeLRC, "text/x-lrc":
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		raw = string(body)
+		if format == "" && mediaType != mimeText {
+			format = lyrics.FormatLRC
+		}
+	default:
+		var req models.UpdateLyricsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		raw = req.Lyrics
+		if format == "" {
+			format = req.Format
+		}
+	}
+
+	if format != "" && format != lyrics.FormatLRC && format != lyrics.FormatPlain {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Format must be 'lrc' or 'plain'", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	parsed, err := lyrics.Parse(raw, format)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid lyrics: "+err.Error(), c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	songLyrics := &models.Lyrics{
+		SongID:    uint(id),
+		Format:    parsed.Format,
+		Raw:       raw,
+		PlainText: parsed.Text(),
+	}
+
+	if err := lc.lyricsRepo.SaveLyrics(songLyrics); err != nil {
+		if err.Error() == "song not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Song not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to save lyrics", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	applyParsedLyrics(songLyrics, parsed)
+
+	response := models.LyricsResponse{
+		Data: *songLyrics,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetLyrics handles GET /songs/{id}/lyrics
+// @Summary Retrieve the lyrics of a song
+// @Description Returns structured lines with offsets as JSON, the raw LRC document (Accept: application/x-lrc) or plain text (Accept: text/plain)
+// @Tags songs
+// @Produce json,application/x-lrc,plain
+// @Param id path int true "Song ID"
+// @Success 200 {object} models.LyricsResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 406 {object} models.ErrorResponse
+// @Router /songs/{id}/lyrics [get]
+func (lc *LyricsController) GetLyrics(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid song ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	songLyrics, err := lc.lyricsRepo.GetLyricsBySongID(uint(id))
+	if err != nil {
+		if err.Error() == "lyrics not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Lyrics not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve lyrics", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	switch c.NegotiateFormat(gin.MIMEJSON, mimeLRC, "text/x-lrc", mimeText) {
+	case mimeLRC, "text/x-lrc":
+		if songLyrics.Format != lyrics.FormatLRC {
+			errorResp := models.NewErrorResponse("Not Acceptable", 406, "Lyrics for this song are not time-synced", c.Request.URL.Path)
+			c.JSON(http.StatusNotAcceptable, errorResp)
+			return
+		}
+		c.Header("Content-Disposition", "inline; filename=\""+idStr+".lrc\"")
+		c.Data(http.StatusOK, mimeLRC+"; charset=utf-8", []byte(songLyrics.Raw))
+	case mimeText:
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(songLyrics.PlainText))
+	default:
+		parsed, err := lyrics.Parse(songLyrics.Raw, songLyrics.Format)
+		if err != nil {
+			errorResp := models.NewErrorResponse("Internal Server Error", 500, "Stored lyrics could not be parsed", c.Request.URL.Path)
+			c.JSON(http.StatusInternalServerError, errorResp)
+			return
+		}
+		applyParsedLyrics(songLyrics, parsed)
+
+		response := models.LyricsResponse{
+			Data: *songLyrics,
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// DeleteLyrics handles DELETE /songs/{id}/lyrics
+// @Summary Delete the lyrics of a song
+// @Description Remove the lyrics attached to a song
+// @Tags songs
+// @Param id path int true "Song ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} models.ErrorResponse
+// @Router /songs/{id}/lyrics [delete]
+func (lc *LyricsController) DeleteLyrics(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid song ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if err := lc.lyricsRepo.DeleteLyrics(uint(id)); err != nil {
+		if err.Error() == "lyrics not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Lyrics not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to delete lyrics", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SearchLyrics handles GET /lyrics/search
+// @Summary Search songs by lyrics
+// @Description Full text search over song lyrics, best matches first
+// @Tags songs
+// @Produce json
+// @Param q query string true "Text to search for"
+// @Param limit query int false "Maximum number of results (default 20, max 100)"
+// @Success 200 {object} models.LyricsSearchResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /lyrics/search [get]
+func (lc *LyricsController) SearchLyrics(c *gin.Context) {
+	text := c.Query("q")
+	if text == "" {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Missing required query parameter: q", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Limit must be between 1 and 100", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	results, err := lc.lyricsRepo.SearchLyrics(text, limit)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to search lyrics", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	response := models.LyricsSearchResponse{
+		Data: results,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// applyParsedLyrics fills the structured lines and metadata of a lyrics model
+func applyParsedLyrics(songLyrics *models.Lyrics, parsed *lyrics.Lyrics) {
+	songLyrics.Metadata = parsed.Metadata
+	songLyrics.Lines = make([]models.LyricsLine, 0, len(parsed.Lines))
+	for _, line := range parsed.Lines {
+		entry := models.LyricsLine{Text: line.Text}
+		if line.TimeMs != nil {
+			entry.TimeMs = line.TimeMs
+			entry.Timestamp = lyrics.FormatTimestamp(*line.TimeMs)
+		}
+		songLyrics.Lines = append(songLyrics.Lines, entry)
+	}
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"melodia/internal/models"
+	"melodia/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxPlayClockSkew is how far in the future a play can start, to allow for
+	// clients with a fast clock
+	maxPlayClockSkew = 5 * time.Minute
+	// defaultPlaysLimit is the page size of the listening history unless requested otherwise
+	defaultPlaysLimit = 50
+)
+
+// earliestPlay is the earliest start time accepted for a play
+var earliestPlay = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// PlayController handles listening history HTTP requests
+type PlayController struct {
+	playRepo *repositories.PlayRepository
+}
+
+// NewPlayController creates a new play controller
+func NewPlayController() *PlayController {
+	return &PlayController{
+		playRepo: repositories.NewPlayRepository(),
+	}
+}
+
+// CreatePlay handles POST /plays
+// @Summary Record a play
+// @Description Records that the listener identified by the X-Listener-ID header played a song, optionally from a playlist. started_at defaults to now. Plays cannot be changed afterwards and stay in the history when the song is deleted.
+// @Tags plays
+// @Accept json
+// @Produce json
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param play body models.CreatePlayRequest true "Play event"
+// @Success 201 {object} models.PlayResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /plays [post]
+func (plc *PlayController) CreatePlay(c *gin.Context) {
+	listenerID, ok := requireListener(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreatePlayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if req.Completed && req.Skipped {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "A play cannot be both completed and skipped", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	now := time.Now()
+	startedAt := now
+	if req.StartedAt != nil {
+		startedAt = *req.StartedAt
+	}
+	if startedAt.After(now.Add(maxPlayClockSkew)) || startedAt.Before(earliestPlay) {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "started_at must be between 2000 and now", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	play := models.Play{
+		ListenerID: listenerID,
+		SongID:     req.SongID,
+		PlaylistID: req.PlaylistID,
+		StartedAt:  startedAt,
+		MsPlayed:   req.MsPlayed,
+		Completed:  req.Completed,
+		Skipped:    req.Skipped,
+	}
+
+	if err := plc.playRepo.CreatePlay(&play); err != nil {
+		switch err.Error() {
+		case "song not found":
+			errorResp := models.NewErrorResponse("Not Found", 404, "Song not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+		case "playlist not found":
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+		default:
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to record play", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+		}
+		return
+	}
+
+	response := models.PlayResponse{
+		Data: play,
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetPlays handles GET /plays
+// @Summary Get the listening history
+// @Description Returns the plays of the listener identified by the X-Listener-ID header, most recent first. Pass next_cursor back as cursor to get the following page.
+// @Tags plays
+// @Produce json
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param limit query int false "Maximum number of plays (default 50, max 100)"
+// @Param cursor query string false "Cursor from a previous page"
+// @Success 200 {object} models.PlaysResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /plays [get]
+func (plc *PlayController) GetPlays(c *gin.Context) {
+	listenerID, ok := requireListener(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPlaysLimit)))
+	if err != nil || limit < 1 || limit > 100 {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Limit must be between 1 and 100", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	var before *time.Time
+	var beforeID int64
+	if cursor := c.Query("cursor"); cursor != "" {
+		startedAt, id, err := decodePlayCursor(cursor)
+		if err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid cursor", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		before, beforeID = &startedAt, id
+	}
+
+	// One extra play tells whether there is a next page
+	plays, err := plc.playRepo.GetPlays(listenerID, before, beforeID, limit+1)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve plays", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	response := models.PlaysResponse{
+		Data: plays,
+	}
+	if len(plays) > limit {
+		response.Data = plays[:limit]
+		last := plays[limit-1]
+		response.NextCursor = encodePlayCursor(last.StartedAt, last.ID)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// requireListener returns the listener identity of a request, responding with
+// 401 when it is missing
+func requireListener(c *gin.Context) (string, bool) {
+	listenerID := strings.TrimSpace(c.GetHeader(models.ListenerHeader))
+	if listenerID == "" || len(listenerID) > 255 {
+		errorResp := models.NewErrorResponse("Unauthorized", 401, "The "+models.ListenerHeader+" header must identify the listener", c.Request.URL.Path)
+		c.JSON(http.StatusUnauthorized, errorResp)
+		return "", false
+	}
+	return listenerID, true
+}
+
+// encodePlayCursor returns an opaque cursor pointing after a play
+func encodePlayCursor(startedAt time.Time, id int64) string {
+	value := startedAt.UTC().Format(time.RFC3339Nano) + "," + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+// decodePlayCursor reverses encodePlayCursor
+func decodePlayCursor(cursor string) (time.Time, int64, error) {
+	value, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	startedAt, idStr, _ := strings.Cut(string(value), ",")
+	t, err := time.Parse(time.RFC3339Nano, startedAt)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return t, id, nil
+}
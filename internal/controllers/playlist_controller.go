@@ -1,15 +1,29 @@
 package controllers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"melodia/internal/compose"
+	"melodia/internal/imaging"
 	"melodia/internal/models"
 	"melodia/internal/repositories"
+	"melodia/internal/shuffle"
+	"melodia/internal/smartplaylist"
+	"melodia/internal/storage"
+	"melodia/internal/tabular"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxCoverSize is the largest cover image accepted, in bytes
+const maxCoverSize = 10 << 20
+
 // PlaylistController handles playlist-related HTTP requests
 type PlaylistController struct {
 	playlistRepo *repositories.PlaylistRepository
@@ -24,7 +38,7 @@ func NewPlaylistController() *PlaylistController {
 
 // CreatePlaylist handles POST /playlists
 // @Summary Create a new playlist
-// @Description Playlist created successfully
+// @Description Create an empty playlist, or a smart playlist when rules are given. The songs of a smart playlist are the catalog songs matching its rules when the playlist is read, and cannot be added by hand. Rules combine conditions on title, artist, album or genre (eq, neq, contains, not_contains, in, not_in, case-insensitive), year, duration_ms or track_number (eq, neq, lt, lte, gt, gte), created_at (within_days, older_than_days) and has_audio (eq) with all and any; sort is created_at, title, artist, year or duration_ms, prefixed with - for descending (default -created_at); limit is 1 to 1000 (default 1000).
 // @Tags playlists
 // @Accept json
 // @Produce json
@@ -61,12 +75,21 @@ func (pc *PlaylistController) CreatePlaylist(c *gin.Context) {
 		return
 	}
 
+	if req.Rules != nil {
+		if err := smartplaylist.Validate(req.Rules); err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid rules: "+err.Error(), c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+	}
+
 	// Create playlist object
 	playlist := models.Playlist{
 		Name:        req.Name,
 		Description: req.Description,
 		IsPublished: false, // Playlists are created as unpublished by default
 		PublishedAt: nil,   // Not published yet
+		Rules:       req.Rules,
 		Songs:       []models.PlaylistSong{},
 	}
 
@@ -77,6 +100,17 @@ func (pc *PlaylistController) CreatePlaylist(c *gin.Context) {
 		return
 	}
 
+	// Smart playlists start with the songs already matching their rules
+	if playlist.Rules != nil {
+		created, err := pc.playlistRepo.GetPlaylistByID(playlist.ID)
+		if err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve created playlist", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		playlist = *created
+	}
+
 	response := models.PlaylistResponse{
 		Data: playlist,
 	}
@@ -125,12 +159,18 @@ func (pc *PlaylistController) GetPlaylists(c *gin.Context) {
 
 // GetPlaylist handles GET /playlists/{id}
 // @Summary Retrieve a playlist by ID
-// @Description Get a specific playlist by its ID with songs ordered by addedAt desc
+// @Description Get a specific playlist by its ID with songs ordered by addedAt desc. With Accept: text/csv or application/x-ndjson (or format=csv|ndjson) the songs are streamed row by row as a file download.
+// @Description With shuffle=true the songs come in a random order that keeps songs by the same artist as far apart as possible. The order depends only on the songs and the seed, so devices passing the same seed agree; without a seed the order stored with POST /playlists/{id}/shuffle is used, or a random seed when none is stored. The seed used is returned in shuffle_seed. Shuffling is only available as JSON.
 // @Tags playlists
-// @Produce json
+// @Produce json,text/csv,application/x-ndjson
 // @Param id path int true "Playlist ID"
+// @Param format query string false "json (default), csv or ndjson; overrides the Accept header"
+// @Param shuffle query bool false "Shuffle the songs keeping artists apart"
+// @Param seed query string false "Seed of the shuffle, up to 64 bytes"
 // @Success 200 {object} models.PlaylistResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 406 {object} models.ErrorResponse
 // @Router /playlists/{id} [get]
 func (pc *PlaylistController) GetPlaylist(c *gin.Context) {
 	idStr := c.Param("id")
@@ -141,6 +181,33 @@ func (pc *PlaylistController) GetPlaylist(c *gin.Context) {
 		return
 	}
 
+	shuffled, err := strconv.ParseBool(c.DefaultQuery("shuffle", "false"))
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "shuffle must be true or false", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	seed := c.Query("seed")
+	if len(seed) > shuffle.MaxSeedLength {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "seed must be at most 64 bytes", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	format, ok := negotiateRowFormat(c)
+	if !ok {
+		return
+	}
+	if format != tabular.FormatJSON {
+		if shuffled {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Shuffled playlists are only available as JSON", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		pc.streamPlaylistSongs(c, uint(id), format)
+		return
+	}
+
 	// Get from database by ID
 	playlist, err := pc.playlistRepo.GetPlaylistByID(uint(id))
 	if err != nil {
@@ -154,13 +221,424 @@ func (pc *PlaylistController) GetPlaylist(c *gin.Context) {
 		return
 	}
 
+	if shuffled {
+		var stored []uint
+		if seed == "" {
+			if seed, stored, err = pc.playlistRepo.GetPlaylistShuffle(uint(id)); err != nil {
+				errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve playlist", c.Request.URL.Path)
+				c.JSON(http.StatusBadRequest, errorResp)
+				return
+			}
+		}
+		if stored != nil {
+			reorderPlaylist(playlist, seed, shuffle.Restore(playlistSongIDs(playlist), stored))
+		} else {
+			if seed == "" {
+				seed = shuffle.NewSeed()
+				// A fresh order on every request
+				c.Header("Cache-Control", "no-store")
+			}
+			reorderPlaylist(playlist, seed, shuffle.Spread(playlistArtists(playlist), seed))
+		}
+	}
+
+	response := models.PlaylistResponse{
+		Data: *playlist,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ShufflePlaylist handles POST /playlists/{id}/shuffle
+// @Summary Store a shuffled order of a playlist
+// @Description Shuffles the songs of a playlist keeping songs by the same artist as far apart as possible, and stores the order so that GET /playlists/{id}?shuffle=true returns it on every device. Songs added later are played after the shuffled ones until the playlist is shuffled again. The seed is random unless one is given.
+// @Tags playlists
+// @Accept json
+// @Produce json
+// @Param id path int true "Playlist ID"
+// @Param options body models.ShufflePlaylistRequest false "Seed of the shuffle, up to 64 bytes"
+// @Success 200 {object} models.PlaylistResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /playlists/{id}/shuffle [post]
+func (pc *PlaylistController) ShufflePlaylist(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid playlist ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	var req models.ShufflePlaylistRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+	}
+	seed := req.Seed
+	if seed == "" {
+		seed = shuffle.NewSeed()
+	}
+
+	playlist, err := pc.playlistRepo.GetPlaylistByID(uint(id))
+	if err != nil {
+		if err.Error() == "playlist not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to shuffle playlist", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	reorderPlaylist(playlist, seed, shuffle.Spread(playlistArtists(playlist), seed))
+	if err := pc.playlistRepo.SetPlaylistShuffle(uint(id), seed, playlistSongIDs(playlist)); err != nil {
+		if err.Error() == "playlist not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to shuffle playlist", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	response := models.PlaylistResponse{
+		Data: *playlist,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DeletePlaylistShuffle handles DELETE /playlists/{id}/shuffle
+// @Summary Forget the stored shuffle of a playlist
+// @Description Removes the stored shuffled order, so GET /playlists/{id}?shuffle=true picks a random seed again
+// @Tags playlists
+// @Param id path int true "Playlist ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /playlists/{id}/shuffle [delete]
+func (pc *PlaylistController) DeletePlaylistShuffle(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid playlist ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if err := pc.playlistRepo.DeletePlaylistShuffle(uint(id)); err != nil {
+		if err.Error() == "shuffle not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Shuffle not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to delete shuffle", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// DuplicatePlaylist handles POST /playlists/{id}/duplicate
+// @Summary Duplicate (fork) a playlist
+// @Description Copies the name, description and every song of a playlist into a new unpublished playlist that records the original in forked_from. Entries keep their added_at by default; with added_at=reset they all get the time of the copy. Either way the songs keep their order.
+// @Tags playlists
+// @Accept json
+// @Produce json
+// @Param id path int true "Playlist ID"
+// @Param options body models.DuplicatePlaylistRequest false "Name of the copy and how to set added_at (preserve or reset)"
+// @Success 201 {object} models.PlaylistResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /playlists/{id}/duplicate [post]
+func (pc *PlaylistController) DuplicatePlaylist(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid playlist ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	var req models.DuplicatePlaylistRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+	}
+
+	switch req.AddedAt {
+	case "", models.AddedAtPreserve, models.AddedAtReset:
+	default:
+		errorResp := models.NewErrorResponse("Bad Request", 400, "added_at must be preserve or reset", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	playlist, err := pc.playlistRepo.DuplicatePlaylist(uint(id), strings.TrimSpace(req.Name), req.AddedAt == models.AddedAtReset)
+	if err != nil {
+		if err.Error() == "playlist not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to duplicate playlist", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
 	response := models.PlaylistResponse{
 		Data: *playlist,
 	}
 
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetPlaylistForks handles GET /playlists/{id}/forks
+// @Summary Retrieve the fork tree of a playlist
+// @Description Returns the playlist and every playlist duplicated from it, directly or from one of its forks, as a tree with fork counts
+// @Tags playlists
+// @Produce json
+// @Param id path int true "Playlist ID"
+// @Success 200 {object} models.PlaylistForkTreeResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /playlists/{id}/forks [get]
+func (pc *PlaylistController) GetPlaylistForks(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid playlist ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	tree, err := pc.playlistRepo.GetForkTree(uint(id))
+	if err != nil {
+		if err.Error() == "playlist not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve forks", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	response := models.PlaylistForkTreeResponse{
+		Data: *tree,
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
+// GetPlaylistStats handles GET /playlists/{id}/stats
+// @Summary Get the statistics of a playlist
+// @Description Returns the number of entries, total and average duration, the share of entries by artist, decade and genre, entries added per week (Monday to Sunday, UTC) with a running total, and how many of its songs also appear in another published playlist. Everything is computed by the database from one snapshot. Songs of smart playlists count as added when they entered the catalog. Responses carry an ETag and may be cached for 5 minutes, by shared caches only when the playlist is published; send If-None-Match to get 304 Not Modified when nothing changed.
+// @Tags playlists
+// @Produce json
+// @Param id path int true "Playlist ID"
+// @Success 200 {object} models.PlaylistStatsResponse
+// @Success 304 "Not Modified"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /playlists/{id}/stats [get]
+func (pc *PlaylistController) GetPlaylistStats(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid playlist ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	stats, err := pc.playlistRepo.GetPlaylistStats(uint(id))
+	if err != nil {
+		if err.Error() == "playlist not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve playlist stats", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	body, err := json.Marshal(models.PlaylistStatsResponse{Data: *stats})
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve playlist stats", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	// Stats change with any song or published playlist, so the ETag is a hash of
+	// the response rather than of something cheaper to check
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	// Shared caches must not keep the stats of a playlist that is not public
+	if stats.IsPublished {
+		c.Header("Cache-Control", "public, max-age=300")
+	} else {
+		c.Header("Cache-Control", "private, max-age=300")
+	}
+	if matchesETag(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// ComposePlaylist handles POST /playlists/compose
+// @Summary Compose a playlist from others
+// @Description Evaluates a set expression over playlists, e.g. {"op":"except","operands":[{"op":"union","operands":[{"playlist":1},{"playlist":2}]},{"playlist":3}]}. Operators are union, intersect and except, applied left to right, and songs appear once. Songs follow the order of the leftmost playlist containing them. Returns a preview unless materialize is true, in which case the result is saved as a new unpublished playlist with the given name and description. Smart playlists cannot be operands.
+// @Tags playlists
+// @Accept json
+// @Produce json
+// @Param composition body models.ComposePlaylistRequest true "Expression and what to do with the result"
+// @Success 200 {object} models.ComposePreviewResponse
+// @Success 201 {object} models.PlaylistResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /playlists/compose [post]
+func (pc *PlaylistController) ComposePlaylist(c *gin.Context) {
+	var req models.ComposePlaylistRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if err := compose.Validate(req.Expression); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid expression: "+err.Error(), c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if req.Materialize {
+		if req.Name == "" || req.Description == "" {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Name and description are required", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		if len(req.Description) < 50 {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Description must be at least 50 characters long", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		if len(req.Description) > 255 {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Description cannot exceed 255 characters", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+	}
+
+	missing, err := pc.playlistRepo.MissingPlaylists(compose.Playlists(req.Expression))
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to compose playlists", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	if len(missing) > 0 {
+		errorResp := models.NewErrorResponse("Not Found", 404, "Playlists not found: "+joinPlaylistIDs(missing), c.Request.URL.Path)
+		c.JSON(http.StatusNotFound, errorResp)
+		return
+	}
+
+	// Smart playlists have no stored songs to combine
+	smart, err := pc.playlistRepo.SmartPlaylists(compose.Playlists(req.Expression))
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to compose playlists", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	if len(smart) > 0 {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Smart playlists cannot be composed: "+joinPlaylistIDs(smart), c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if !req.Materialize {
+		songs, err := pc.playlistRepo.ComposePlaylistSongs(req.Expression)
+		if err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to compose playlists", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+
+		response := models.ComposePreviewResponse{
+			Data: models.ComposePreview{
+				Expression: compose.String(req.Expression),
+				SongCount:  len(songs),
+				Songs:      songs,
+			},
+		}
+
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	playlist := &models.Playlist{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	playlist, err = pc.playlistRepo.ComposePlaylist(playlist, req.Expression)
+	if err != nil {
+		if err.Error() == "playlist not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to compose playlists", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	response := models.PlaylistResponse{
+		Data: *playlist,
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// streamPlaylistSongs writes the songs of a playlist as a CSV or NDJSON download
+func (pc *PlaylistController) streamPlaylistSongs(c *gin.Context, id uint, format string) {
+	playlist, err := pc.playlistRepo.GetPlaylistWithoutSongs(id)
+	if err != nil {
+		if err.Error() == "playlist not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve playlist", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	err = streamRows(c, format, exportFilename(playlist.Name, format), playlistSongCSVHeader, func(emit func(interface{}, []string) error) error {
+		position := 0
+		return pc.playlistRepo.EachPlaylistSong(id, func(song *models.PlaylistSong) error {
+			position++
+			return emit(song, playlistSongRecord(position, song))
+		})
+	})
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve playlist", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+	}
+}
+
 // DeletePlaylist handles DELETE /playlists/{id}
 // @Summary Delete a playlist by ID
 // @Description Delete a specific playlist by its ID
@@ -250,7 +728,7 @@ func (pc *PlaylistController) PublishPlaylist(c *gin.Context) {
 
 // AddSongToPlaylist handles POST /playlists/{id}/songs
 // @Summary Add a song to a playlist
-// @Description Add an existing song to a playlist
+// @Description Add an existing song to a playlist. Smart playlists cannot be edited by hand.
 // @Tags playlists
 // @Accept json
 // @Produce json
@@ -259,6 +737,7 @@ func (pc *PlaylistController) PublishPlaylist(c *gin.Context) {
 // @Success 200 {object} models.PlaylistResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
 // @Router /playlists/{id}/songs [post]
 func (pc *PlaylistController) AddSongToPlaylist(c *gin.Context) {
 	idStr := c.Param("id")
@@ -304,6 +783,11 @@ func (pc *PlaylistController) AddSongToPlaylist(c *gin.Context) {
 			c.JSON(http.StatusNotFound, errorResp)
 			return
 		}
+		if err.Error() == "playlist is smart" {
+			errorResp := models.NewErrorResponse("Conflict", 409, "Smart playlists get their songs from their rules and cannot be edited by hand", c.Request.URL.Path)
+			c.JSON(http.StatusConflict, errorResp)
+			return
+		}
 		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to add song to playlist", c.Request.URL.Path)
 		c.JSON(http.StatusBadRequest, errorResp)
 		return
@@ -323,3 +807,246 @@ func (pc *PlaylistController) AddSongToPlaylist(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// UploadPlaylistCover handles PUT /playlists/{id}/cover
+// @Summary Upload a playlist cover image
+// @Description Accepts a JPEG or PNG image (max 10MB) as multipart field "cover" or as the raw request body, and generates square thumbnails
+// @Tags playlists
+// @Accept multipart/form-data,image/jpeg,image/png
+// @Produce json
+// @Param id path int true "Playlist ID"
+// @Param cover formData file true "Cover image"
+// @Success 200 {object} models.PlaylistResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Router /playlists/{id}/cover [put]
+func (pc *PlaylistController) UploadPlaylistCover(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid playlist ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	// Allow some room for multipart headers on top of the image itself
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCoverSize+64<<10)
+
+	var reader io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, _, err := c.Request.FormFile("cover")
+		if err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Missing cover file", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxCoverSize+1))
+	if err != nil || len(data) > maxCoverSize {
+		errorResp := models.NewErrorResponse("Payload Too Large", 413, "Cover image cannot exceed 10MB", c.Request.URL.Path)
+		c.JSON(http.StatusRequestEntityTooLarge, errorResp)
+		return
+	}
+
+	img, contentType, err := imaging.Decode(data)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Unsupported Media Type", 415, "Cover must be a valid JPEG or PNG image", c.Request.URL.Path)
+		c.JSON(http.StatusUnsupportedMediaType, errorResp)
+		return
+	}
+
+	thumbnails := make(map[int][]byte, len(models.PlaylistCoverSizes))
+	for _, size := range models.PlaylistCoverSizes {
+		encoded, err := imaging.EncodeJPEG(imaging.Thumbnail(img, size))
+		if err != nil {
+			errorResp := models.NewErrorResponse("Internal Server Error", 500, "Failed to generate cover thumbnails", c.Request.URL.Path)
+			c.JSON(http.StatusInternalServerError, errorResp)
+			return
+		}
+		thumbnails[size] = encoded
+	}
+
+	sum := sha256.Sum256(data)
+	version := hex.EncodeToString(sum[:8])
+
+	if err := pc.playlistRepo.SetPlaylistCover(uint(id), version, contentType, data, thumbnails); err != nil {
+		if err.Error() == "playlist not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to store playlist cover", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	playlist, err := pc.playlistRepo.GetPlaylistByID(uint(id))
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve updated playlist", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	response := models.PlaylistResponse{
+		Data: *playlist,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetPlaylistCover handles GET /playlists/{id}/cover
+// @Summary Retrieve a playlist cover image
+// @Description Returns the original cover, or a square JPEG thumbnail when size is given
+// @Tags playlists
+// @Produce image/jpeg,image/png
+// @Param id path int true "Playlist ID"
+// @Param size query int false "Thumbnail size in pixels (64, 256 or 640)"
+// @Success 200 {file} binary
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /playlists/{id}/cover [get]
+func (pc *PlaylistController) GetPlaylistCover(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid playlist ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	size := 0
+	if sizeStr := c.Query("size"); sizeStr != "" {
+		size, err = strconv.Atoi(sizeStr)
+		valid := false
+		for _, allowed := range models.PlaylistCoverSizes {
+			valid = valid || size == allowed
+		}
+		if err != nil || !valid {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid cover size", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+	}
+
+	key, contentType, err := pc.playlistRepo.GetPlaylistCover(uint(id), size)
+	if err != nil {
+		switch err.Error() {
+		case "playlist not found":
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+		case "cover not found":
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist has no cover", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+		default:
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve playlist cover", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+		}
+		return
+	}
+
+	blob, info, err := storage.Blobs.Open(key)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Not Found", 404, "Playlist has no cover", c.Request.URL.Path)
+		c.JSON(http.StatusNotFound, errorResp)
+		return
+	}
+	defer blob.Close()
+
+	// Versioned URLs never change content, unversioned ones follow cover updates
+	if version := c.Query("v"); version != "" && strings.Contains(key, "/"+version+"/") {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		c.Header("Cache-Control", "public, max-age=300")
+	}
+	c.Header("Content-Type", contentType)
+	http.ServeContent(c.Writer, c.Request, "", info.ModTime, blob)
+}
+
+// DeletePlaylistCover handles DELETE /playlists/{id}/cover
+// @Summary Delete a playlist cover image
+// @Description Remove the cover image and thumbnails of a playlist
+// @Tags playlists
+// @Param id path int true "Playlist ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} models.ErrorResponse
+// @Router /playlists/{id}/cover [delete]
+func (pc *PlaylistController) DeletePlaylistCover(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid playlist ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if err := pc.playlistRepo.DeletePlaylistCover(uint(id)); err != nil {
+		switch err.Error() {
+		case "playlist not found":
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+		case "cover not found":
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist has no cover", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+		default:
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to delete playlist cover", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// joinPlaylistIDs lists playlist IDs for error messages
+func joinPlaylistIDs(ids []uint) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// playlistArtists returns the artist of every song of a playlist, in order
+func playlistArtists(playlist *models.Playlist) []string {
+	artists := make([]string, len(playlist.Songs))
+	for i, song := range playlist.Songs {
+		artists[i] = song.Artist
+	}
+	return artists
+}
+
+// playlistSongIDs returns the ID of every song of a playlist, in order
+func playlistSongIDs(playlist *models.Playlist) []uint {
+	ids := make([]uint, len(playlist.Songs))
+	for i, song := range playlist.Songs {
+		ids[i] = song.ID
+	}
+	return ids
+}
+
+// reorderPlaylist puts the songs of a playlist in the given order, as indexes
+// into its songs, and records the seed the order came from
+func reorderPlaylist(playlist *models.Playlist, seed string, order []int) {
+	songs := make([]models.PlaylistSong, len(order))
+	for i, index := range order {
+		songs[i] = playlist.Songs[index]
+	}
+	playlist.Songs = songs
+	playlist.ShuffleSeed = &seed
+}
+
+// matchesETag reports whether an If-None-Match header lists etag
+func matchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,430 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"melodia/internal/models"
+	"melodia/internal/normalize"
+	"melodia/internal/playlistformat"
+	"melodia/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxPlaylistFileSize is the largest playlist file accepted for import, in bytes
+	maxPlaylistFileSize = 5 << 20
+	// exportStreamURLTTL is how long stream URLs embedded in exported playlists stay valid
+	exportStreamURLTTL = 24 * time.Hour
+	// maxTextLength is the size of the VARCHAR columns imported values are stored in
+	maxTextLength = 255
+)
+
+// songPathPattern matches the path of the song URLs written by playlist exports
+var songPathPattern = regexp.MustCompile(`^/songs/(\d+)(?:/stream)?$`)
+
+// PlaylistInterchangeController handles playlist import and export in interchange formats
+type PlaylistInterchangeController struct {
+	playlistRepo *repositories.PlaylistRepository
+	songRepo     *repositories.SongRepository
+}
+
+// NewPlaylistInterchangeController creates a new playlist interchange controller
+func NewPlaylistInterchangeController() *PlaylistInterchangeController {
+	return &PlaylistInterchangeController{
+		playlistRepo: repositories.NewPlaylistRepository(),
+		songRepo:     repositories.NewSongRepository(),
+	}
+}
+
+// ExportPlaylist handles GET /playlists/{id}/export
+// @Summary Export a playlist
+// @Description Downloads a playlist as M3U (Latin-1), M3U8 (UTF-8), PLS, XSPF or JSPF. Songs with audio point to signed stream URLs valid for 24 hours, other songs to their API resource. XSPF and JSPF also carry the song URL as track identifier, the playlist creator and annotation, and the published state and added date as extensions.
+// @Tags playlists
+// @Produce audio/x-mpegurl,audio/x-scpls,application/xspf+xml,application/json
+// @Param id path int true "Playlist ID"
+// @Param format query string false "Export format: m3u, m3u8 (default), pls, xspf or jspf"
+// @Success 200 {file} binary
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /playlists/{id}/export [get]
+func (pic *PlaylistInterchangeController) ExportPlaylist(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid playlist ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", playlistformat.FormatM3U8))
+	contentType, ok := playlistformat.ContentTypes[format]
+	if !ok {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Format must be one of: m3u, m3u8, pls, xspf, jspf", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	playlist, err := pic.playlistRepo.GetPlaylistByID(uint(id))
+	if err != nil {
+		if err.Error() == "playlist not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve playlist", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	base := requestBaseURL(c)
+	doc := &playlistformat.Document{
+		Title:      playlist.Name,
+		Creator:    playlist.Creator,
+		Annotation: playlist.Description,
+		Identifier: fmt.Sprintf("%s/playlists/%d", base, playlist.ID),
+		Date:       &playlist.CreatedAt,
+		Public:     &playlist.IsPublished,
+	}
+	if playlist.CoverURL != nil {
+		doc.Image = base + *playlist.CoverURL
+	}
+	for _, song := range playlist.Songs {
+		addedAt := song.AddedAt
+		doc.Entries = append(doc.Entries, playlistformat.Entry{
+			Title:       song.Title,
+			Artist:      song.Artist,
+			Album:       song.Album,
+			Duration:    time.Duration(song.DurationMs) * time.Millisecond,
+			Location:    songLocation(base, song),
+			Identifiers: []string{fmt.Sprintf("%s/songs/%d", base, song.ID)},
+			AddedAt:     &addedAt,
+		})
+	}
+
+	var body []byte
+	switch format {
+	case playlistformat.FormatPLS:
+		body = playlistformat.EncodePLS(doc)
+	case playlistformat.FormatXSPF:
+		body, err = playlistformat.EncodeXSPF(doc)
+	case playlistformat.FormatJSPF:
+		body, err = playlistformat.EncodeJSPF(doc)
+	default:
+		body, err = playlistformat.EncodeM3U(doc, format)
+	}
+	if err != nil {
+		errorResp := models.NewErrorResponse("Internal Server Error", 500, "Failed to encode playlist", c.Request.URL.Path)
+		c.JSON(http.StatusInternalServerError, errorResp)
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": exportFilename(playlist.Name, format),
+	}))
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// ImportPlaylist handles POST /playlists/import
+// @Summary Import a playlist
+// @Description Creates a playlist from an M3U, M3U8, PLS, XSPF or JSPF file. Entries are matched to existing songs by the song URLs this server exports or by normalized title and artist. XSPF and JSPF documents are validated against the spec, and their unmatched tracks with a title and creator are created as new songs. The playlist is always created unpublished; the public flag of an XSPF or JSPF file is only reported back. Everything is stored in a single transaction.
+// @Tags playlists
+// @Accept multipart/form-data,audio/x-mpegurl,audio/x-scpls,application/xspf+xml,application/json,text/plain
+// @Produce json
+// @Param file formData file false "Playlist file (or send it as the raw request body)"
+// @Param format query string false "Source format: m3u, m3u8, pls, xspf or jspf (detected when omitted)"
+// @Param name query string false "Playlist name (defaults to the playlist title or file name)"
+// @Param description query string false "Playlist description (50-255 characters)"
+// @Success 201 {object} models.PlaylistImportResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Router /playlists/import [post]
+func (pic *PlaylistInterchangeController) ImportPlaylist(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxPlaylistFileSize+64<<10)
+
+	var reader io.Reader = c.Request.Body
+	filename := ""
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Missing playlist file", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		defer file.Close()
+		reader = file
+		filename = header.Filename
+	}
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxPlaylistFileSize+1))
+	if err != nil || len(data) > maxPlaylistFileSize {
+		errorResp := models.NewErrorResponse("Payload Too Large", 413, "Playlist file cannot exceed 5MB", c.Request.URL.Path)
+		c.JSON(http.StatusRequestEntityTooLarge, errorResp)
+		return
+	}
+
+	format := strings.ToLower(firstNonEmpty(c.Query("format"), c.PostForm("format")))
+	if format == "" {
+		format = playlistformat.Detect(data, filename)
+	}
+
+	var doc *playlistformat.Document
+	switch format {
+	case playlistformat.FormatM3U, playlistformat.FormatM3U8:
+		doc, err = playlistformat.ParseM3U(data)
+	case playlistformat.FormatPLS:
+		doc, err = playlistformat.ParsePLS(data)
+	case playlistformat.FormatXSPF:
+		doc, err = playlistformat.ParseXSPF(data)
+	case playlistformat.FormatJSPF:
+		doc, err = playlistformat.ParseJSPF(data)
+	default:
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Format must be one of: m3u, m3u8, pls, xspf, jspf", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid playlist file: "+err.Error(), c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if len(doc.Entries) == 0 {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Playlist file has no entries", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	name := strings.TrimSpace(firstNonEmpty(c.Query("name"), c.PostForm("name"), doc.Title,
+		strings.TrimSuffix(path.Base(filename), path.Ext(filename))))
+	if name == "" || name == "." {
+		name = "Imported playlist"
+	}
+
+	description := firstNonEmpty(c.Query("description"), c.PostForm("description"))
+	if description != "" && (len(description) < 50 || len(description) > 255) {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Description must be between 50 and 255 characters long", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	if description == "" {
+		description = truncateText(strings.TrimSpace(doc.Annotation), 255)
+	}
+	if len(description) < 50 {
+		description = fmt.Sprintf("Imported from a %s playlist file with %d entries on %s",
+			strings.ToUpper(format), len(doc.Entries), time.Now().Format("2006-01-02"))
+	}
+
+	playlist := models.Playlist{
+		Name:        truncateText(name, maxTextLength),
+		Description: description,
+		Creator:     truncateText(strings.TrimSpace(doc.Creator), maxTextLength),
+		IsPublished: false,
+	}
+
+	// Only XSPF and JSPF carry structured track metadata reliable enough to create songs
+	createMissing := format == playlistformat.FormatXSPF || format == playlistformat.FormatJSPF
+
+	// The public flag of the file is reported but never applied: importing must
+	// not publish a playlist nobody has reviewed yet
+	report := models.PlaylistImportReport{Public: doc.Public, Entries: []models.PlaylistImportEntry{}}
+	base := requestBaseURL(c)
+	var tracks []repositories.PlaylistTrack
+	entrySongs := make([]*models.Song, len(doc.Entries))
+	newSongs := map[string]*models.Song{}
+	for i, entry := range doc.Entries {
+		result := models.PlaylistImportEntry{
+			Line:     entry.Line,
+			Title:    entry.Title,
+			Artist:   entry.Artist,
+			Location: entry.Location,
+			Status:   models.ImportStatusUnmatched,
+		}
+
+		song, err := pic.matchEntry(entry, base)
+		if err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to match playlist entries", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+
+		key := normalize.SongKey(entry.Title, entry.Artist)
+		switch {
+		case song != nil:
+			result.Status = models.ImportStatusMatched
+			report.Matched++
+		case newSongs[key] != nil:
+			// The same new song appears more than once in the file
+			song = newSongs[key]
+			result.Status = models.ImportStatusMatched
+			report.Matched++
+		case createMissing && strings.TrimSpace(entry.Title) != "" && strings.TrimSpace(entry.Artist) != "":
+			song = &models.Song{
+				Title:       truncateText(strings.TrimSpace(entry.Title), maxTextLength),
+				Artist:      truncateText(strings.TrimSpace(entry.Artist), maxTextLength),
+				Album:       truncateText(strings.TrimSpace(entry.Album), maxTextLength),
+				TrackNumber: entry.TrackNumber,
+				DurationMs:  entry.Duration.Milliseconds(),
+			}
+			newSongs[key] = song
+			result.Status = models.ImportStatusCreated
+			report.Created++
+		default:
+			report.Unmatched++
+		}
+
+		if song != nil {
+			entrySongs[i] = song
+			tracks = append(tracks, repositories.PlaylistTrack{Song: song, AddedAt: entry.AddedAt})
+		}
+		report.Entries = append(report.Entries, result)
+	}
+
+	if err := pic.playlistRepo.CreatePlaylistWithSongs(&playlist, tracks); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to create playlist", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	// New songs only get their IDs once the transaction has committed
+	for i, song := range entrySongs {
+		if song != nil {
+			id := song.ID
+			report.Entries[i].SongID = &id
+		}
+	}
+
+	created, err := pic.playlistRepo.GetPlaylistByID(playlist.ID)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve created playlist", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	if created.Songs == nil {
+		created.Songs = []models.PlaylistSong{}
+	}
+	report.Playlist = *created
+
+	response := models.PlaylistImportResponse{
+		Data: report,
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// matchEntry finds the existing song an imported entry refers to. URLs produced by
+// our own exports identify the song directly, anything else is matched by the
+// normalized title and artist. It returns nil when nothing matches.
+func (pic *PlaylistInterchangeController) matchEntry(entry playlistformat.Entry, base string) (*models.Song, error) {
+	for _, uri := range append([]string{entry.Location}, entry.Identifiers...) {
+		id, ok := songIDFromLocation(uri, base)
+		if !ok {
+			continue
+		}
+		song, err := pic.songRepo.GetSongByID(id)
+		if err == nil {
+			return song, nil
+		}
+		if err.Error() != "song not found" {
+			return nil, err
+		}
+	}
+
+	if entry.Title == "" {
+		return nil, nil
+	}
+
+	song, err := pic.songRepo.FindSongByNormalizedKey(normalize.SongKey(entry.Title, entry.Artist))
+	if err != nil {
+		if err.Error() == "song not found" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return song, nil
+}
+
+// songIDFromLocation returns the song a URL written by our exports points to.
+// Absolute URLs must be on this server, as seen from base; IDs in the URLs of
+// other servers mean other songs.
+func songIDFromLocation(location, base string) (uint, bool) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return 0, false
+	}
+	if u.Scheme != "" || u.Host != "" {
+		if !strings.EqualFold(u.Scheme+"://"+u.Host, base) {
+			return 0, false
+		}
+	}
+	m := songPathPattern.FindStringSubmatch(u.Path)
+	if m == nil {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(m[1], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// requestBaseURL returns the scheme and host the client used to reach the API
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// songLocation returns the absolute URL an exported playlist uses for a song
+func songLocation(base string, song models.PlaylistSong) string {
+	if song.HasAudio {
+		url, _ := signedStreamURL(song.ID, exportStreamURLTTL)
+		return base + url
+	}
+	return fmt.Sprintf("%s/songs/%d", base, song.ID)
+}
+
+// exportFilename derives a safe download file name from a playlist name
+func exportFilename(name, ext string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(strings.TrimSpace(name), ".")
+	if name == "" {
+		name = "playlist"
+	}
+	return name + "." + ext
+}
+
+// truncateText shortens value to at most max bytes without splitting a character
+func truncateText(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	value = value[:max]
+	for !utf8.ValidString(value) {
+		value = value[:len(value)-1]
+	}
+	return value
+}
@@ -0,0 +1,350 @@
+package controllers
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"melodia/internal/models"
+	"melodia/internal/playqueue"
+	"melodia/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QueueController handles listener play queue HTTP requests
+type QueueController struct {
+	queueRepo    *repositories.QueueRepository
+	playlistRepo *repositories.PlaylistRepository
+}
+
+// NewQueueController creates a new queue controller
+func NewQueueController() *QueueController {
+	return &QueueController{
+		queueRepo:    repositories.NewQueueRepository(),
+		playlistRepo: repositories.NewPlaylistRepository(),
+	}
+}
+
+// GetQueue handles GET /queue
+// @Summary Get the play queue
+// @Description Returns the play queue of the listener identified by the X-Listener-ID header, in play order. The queue is stored on the server, so it can be resumed from any device.
+// @Tags queue
+// @Produce json
+// @Param X-Listener-ID header string true "Listener identity"
+// @Success 200 {object} models.QueueResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /queue [get]
+func (qc *QueueController) GetQueue(c *gin.Context) {
+	listenerID, ok := requireListener(c)
+	if !ok {
+		return
+	}
+
+	queue, err := qc.queueRepo.GetQueue(listenerID)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve queue", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	respondQueue(c, queue)
+}
+
+// Enqueue handles POST /queue/items
+// @Summary Queue a song or a playlist
+// @Description Queues a song, or every song of a playlist in playlist order, at the end of the queue or right after the playing song when next is true. Changes to the queue accept If-Match with the queue version and fail with 412 if the queue changed meanwhile.
+// @Tags queue
+// @Accept json
+// @Produce json
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param If-Match header string false "Queue version the change is based on"
+// @Param item body models.EnqueueRequest true "Song or playlist to queue"
+// @Success 200 {object} models.QueueResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Router /queue/items [post]
+func (qc *QueueController) Enqueue(c *gin.Context) {
+	var req models.EnqueueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if (req.SongID == 0) == (req.PlaylistID == 0) {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Either song_id or playlist_id is required", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	songIDs := []uint{req.SongID}
+	if req.PlaylistID != 0 {
+		playlist, err := qc.playlistRepo.GetPlaylistByID(req.PlaylistID)
+		if err != nil {
+			if err.Error() == "playlist not found" {
+				errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+				c.JSON(http.StatusNotFound, errorResp)
+				return
+			}
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to queue playlist", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		songIDs = make([]uint, len(playlist.Songs))
+		for i, song := range playlist.Songs {
+			songIDs[i] = song.ID
+		}
+	}
+
+	qc.update(c, "Failed to queue songs", func(queue *playqueue.Queue) error {
+		return queue.Add(songIDs, req.Next)
+	})
+}
+
+// RemoveQueueItem handles DELETE /queue/items/{itemId}
+// @Summary Remove a song from the queue
+// @Description Removes an item from the queue. Removing the playing item makes the following one current.
+// @Tags queue
+// @Produce json
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param If-Match header string false "Queue version the change is based on"
+// @Param itemId path int true "Queue item ID"
+// @Success 200 {object} models.QueueResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Router /queue/items/{itemId} [delete]
+func (qc *QueueController) RemoveQueueItem(c *gin.Context) {
+	itemID, err := strconv.ParseInt(c.Param("itemId"), 10, 64)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid queue item ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	qc.update(c, "Failed to remove queue item", func(queue *playqueue.Queue) error {
+		return queue.Remove(itemID)
+	})
+}
+
+// MoveQueueItem handles PUT /queue/items/{itemId}/index
+// @Summary Move a song within the queue
+// @Description Moves an item to another index of the play order
+// @Tags queue
+// @Accept json
+// @Produce json
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param If-Match header string false "Queue version the change is based on"
+// @Param itemId path int true "Queue item ID"
+// @Param index body models.QueueIndexRequest true "New index"
+// @Success 200 {object} models.QueueResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Router /queue/items/{itemId}/index [put]
+func (qc *QueueController) MoveQueueItem(c *gin.Context) {
+	itemID, err := strconv.ParseInt(c.Param("itemId"), 10, 64)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid queue item ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	var req models.QueueIndexRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	qc.update(c, "Failed to move queue item", func(queue *playqueue.Queue) error {
+		return queue.Move(itemID, *req.Index)
+	})
+}
+
+// ClearQueue handles DELETE /queue
+// @Summary Clear the queue
+// @Description Removes every song from the queue. Shuffle and repeat are kept.
+// @Tags queue
+// @Produce json
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param If-Match header string false "Queue version the change is based on"
+// @Success 200 {object} models.QueueResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Router /queue [delete]
+func (qc *QueueController) ClearQueue(c *gin.Context) {
+	qc.update(c, "Failed to clear queue", func(queue *playqueue.Queue) error {
+		queue.Clear()
+		return nil
+	})
+}
+
+// SetQueueCurrent handles PUT /queue/current
+// @Summary Play a song of the queue
+// @Description Makes the item at an index of the play order the playing one
+// @Tags queue
+// @Accept json
+// @Produce json
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param If-Match header string false "Queue version the change is based on"
+// @Param index body models.QueueIndexRequest true "Index to play"
+// @Success 200 {object} models.QueueResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Router /queue/current [put]
+func (qc *QueueController) SetQueueCurrent(c *gin.Context) {
+	var req models.QueueIndexRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	qc.update(c, "Failed to change the playing song", func(queue *playqueue.Queue) error {
+		return queue.Jump(*req.Index)
+	})
+}
+
+// NextInQueue handles POST /queue/next
+// @Summary Skip to the next song
+// @Description Moves on to the following item following the repeat mode: repeat one stays on the same item, repeat all starts over after the last one and off stops.
+// @Tags queue
+// @Produce json
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param If-Match header string false "Queue version the change is based on"
+// @Success 200 {object} models.QueueResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Router /queue/next [post]
+func (qc *QueueController) NextInQueue(c *gin.Context) {
+	qc.update(c, "Failed to skip to the next song", func(queue *playqueue.Queue) error {
+		queue.Next()
+		return nil
+	})
+}
+
+// PreviousInQueue handles POST /queue/previous
+// @Summary Go back to the previous song
+// @Description Goes back to the item before the playing one following the repeat mode
+// @Tags queue
+// @Produce json
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param If-Match header string false "Queue version the change is based on"
+// @Success 200 {object} models.QueueResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Router /queue/previous [post]
+func (qc *QueueController) PreviousInQueue(c *gin.Context) {
+	qc.update(c, "Failed to go back to the previous song", func(queue *playqueue.Queue) error {
+		queue.Previous()
+		return nil
+	})
+}
+
+// UpdateQueueModes handles PATCH /queue
+// @Summary Change shuffle and repeat
+// @Description Turns shuffle on or off and changes the repeat mode (off, all or one). Shuffling keeps the playing song first; turning shuffle off restores the order songs were queued in.
+// @Tags queue
+// @Accept json
+// @Produce json
+// @Param X-Listener-ID header string true "Listener identity"
+// @Param If-Match header string false "Queue version the change is based on"
+// @Param modes body models.UpdateQueueRequest true "Modes to change"
+// @Success 200 {object} models.QueueResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Router /queue [patch]
+func (qc *QueueController) UpdateQueueModes(c *gin.Context) {
+	var req models.UpdateQueueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	qc.update(c, "Failed to update queue", func(queue *playqueue.Queue) error {
+		if req.Repeat != nil {
+			if err := queue.SetRepeat(*req.Repeat); err != nil {
+				return err
+			}
+		}
+		if req.Shuffle != nil {
+			queue.SetShuffle(*req.Shuffle, rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())))
+		}
+		return nil
+	})
+}
+
+// update applies a change to the queue of the requesting listener and responds
+// with the resulting queue, or with the error matching what went wrong
+func (qc *QueueController) update(c *gin.Context, failure string, change func(queue *playqueue.Queue) error) {
+	listenerID, ok := requireListener(c)
+	if !ok {
+		return
+	}
+
+	var version *int64
+	if value := c.GetHeader("If-Match"); value != "" {
+		parsed, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(value, "W/"), `"`), 10, 64)
+		if err != nil {
+			errorResp := models.NewErrorResponse("Precondition Failed", 412, "If-Match must be a queue version", c.Request.URL.Path)
+			c.JSON(http.StatusPreconditionFailed, errorResp)
+			return
+		}
+		version = &parsed
+	}
+
+	queue, err := qc.queueRepo.UpdateQueue(listenerID, version, change)
+	if err != nil {
+		switch {
+		case err == playqueue.ErrItemNotFound:
+			errorResp := models.NewErrorResponse("Not Found", 404, "Queue item not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+		case err == playqueue.ErrFull:
+			errorResp := models.NewErrorResponse("Bad Request", 400, "The queue cannot hold more than 1000 songs", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+		case err == playqueue.ErrIndexOutOfRange:
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Index is outside the queue", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+		case err == playqueue.ErrInvalidRepeat:
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Repeat must be off, all or one", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+		case err.Error() == "song not found":
+			errorResp := models.NewErrorResponse("Not Found", 404, "Song not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+		case err.Error() == "queue version mismatch":
+			errorResp := models.NewErrorResponse("Precondition Failed", 412, "The queue changed since that version", c.Request.URL.Path)
+			c.JSON(http.StatusPreconditionFailed, errorResp)
+		default:
+			errorResp := models.NewErrorResponse("Bad Request", 400, failure, c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+		}
+		return
+	}
+
+	respondQueue(c, queue)
+}
+
+// respondQueue writes a queue with its version as ETag
+func respondQueue(c *gin.Context, queue *models.Queue) {
+	c.Header("ETag", `"`+strconv.FormatInt(queue.Version, 10)+`"`)
+
+	response := models.QueueResponse{
+		Data: *queue,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"melodia/internal/models"
+	"melodia/internal/radio"
+	"melodia/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RadioController handles radio station HTTP requests
+type RadioController struct {
+	radioRepo    *repositories.RadioRepository
+	songRepo     *repositories.SongRepository
+	playlistRepo *repositories.PlaylistRepository
+}
+
+// NewRadioController creates a new radio controller
+func NewRadioController() *RadioController {
+	return &RadioController{
+		radioRepo:    repositories.NewRadioRepository(),
+		songRepo:     repositories.NewSongRepository(),
+		playlistRepo: repositories.NewPlaylistRepository(),
+	}
+}
+
+// CreateStation handles POST /radio
+// @Summary Start a radio station
+// @Description Starts an endless station from a song, an artist or a playlist; exactly one of song_id, artist and playlist_id is required. The seed is expanded once into up to 500 songs: a song and the songs most related to it, the songs of an artist, or the songs of a playlist. Songs are then drawn with GET /radio/{id}/next.
+// @Tags radio
+// @Accept json
+// @Produce json
+// @Param station body models.CreateRadioRequest true "Seed of the station"
+// @Success 201 {object} models.RadioStationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /radio [post]
+func (rc *RadioController) CreateStation(c *gin.Context) {
+	var req models.CreateRadioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	req.Artist = strings.TrimSpace(req.Artist)
+	seeds := 0
+	for _, given := range []bool{req.SongID != 0, req.Artist != "", req.PlaylistID != 0} {
+		if given {
+			seeds++
+		}
+	}
+	if seeds != 1 {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Exactly one of song_id, artist or playlist_id is required", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	station := &models.RadioStation{}
+	var seedSongs []uint
+	var err error
+	switch {
+	case req.SongID != 0:
+		if _, err := rc.songRepo.GetSongByID(req.SongID); err != nil {
+			if err.Error() == "song not found" {
+				errorResp := models.NewErrorResponse("Not Found", 404, "Song not found", c.Request.URL.Path)
+				c.JSON(http.StatusNotFound, errorResp)
+				return
+			}
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to create station", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		station.SeedType = radio.SeedSong
+		station.Seed = strconv.FormatUint(uint64(req.SongID), 10)
+		seedSongs, err = rc.radioRepo.SongSeed(req.SongID)
+	case req.Artist != "":
+		station.SeedType = radio.SeedArtist
+		station.Seed = req.Artist
+		seedSongs, err = rc.radioRepo.ArtistSeed(req.Artist)
+		if err == nil && len(seedSongs) == 0 {
+			errorResp := models.NewErrorResponse("Not Found", 404, "No songs found for artist", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+	default:
+		playlist, playlistErr := rc.playlistRepo.GetPlaylistByID(req.PlaylistID)
+		if playlistErr != nil {
+			if playlistErr.Error() == "playlist not found" {
+				errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+				c.JSON(http.StatusNotFound, errorResp)
+				return
+			}
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to create station", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		if len(playlist.Songs) == 0 {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "The playlist has no songs to start a station from", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		station.SeedType = radio.SeedPlaylist
+		station.Seed = strconv.FormatUint(uint64(req.PlaylistID), 10)
+		for _, song := range playlist.Songs {
+			if len(seedSongs) == radio.MaxSeedSongs {
+				break
+			}
+			seedSongs = append(seedSongs, song.ID)
+		}
+	}
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to create station", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if err := rc.radioRepo.CreateStation(station, seedSongs); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to create station", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	response := models.RadioStationResponse{
+		Data: *station,
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetStation handles GET /radio/{id}
+// @Summary Retrieve a radio station
+// @Description Returns the seed of a station and how many songs it has played
+// @Tags radio
+// @Produce json
+// @Param id path int true "Station ID"
+// @Success 200 {object} models.RadioStationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /radio/{id} [get]
+func (rc *RadioController) GetStation(c *gin.Context) {
+	id, ok := stationID(c)
+	if !ok {
+		return
+	}
+
+	station, err := rc.radioRepo.GetStation(id)
+	if err != nil {
+		if err.Error() == "station not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Station not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve station", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	response := models.RadioStationResponse{
+		Data: *station,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// NextSongs handles GET /radio/{id}/next
+// @Summary Get the next songs of a radio station
+// @Description Advances the station and returns its next songs. Each song is drawn at random among the songs sharing published playlists with the previous one, weighted by how often they do, and now and then among the seed songs. A song is not repeated within 50 songs and an artist plays at most 2 songs in a row, unless the catalog is too small to avoid it. Stations are stored, so every call continues where the last one stopped, from any device.
+// @Tags radio
+// @Produce json
+// @Param id path int true "Station ID"
+// @Param n query int false "Number of songs (default 10, max 50)"
+// @Success 200 {object} models.RadioSongsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /radio/{id}/next [get]
+func (rc *RadioController) NextSongs(c *gin.Context) {
+	id, ok := stationID(c)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(c.DefaultQuery("n", "10"))
+	if err != nil || n < 1 || n > radio.MaxBatch {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "n must be between 1 and 50", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	songs, err := rc.radioRepo.NextSongs(id, n)
+	if err != nil {
+		if err.Error() == "station not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Station not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve station songs", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	response := models.RadioSongsResponse{
+		Data: songs,
+	}
+
+	// Every call moves the station on, so responses must not be reused
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
+
+// stationID parses the station ID of the path, responding 400 when it is invalid
+func stationID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid station ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"melodia/internal/models"
+	"melodia/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecommendationController handles song recommendation HTTP requests
+type RecommendationController struct {
+	similarityRepo *repositories.SimilarityRepository
+	songRepo       *repositories.SongRepository
+}
+
+// NewRecommendationController creates a new recommendation controller
+func NewRecommendationController() *RecommendationController {
+	return &RecommendationController{
+		similarityRepo: repositories.NewSimilarityRepository(),
+		songRepo:       repositories.NewSongRepository(),
+	}
+}
+
+// GetRelatedSongs handles GET /songs/{id}/related
+// @Summary Get songs often added together with a song
+// @Description Returns the songs that most often share published playlists with the song, ranked by Jaccard similarity or PMI. Unpublished playlists are ignored. Similarities are recomputed every hour, so recent changes to playlists may be missing.
+// @Tags songs
+// @Produce json
+// @Param id path int true "Song ID"
+// @Param limit query int false "Maximum number of songs (default 10, max 100)"
+// @Param min_support query int false "Minimum number of playlists shared with the song (default 2)"
+// @Param metric query string false "jaccard (default) or pmi"
+// @Success 200 {object} models.RelatedSongsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /songs/{id}/related [get]
+func (rc *RecommendationController) GetRelatedSongs(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid song ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Limit must be between 1 and 100", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	minSupport, err := strconv.Atoi(c.DefaultQuery("min_support", "2"))
+	if err != nil || minSupport < 1 {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "min_support must be a positive number", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	metric := c.DefaultQuery("metric", models.SimilarityJaccard)
+	if metric != models.SimilarityJaccard && metric != models.SimilarityPMI {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Metric must be jaccard or pmi", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if _, err := rc.songRepo.GetSongByID(uint(id)); err != nil {
+		if err.Error() == "song not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Song not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve related songs", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	related, err := rc.similarityRepo.GetRelatedSongs(uint(id), metric, minSupport, limit)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve related songs", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	response := models.RelatedSongsResponse{
+		Data: related,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
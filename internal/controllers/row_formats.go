@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"melodia/internal/models"
+	"melodia/internal/tabular"
+
+	"github.com/gin-gonic/gin"
+)
+
+// songCSVHeader lists the columns of songs exported as CSV
+var songCSVHeader = []string{
+	"id", "title", "artist", "album", "track_number", "year", "duration_ms",
+	"has_audio", "file_missing", "created_at", "updated_at",
+}
+
+// playlistSongCSVHeader lists the columns of playlist songs exported as CSV
+var playlistSongCSVHeader = []string{
+	"position", "id", "title", "artist", "album", "duration_ms", "has_audio", "added_at",
+}
+
+// negotiateRowFormat picks the format of a list response from the format query
+// parameter or else the Accept header, writing the error response when no
+// supported format fits
+func negotiateRowFormat(c *gin.Context) (string, bool) {
+	c.Header("Vary", "Accept")
+
+	if format := c.Query("format"); format != "" {
+		if _, ok := tabular.ContentTypes[format]; !ok {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Format must be json, csv or ndjson", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return "", false
+		}
+		return format, true
+	}
+
+	format := tabular.Negotiate(c.GetHeader("Accept"))
+	if format == "" {
+		errorResp := models.NewErrorResponse("Not Acceptable", 406, "Supported formats are application/json, text/csv and application/x-ndjson", c.Request.URL.Path)
+		c.JSON(http.StatusNotAcceptable, errorResp)
+		return "", false
+	}
+	return format, true
+}
+
+// streamRows writes a CSV or NDJSON attachment from the rows each hands to emit,
+// without buffering the whole result. It returns each's error only when nothing
+// was sent yet, so the caller can still answer with an error response; later
+// failures can only cut the response short and are logged.
+func streamRows(c *gin.Context, format, filename string, header []string, each func(emit func(value interface{}, record []string) error) error) error {
+	c.Header("Content-Type", tabular.ContentTypes[format])
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Status(http.StatusOK)
+
+	encoder, err := tabular.NewEncoder(c.Writer, format, header)
+	if err == nil {
+		err = each(encoder.Encode)
+	}
+	if err == nil {
+		err = encoder.Flush()
+	}
+	if err == nil {
+		return nil
+	}
+
+	if !c.Writer.Written() {
+		c.Writer.Header().Del("Content-Disposition")
+		c.Writer.Header().Del("Content-Type")
+		return err
+	}
+	log.Printf("Failed to stream %s: %v", c.Request.URL.Path, err)
+	return nil
+}
+
+// songRecord returns the CSV record of a song, leaving unknown values empty
+func songRecord(song *models.Song) []string {
+	return []string{
+		strconv.FormatUint(uint64(song.ID), 10),
+		song.Title,
+		song.Artist,
+		song.Album,
+		optionalInt(int64(song.TrackNumber)),
+		optionalInt(int64(song.Year)),
+		optionalInt(song.DurationMs),
+		strconv.FormatBool(song.HasAudio),
+		strconv.FormatBool(song.FileMissing),
+		song.CreatedAt.UTC().Format(time.RFC3339),
+		song.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// playlistSongRecord returns the CSV record of the song at a 1-based position
+func playlistSongRecord(position int, song *models.PlaylistSong) []string {
+	return []string{
+		strconv.Itoa(position),
+		strconv.FormatUint(uint64(song.ID), 10),
+		song.Title,
+		song.Artist,
+		song.Album,
+		optionalInt(song.DurationMs),
+		strconv.FormatBool(song.HasAudio),
+		song.AddedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// optionalInt formats a number, or returns "" for zero
+func optionalInt(value int64) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.FormatInt(value, 10)
+}
@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"melodia/internal/models"
+	"melodia/internal/repositories"
+	"melodia/internal/signing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// shareLinkPurpose scopes share link tokens so they cannot be used as other tokens
+	shareLinkPurpose = "share-link"
+	// defaultShareLinkTTL is how long a share link lasts unless requested otherwise
+	defaultShareLinkTTL = 7 * 24 * time.Hour
+	// maxShareLinkHours is the longest a share link can last, in hours
+	maxShareLinkHours = 90 * 24
+)
+
+// ShareLinkController handles playlist share link HTTP requests
+type ShareLinkController struct {
+	shareLinkRepo *repositories.ShareLinkRepository
+	playlistRepo  *repositories.PlaylistRepository
+}
+
+// NewShareLinkController creates a new share link controller
+func NewShareLinkController() *ShareLinkController {
+	return &ShareLinkController{
+		shareLinkRepo: repositories.NewShareLinkRepository(),
+		playlistRepo:  repositories.NewPlaylistRepository(),
+	}
+}
+
+// CreateShareLink handles POST /playlists/{id}/share-links
+// @Summary Share a playlist
+// @Description Creates a signed link giving read-only access to the playlist through GET /shared/{token}, even while it is unpublished. Links expire after 7 days unless expires_in_hours says otherwise (up to 90 days) and can be revoked.
+// @Tags playlists
+// @Accept json
+// @Produce json
+// @Param id path int true "Playlist ID"
+// @Param link body models.CreateShareLinkRequest false "Label and lifetime of the link"
+// @Success 201 {object} models.ShareLinkResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /playlists/{id}/share-links [post]
+func (slc *ShareLinkController) CreateShareLink(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid playlist ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	var req models.CreateShareLinkRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+	}
+
+	ttl := defaultShareLinkTTL
+	if req.ExpiresInHours > maxShareLinkHours {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Share links cannot last more than 90 days", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+
+	link := &models.ShareLink{
+		PlaylistID: uint(id),
+		Label:      strings.TrimSpace(req.Label),
+		ExpiresAt:  time.Now().Add(ttl).Truncate(time.Second),
+	}
+
+	if err := slc.shareLinkRepo.CreateShareLink(link); err != nil {
+		if err.Error() == "playlist not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to create share link", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	applyShareLinkURL(c, link)
+
+	response := models.ShareLinkResponse{
+		Data: *link,
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetShareLinks handles GET /playlists/{id}/share-links
+// @Summary List the share links of a playlist
+// @Description Returns every share link of the playlist, newest first, including revoked and expired ones with their usage counts
+// @Tags playlists
+// @Produce json
+// @Param id path int true "Playlist ID"
+// @Success 200 {object} models.ShareLinksResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /playlists/{id}/share-links [get]
+func (slc *ShareLinkController) GetShareLinks(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid playlist ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if _, err := slc.playlistRepo.GetPlaylistWithoutSongs(uint(id)); err != nil {
+		if err.Error() == "playlist not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Playlist not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve share links", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	links, err := slc.shareLinkRepo.GetShareLinks(uint(id))
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve share links", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	for i := range links {
+		applyShareLinkURL(c, &links[i])
+	}
+
+	response := models.ShareLinksResponse{
+		Data: links,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeShareLink handles DELETE /playlists/{id}/share-links/{linkId}
+// @Summary Revoke a share link
+// @Description Stops a share link from working. Its usage count is kept.
+// @Tags playlists
+// @Param id path int true "Playlist ID"
+// @Param linkId path int true "Share link ID"
+// @Success 204 "Share link revoked"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /playlists/{id}/share-links/{linkId} [delete]
+func (slc *ShareLinkController) RevokeShareLink(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid playlist ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	linkID, err := strconv.ParseUint(c.Param("linkId"), 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid share link ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if _, err := slc.shareLinkRepo.RevokeShareLink(uint(id), uint(linkID)); err != nil {
+		if err.Error() == "share link not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Share link not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to revoke share link", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetSharedPlaylist handles GET /shared/{token}
+// @Summary Open a shared playlist
+// @Description Returns the playlist a share link points to, read-only and whether it is published or not. Every successful visit is counted.
+// @Tags playlists
+// @Produce json
+// @Param token path string true "Share link token"
+// @Success 200 {object} models.PlaylistResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Router /shared/{token} [get]
+func (slc *ShareLinkController) GetSharedPlaylist(c *gin.Context) {
+	// Shared drafts must not end up in shared caches or search engines
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Robots-Tag", "noindex")
+
+	linkID, err := signing.ParseToken(shareLinkPurpose, c.Param("token"))
+	if err != nil {
+		if err == signing.ErrExpired {
+			errorResp := models.NewErrorResponse("Gone", 410, "Share link has expired", c.Request.URL.Path)
+			c.JSON(http.StatusGone, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Not Found", 404, "Share link not found", c.Request.URL.Path)
+		c.JSON(http.StatusNotFound, errorResp)
+		return
+	}
+
+	playlistID, err := slc.shareLinkRepo.UseShareLink(linkID)
+	if err != nil {
+		switch err.Error() {
+		case "share link not found":
+			errorResp := models.NewErrorResponse("Not Found", 404, "Share link not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+		case "share link revoked":
+			errorResp := models.NewErrorResponse("Gone", 410, "Share link has been revoked", c.Request.URL.Path)
+			c.JSON(http.StatusGone, errorResp)
+		case "share link expired":
+			errorResp := models.NewErrorResponse("Gone", 410, "Share link has expired", c.Request.URL.Path)
+			c.JSON(http.StatusGone, errorResp)
+		default:
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to open share link", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+		}
+		return
+	}
+
+	playlist, err := slc.playlistRepo.GetPlaylistByID(playlistID)
+	if err != nil {
+		if err.Error() == "playlist not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Share link not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve playlist", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	response := models.PlaylistResponse{
+		Data: *playlist,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// applyShareLinkURL sets the token and absolute URL of a share link
+func applyShareLinkURL(c *gin.Context, link *models.ShareLink) {
+	link.Token = signing.NewToken(shareLinkPurpose, link.ID, link.ExpiresAt)
+	link.URL = requestBaseURL(c) + "/shared/" + link.Token
+}
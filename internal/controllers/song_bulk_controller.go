@@ -0,0 +1,364 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"melodia/internal/models"
+	"melodia/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxBulkItems is the largest number of songs accepted by a bulk operation
+	maxBulkItems = 1000
+	// maxBulkBodySize is the largest bulk request body accepted, in bytes
+	maxBulkBodySize = 5 << 20
+)
+
+// BulkCreateSongs handles POST /songs/bulk
+// @Summary Create several songs
+// @Description Creates up to 1000 songs in a single transaction. Every item is validated like POST /songs and reported with the status it would get on its own. In all_or_nothing mode (default) nothing is stored unless every item succeeds; in partial mode the valid items are stored and the response is 207 Multi-Status.
+// @Tags songs
+// @Accept json
+// @Produce json
+// @Param mode query string false "all_or_nothing (default) or partial"
+// @Param songs body models.BulkCreateSongsRequest true "Songs to create"
+// @Success 201 {object} models.BulkResponse
+// @Success 207 {object} models.BulkResponse
+// @Failure 400 {object} models.BulkResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Router /songs/bulk [post]
+func (sc *SongController) BulkCreateSongs(c *gin.Context) {
+	var req models.BulkCreateSongsRequest
+	mode, ok := bindBulkRequest(c, &req)
+	if !ok {
+		return
+	}
+	if !checkBulkSize(c, len(req.Songs)) {
+		return
+	}
+
+	items := make([]models.BulkItemResult, len(req.Songs))
+	var songs []*models.Song
+	var indexes []int
+	for i, item := range req.Songs {
+		items[i].Index = i
+		genre := strings.TrimSpace(item.Genre)
+		if reason := bulkSongFieldsError(item.Title, item.Artist, genre); reason != "" {
+			items[i].Status = http.StatusBadRequest
+			items[i].Error = reason
+			continue
+		}
+		songs = append(songs, &models.Song{Title: item.Title, Artist: item.Artist, Genre: genre})
+		indexes = append(indexes, i)
+	}
+
+	songBatch, err := sc.songRepo.BeginBatch()
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to create songs", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	defer songBatch.Rollback()
+
+	if len(songs) > 0 {
+		duplicateOf, err := songBatch.InsertSongs(songs)
+		if err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to create songs", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+
+		// createdBy maps songs created by this request to the item that created them
+		createdBy := make(map[uint]int)
+		for i, index := range indexes {
+			item := &items[index]
+			if duplicateOf[i] == 0 {
+				id := songs[i].ID
+				item.ID = &id
+				item.Status = http.StatusCreated
+				item.Song = songs[i]
+				createdBy[id] = index
+				continue
+			}
+
+			item.Status = http.StatusConflict
+			if earlier, found := createdBy[duplicateOf[i]]; found {
+				item.Error = fmt.Sprintf("Same title and artist as item %d", earlier)
+			} else {
+				id := duplicateOf[i]
+				item.ID = &id
+				item.Error = fmt.Sprintf("A song with the same title and artist already exists (ID %d)", id)
+			}
+		}
+	}
+
+	finishBulk(c, songBatch, mode, items, http.StatusCreated)
+}
+
+// BulkUpdateSongs handles PATCH /songs/bulk
+// @Summary Update several songs
+// @Description Sets the title, artist and genre of up to 1000 songs, keeping the current genre of items without one, with a single statement. Every item is validated like PUT /songs/{id} and reported with the status it would get on its own. In all_or_nothing mode (default) nothing is changed unless every item succeeds; in partial mode the valid items are updated and the response is 207 Multi-Status.
+// @Tags songs
+// @Accept json
+// @Produce json
+// @Param mode query string false "all_or_nothing (default) or partial"
+// @Param songs body models.BulkUpdateSongsRequest true "Songs to update"
+// @Success 200 {object} models.BulkResponse
+// @Success 207 {object} models.BulkResponse
+// @Failure 400 {object} models.BulkResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Router /songs/bulk [patch]
+func (sc *SongController) BulkUpdateSongs(c *gin.Context) {
+	var req models.BulkUpdateSongsRequest
+	mode, ok := bindBulkRequest(c, &req)
+	if !ok {
+		return
+	}
+	if !checkBulkSize(c, len(req.Songs)) {
+		return
+	}
+
+	items := make([]models.BulkItemResult, len(req.Songs))
+	ids := make([]uint, len(req.Songs))
+	for i, item := range req.Songs {
+		ids[i] = item.ID
+	}
+	valid := validateBulkIDs(items, ids)
+
+	var songs []*models.Song
+	var keepGenre []bool
+	var indexes []int
+	for _, i := range valid {
+		item := req.Songs[i]
+		genre := ""
+		if item.Genre != nil {
+			genre = strings.TrimSpace(*item.Genre)
+		}
+		if reason := bulkSongFieldsError(item.Title, item.Artist, genre); reason != "" {
+			items[i].Status = http.StatusBadRequest
+			items[i].Error = reason
+			continue
+		}
+		songs = append(songs, &models.Song{ID: item.ID, Title: item.Title, Artist: item.Artist, Genre: genre})
+		keepGenre = append(keepGenre, item.Genre == nil)
+		indexes = append(indexes, i)
+	}
+
+	songBatch, err := sc.songRepo.BeginBatch()
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to update songs", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	defer songBatch.Rollback()
+
+	if len(songs) > 0 {
+		found, err := songBatch.UpdateSongs(songs, keepGenre)
+		if err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to update songs", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+
+		for i, index := range indexes {
+			if !found[i] {
+				items[index].Status = http.StatusNotFound
+				items[index].Error = "Song not found"
+				continue
+			}
+			items[index].Status = http.StatusOK
+			items[index].Song = songs[i]
+		}
+	}
+
+	finishBulk(c, songBatch, mode, items, http.StatusOK)
+}
+
+// BulkDeleteSongs handles DELETE /songs/bulk
+// @Summary Delete several songs
+// @Description Deletes up to 1000 songs with a single statement. In all_or_nothing mode (default) nothing is deleted unless every song exists; in partial mode the existing songs are deleted and the response is 207 Multi-Status.
+// @Tags songs
+// @Accept json
+// @Produce json
+// @Param mode query string false "all_or_nothing (default) or partial"
+// @Param ids body models.BulkDeleteSongsRequest true "IDs of the songs to delete"
+// @Success 200 {object} models.BulkResponse
+// @Success 207 {object} models.BulkResponse
+// @Failure 400 {object} models.BulkResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Router /songs/bulk [delete]
+func (sc *SongController) BulkDeleteSongs(c *gin.Context) {
+	var req models.BulkDeleteSongsRequest
+	mode, ok := bindBulkRequest(c, &req)
+	if !ok {
+		return
+	}
+	if !checkBulkSize(c, len(req.IDs)) {
+		return
+	}
+
+	items := make([]models.BulkItemResult, len(req.IDs))
+	valid := validateBulkIDs(items, req.IDs)
+
+	songBatch, err := sc.songRepo.BeginBatch()
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to delete songs", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	defer songBatch.Rollback()
+
+	if len(valid) > 0 {
+		ids := make([]uint, len(valid))
+		for i, index := range valid {
+			ids[i] = req.IDs[index]
+		}
+
+		found, err := songBatch.DeleteSongs(ids)
+		if err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to delete songs", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+
+		for i, index := range valid {
+			if !found[i] {
+				items[index].Status = http.StatusNotFound
+				items[index].Error = "Song not found"
+				continue
+			}
+			items[index].Status = http.StatusNoContent
+		}
+	}
+
+	finishBulk(c, songBatch, mode, items, http.StatusOK)
+}
+
+// bindBulkRequest reads the mode and the JSON body of a bulk request, writing
+// the error response when either is invalid
+func bindBulkRequest(c *gin.Context, req interface{}) (string, bool) {
+	mode := c.DefaultQuery("mode", models.BulkModeAllOrNothing)
+	if mode != models.BulkModeAllOrNothing && mode != models.BulkModePartial {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Mode must be all_or_nothing or partial", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return "", false
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBulkBodySize)
+	if err := c.ShouldBindJSON(req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			errorResp := models.NewErrorResponse("Payload Too Large", 413, "Request body cannot exceed 5MB", c.Request.URL.Path)
+			c.JSON(http.StatusRequestEntityTooLarge, errorResp)
+			return "", false
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return "", false
+	}
+	return mode, true
+}
+
+// checkBulkSize rejects bulk requests without items or with too many of them
+func checkBulkSize(c *gin.Context, count int) bool {
+	if count == 0 {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "At least one song is required", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return false
+	}
+	if count > maxBulkItems {
+		detail := fmt.Sprintf("A bulk request cannot contain more than %d songs", maxBulkItems)
+		errorResp := models.NewErrorResponse("Bad Request", 400, detail, c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return false
+	}
+	return true
+}
+
+// validateBulkIDs sets the index and ID of every item, marks missing and repeated
+// IDs as bad requests and returns the indexes of the remaining items
+func validateBulkIDs(items []models.BulkItemResult, ids []uint) []int {
+	var valid []int
+	seen := make(map[uint]int, len(ids))
+	for i, id := range ids {
+		items[i].Index = i
+		if id == 0 {
+			items[i].Status = http.StatusBadRequest
+			items[i].Error = "Song ID is required"
+			continue
+		}
+
+		items[i].ID = &id
+		if earlier, found := seen[id]; found {
+			items[i].Status = http.StatusBadRequest
+			items[i].Error = fmt.Sprintf("Song ID already listed in item %d", earlier)
+			continue
+		}
+		seen[id] = i
+		valid = append(valid, i)
+	}
+	return valid
+}
+
+// finishBulk commits or rolls back a bulk operation depending on its mode and
+// the outcome of its items, and writes the response
+func finishBulk(c *gin.Context, songBatch *repositories.SongBatch, mode string, items []models.BulkItemResult, successStatus int) {
+	result := models.BulkResult{Mode: mode, Items: items}
+	for _, item := range items {
+		if item.Status >= 200 && item.Status < 300 {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+	}
+
+	// In all_or_nothing mode a single failure discards the whole batch
+	if mode == models.BulkModeAllOrNothing && result.Failed > 0 {
+		for i := range items {
+			if items[i].Status >= 200 && items[i].Status < 300 {
+				// IDs of rolled back songs were never stored
+				if items[i].Status == http.StatusCreated {
+					items[i].ID = nil
+				}
+				items[i].Status = http.StatusFailedDependency
+				items[i].Error = "Not applied because another item failed"
+				items[i].Song = nil
+			}
+		}
+		result.Failed += result.Succeeded
+		result.Succeeded = 0
+		c.JSON(http.StatusBadRequest, models.BulkResponse{Data: result})
+		return
+	}
+
+	if err := songBatch.Commit(); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to apply bulk operation", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	result.Applied = true
+
+	status := successStatus
+	if mode == models.BulkModePartial {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, models.BulkResponse{Data: result})
+}
+
+// bulkSongFieldsError returns why the fields of a bulk item cannot be stored,
+// or an empty string when they are valid
+func bulkSongFieldsError(title, artist, genre string) string {
+	if reason := songFieldsError(title, artist); reason != "" {
+		return reason
+	}
+	if utf8.RuneCountInString(genre) > 255 {
+		return "Genre cannot exceed 255 characters"
+	}
+	return ""
+}
@@ -1,15 +1,32 @@
 package controllers
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
+	"melodia/internal/audio"
 	"melodia/internal/models"
+	"melodia/internal/normalize"
 	"melodia/internal/repositories"
+	"melodia/internal/storage"
+	"melodia/internal/tabular"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxAudioSize is the largest audio file accepted, in bytes
+const maxAudioSize = 500 << 20
+
 // SongController handles song-related HTTP requests
 type SongController struct {
 	songRepo *repositories.SongRepository
@@ -24,13 +41,14 @@ func NewSongController() *SongController {
 
 // CreateSong handles POST /songs
 // @Summary Create a new song
-// @Description Create a new song with title and artist
+// @Description Create a new song with title, artist and an optional genre
 // @Tags songs
 // @Accept json
 // @Produce json
 // @Param song body models.CreateSongRequest true "Song information"
 // @Success 201 {object} models.SongResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
 // @Router /songs [post]
 func (sc *SongController) CreateSong(c *gin.Context) {
 	var req models.CreateSongRequest
@@ -41,8 +59,8 @@ func (sc *SongController) CreateSong(c *gin.Context) {
 		return
 	}
 
-	if req.Title == "" || req.Artist == "" {
-		errorResp := models.NewErrorResponse("Bad Request", 400, "Title and artist are required", c.Request.URL.Path)
+	if reason := songFieldsError(req.Title, req.Artist); reason != "" {
+		errorResp := models.NewErrorResponse("Bad Request", 400, reason, c.Request.URL.Path)
 		c.JSON(http.StatusBadRequest, errorResp)
 		return
 	}
@@ -50,13 +68,22 @@ func (sc *SongController) CreateSong(c *gin.Context) {
 	song := &models.Song{
 		Title:  req.Title,
 		Artist: req.Artist,
+		Genre:  strings.TrimSpace(req.Genre),
 	}
 
-	if err := sc.songRepo.CreateSong(song); err != nil {
+	// Reject songs that only differ in case, accents, punctuation or "feat." credits
+	existing, err := sc.songRepo.CreateSongIfNew(song)
+	if err != nil {
 		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to create song", c.Request.URL.Path)
 		c.JSON(http.StatusBadRequest, errorResp)
 		return
 	}
+	if existing != nil {
+		detail := fmt.Sprintf("A song with the same title and artist already exists (ID %d)", existing.ID)
+		errorResp := models.NewErrorResponse("Conflict", 409, detail, c.Request.URL.Path)
+		c.JSON(http.StatusConflict, errorResp)
+		return
+	}
 
 	response := models.SongResponse{
 		Data: *song,
@@ -67,13 +94,33 @@ func (sc *SongController) CreateSong(c *gin.Context) {
 
 // GetSongs handles GET /songs
 // @Summary Retrieve all songs
-// @Description Get a list of all songs
+// @Description Get a list of all songs. With Accept: text/csv or application/x-ndjson (or format=csv|ndjson) the catalog is streamed row by row as a file download.
 // @Tags songs
-// @Produce json
+// @Produce json,text/csv,application/x-ndjson
+// @Param format query string false "json (default), csv or ndjson; overrides the Accept header"
 // @Success 200 {object} models.SongsResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 406 {object} models.ErrorResponse
 // @Router /songs [get]
 func (sc *SongController) GetSongs(c *gin.Context) {
+	format, ok := negotiateRowFormat(c)
+	if !ok {
+		return
+	}
+
+	if format != tabular.FormatJSON {
+		err := streamRows(c, format, "songs."+format, songCSVHeader, func(emit func(interface{}, []string) error) error {
+			return sc.songRepo.EachSong(func(song *models.Song) error {
+				return emit(song, songRecord(song))
+			})
+		})
+		if err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve songs", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+		}
+		return
+	}
+
 	songs, err := sc.songRepo.GetSongs()
 	if err != nil {
 		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve songs", c.Request.URL.Path)
@@ -169,6 +216,9 @@ func (sc *SongController) UpdateSong(c *gin.Context) {
 	// Update song fields
 	existingSong.Title = req.Title
 	existingSong.Artist = req.Artist
+	if req.Genre != nil {
+		existingSong.Genre = strings.TrimSpace(*req.Genre)
+	}
 
 	// Save updated song to database
 	if err := sc.songRepo.UpdateSong(existingSong); err != nil {
@@ -215,3 +265,303 @@ func (sc *SongController) DeleteSong(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// GetDuplicateSongs handles GET /songs/duplicates
+// @Summary Report duplicate songs
+// @Description Groups songs whose title and artist match after normalizing case, accents, punctuation and "feat." credits
+// @Tags songs
+// @Produce json
+// @Success 200 {object} models.DuplicateSongsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /songs/duplicates [get]
+func (sc *SongController) GetDuplicateSongs(c *gin.Context) {
+	groups, err := sc.songRepo.GetDuplicateSongs()
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve duplicate songs", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	response := models.DuplicateSongsResponse{
+		Data: groups,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// MergeSongs handles POST /songs/{id}/merge
+// @Summary Merge duplicate songs into a canonical song
+// @Description Re-points every playlist entry, queued song, like and play of the duplicates to the canonical song and deletes the duplicates, in a single transaction. Songs whose normalized title and artist differ from the canonical song's are rejected unless force is set.
+// @Tags songs
+// @Accept json
+// @Produce json
+// @Param id path int true "Canonical song ID"
+// @Param merge body models.MergeSongsRequest true "Songs to fold into the canonical one"
+// @Success 200 {object} models.MergeSongsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /songs/{id}/merge [post]
+func (sc *SongController) MergeSongs(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid song ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	var req models.MergeSongsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid request body", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if len(req.DuplicateIDs) == 0 {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "At least one duplicate song ID is required", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	result, err := sc.songRepo.MergeSongs(uint(id), req.DuplicateIDs, req.Force)
+	if err != nil {
+		switch err.Error() {
+		case "song not found":
+			errorResp := models.NewErrorResponse("Not Found", 404, "Song not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+		case "duplicate song not found":
+			errorResp := models.NewErrorResponse("Not Found", 404, "One or more duplicate songs were not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+		case "songs are not duplicates":
+			errorResp := models.NewErrorResponse("Conflict", 409, "One or more songs have a different title and artist than the canonical song; set force to merge them anyway", c.Request.URL.Path)
+			c.JSON(http.StatusConflict, errorResp)
+		case "cannot merge a song into itself":
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Cannot merge a song into itself", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+		default:
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to merge songs", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+		}
+		return
+	}
+
+	response := models.MergeSongsResponse{
+		Data: *result,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UploadSong handles POST /songs/upload
+// @Summary Upload an audio file
+// @Description Accepts an MP3, FLAC or OGG file as multipart field "file" and creates a song pre-filled from its tags (title, artist, album, track number, year, duration and embedded cover). Identical uploads return the existing song. Optional title, artist and album form fields override the tags.
+// @Tags songs
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Audio file"
+// @Param title formData string false "Title override"
+// @Param artist formData string false "Artist override"
+// @Param album formData string false "Album override"
+// @Param genre formData string false "Genre override"
+// @Success 200 {object} models.SongResponse "Existing song with identical audio"
+// @Success 201 {object} models.SongResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Router /songs/upload [post]
+func (sc *SongController) UploadSong(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAudioSize+1<<20)
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Missing audio file", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	defer file.Close()
+
+	// Spool the upload to disk, hashing it on the way, so tags can be read with seeks
+	tmp, err := os.CreateTemp("", "melodia-upload-*")
+	if err != nil {
+		errorResp := models.NewErrorResponse("Internal Server Error", 500, "Failed to process upload", c.Request.URL.Path)
+		c.JSON(http.StatusInternalServerError, errorResp)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), io.LimitReader(file, maxAudioSize+1))
+	if err != nil || size > maxAudioSize {
+		errorResp := models.NewErrorResponse("Payload Too Large", 413, "Audio file cannot exceed 500MB", c.Request.URL.Path)
+		c.JSON(http.StatusRequestEntityTooLarge, errorResp)
+		return
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	// Identical uploads resolve to the song that already owns this audio
+	if existing, err := sc.songRepo.FindSongByAudioHash(hash); err == nil {
+		c.JSON(http.StatusOK, models.SongResponse{Data: *existing})
+		return
+	}
+
+	metadata, err := audio.Read(tmp)
+	if err != nil {
+		detail := "Unsupported audio file, expected MP3, FLAC or OGG"
+		if errors.Is(err, audio.ErrCorrupt) {
+			detail = "Corrupt audio file: " + strings.TrimPrefix(err.Error(), audio.ErrCorrupt.Error()+": ")
+		}
+		errorResp := models.NewErrorResponse("Unprocessable Entity", 422, detail, c.Request.URL.Path)
+		c.JSON(http.StatusUnprocessableEntity, errorResp)
+		return
+	}
+
+	fileTitle := strings.TrimSuffix(filepath.Base(header.Filename), filepath.Ext(header.Filename))
+	song := &models.Song{
+		Title:            firstNonEmpty(c.PostForm("title"), metadata.Title, fileTitle),
+		Artist:           firstNonEmpty(c.PostForm("artist"), metadata.Artist, "Unknown Artist"),
+		Album:            firstNonEmpty(c.PostForm("album"), metadata.Album),
+		Genre:            firstNonEmpty(c.PostForm("genre"), metadata.Genre),
+		TrackNumber:      metadata.TrackNumber,
+		Year:             metadata.Year,
+		DurationMs:       metadata.DurationMs,
+		AudioKey:         "songs/audio/" + hash,
+		AudioHash:        hash,
+		AudioContentType: metadata.MimeType,
+		AudioSize:        size,
+	}
+
+	if metadata.Cover != nil {
+		contentType := http.DetectContentType(metadata.Cover.Data)
+		if contentType == "image/jpeg" || contentType == "image/png" {
+			sum := sha256.Sum256(metadata.Cover.Data)
+			coverKey := "songs/covers/" + hex.EncodeToString(sum[:])
+			if _, err := storage.Blobs.Put(coverKey, bytes.NewReader(metadata.Cover.Data)); err == nil {
+				song.CoverKey = coverKey
+				song.CoverContentType = contentType
+			}
+		}
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		errorResp := models.NewErrorResponse("Internal Server Error", 500, "Failed to process upload", c.Request.URL.Path)
+		c.JSON(http.StatusInternalServerError, errorResp)
+		return
+	}
+	if _, err := storage.Blobs.Put(song.AudioKey, tmp); err != nil {
+		errorResp := models.NewErrorResponse("Internal Server Error", 500, "Failed to store audio file", c.Request.URL.Path)
+		c.JSON(http.StatusInternalServerError, errorResp)
+		return
+	}
+
+	status := http.StatusCreated
+	existing, err := sc.songRepo.FindSongByNormalizedKey(normalize.SongKey(song.Title, song.Artist))
+	switch {
+	case err == nil && existing.AudioKey == "":
+		// The song is already in the catalog without audio, attach the file to it
+		song.ID = existing.ID
+		err = sc.songRepo.AttachSongAudio(song)
+		status = http.StatusOK
+	case err == nil:
+		sc.songRepo.DeleteUnreferencedBlobs(song.AudioKey, song.CoverKey)
+		detail := fmt.Sprintf("A song with the same title and artist already has audio (ID %d)", existing.ID)
+		errorResp := models.NewErrorResponse("Conflict", 409, detail, c.Request.URL.Path)
+		c.JSON(http.StatusConflict, errorResp)
+		return
+	case err.Error() == "song not found":
+		err = sc.songRepo.CreateSong(song)
+	}
+
+	if err != nil {
+		// A concurrent identical upload won the race, return its song
+		if err.Error() == "song audio already exists" {
+			if existing, err := sc.songRepo.FindSongByAudioHash(hash); err == nil {
+				c.JSON(http.StatusOK, models.SongResponse{Data: *existing})
+				return
+			}
+		}
+		sc.songRepo.DeleteUnreferencedBlobs(song.AudioKey, song.CoverKey)
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to create song", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	response := models.SongResponse{
+		Data: *song,
+	}
+
+	c.JSON(status, response)
+}
+
+// GetSongCover handles GET /songs/{id}/cover
+// @Summary Retrieve a song cover image
+// @Description Returns the cover image embedded in the song's audio file
+// @Tags songs
+// @Produce image/jpeg,image/png
+// @Param id path int true "Song ID"
+// @Success 200 {file} binary
+// @Failure 404 {object} models.ErrorResponse
+// @Router /songs/{id}/cover [get]
+func (sc *SongController) GetSongCover(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid song ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	song, err := sc.songRepo.GetSongByID(uint(id))
+	if err != nil {
+		if err.Error() == "song not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Song not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve song", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if song.CoverKey == "" {
+		errorResp := models.NewErrorResponse("Not Found", 404, "Song has no cover", c.Request.URL.Path)
+		c.JSON(http.StatusNotFound, errorResp)
+		return
+	}
+
+	blob, info, err := storage.Blobs.Open(song.CoverKey)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Not Found", 404, "Song has no cover", c.Request.URL.Path)
+		c.JSON(http.StatusNotFound, errorResp)
+		return
+	}
+	defer blob.Close()
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Header("Content-Type", song.CoverContentType)
+	http.ServeContent(c.Writer, c.Request, "", info.ModTime, blob)
+}
+
+// songFieldsError returns why a title and artist cannot be stored, or an empty
+// string when they are valid
+func songFieldsError(title, artist string) string {
+	if title == "" || artist == "" {
+		return "Title and artist are required"
+	}
+	if utf8.RuneCountInString(title) > 255 || utf8.RuneCountInString(artist) > 255 {
+		return "Title and artist cannot exceed 255 characters"
+	}
+	return ""
+}
+
+// firstNonEmpty returns the first value that is not blank
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
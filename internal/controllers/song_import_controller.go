@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"melodia/internal/models"
+	"melodia/internal/songcsv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxCSVImportSize is the largest CSV file accepted by the song import, in bytes
+	maxCSVImportSize = 100 << 20
+	// importBatchSize is how many rows are inserted per statement
+	importBatchSize = 1000
+)
+
+// ImportSongs handles POST /songs/import
+// @Summary Import songs from CSV
+// @Description Creates songs from a CSV file with a header row, sent as the raw body or as the "file" part of a multipart form. Title and artist columns are required; album, track_number, year and duration_ms (milliseconds or m:ss) are optional. Columns are found by name or mapped explicitly, e.g. columns[title]=Track Name. Rows are validated like POST /songs and inserted in batches inside a single transaction. With dry_run=true nothing is stored.
+// @Tags songs
+// @Accept text/csv,multipart/form-data
+// @Produce json
+// @Param file formData file false "CSV file (or send it as the raw request body)"
+// @Param dry_run query bool false "Validate and report without storing anything"
+// @Param delimiter query string false "Field delimiter: a single character or \"tab\" (default comma)"
+// @Param columns[title] query string false "Header of the title column"
+// @Param columns[artist] query string false "Header of the artist column"
+// @Success 200 {object} models.SongImportResponse
+// @Success 201 {object} models.SongImportResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Router /songs/import [post]
+func (sc *SongController) ImportSongs(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "dry_run must be true or false", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	var delimiter rune
+	switch value := c.Query("delimiter"); {
+	case value == "":
+	case value == "tab" || value == `\t`:
+		delimiter = '\t'
+	case utf8.RuneCountInString(value) == 1 && value != `"` && value != "\n" && value != "\r":
+		delimiter, _ = utf8.DecodeRuneInString(value)
+	default:
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Delimiter must be a single character", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCSVImportSize)
+
+	// Multipart bodies are read part by part so the file is never held in memory
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		multipartReader, err := c.Request.MultipartReader()
+		if err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid multipart body", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		body = nil
+		for {
+			part, err := multipartReader.NextPart()
+			if err != nil {
+				break
+			}
+			if part.FormName() == "file" {
+				body = part
+				break
+			}
+		}
+		if body == nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Missing CSV file", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+	}
+
+	reader, err := songcsv.NewReader(body, c.QueryMap("columns"), delimiter)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid CSV: "+err.Error(), c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	songBatch, err := sc.songRepo.BeginBatch()
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to import songs", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+	defer songBatch.Rollback()
+
+	report := models.SongImportReport{DryRun: dryRun, Rows: []models.SongImportRow{}}
+	// createdLines maps songs created by this import to the row that created them
+	createdLines := make(map[uint]int)
+	var batch []*models.Song
+	var batchRows []int
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		duplicateOf, err := songBatch.InsertSongs(batch)
+		if err != nil {
+			return err
+		}
+
+		for i, rowIndex := range batchRows {
+			row := &report.Rows[rowIndex]
+			if duplicateOf[i] == 0 {
+				row.Status = models.ImportStatusCreated
+				report.Created++
+				createdLines[batch[i].ID] = row.Line
+				if !dryRun {
+					id := batch[i].ID
+					row.SongID = &id
+				}
+				continue
+			}
+
+			row.Status = models.ImportStatusDuplicate
+			report.Duplicates++
+			if line, found := createdLines[duplicateOf[i]]; found {
+				row.Reason = fmt.Sprintf("Same title and artist as line %d", line)
+				if dryRun {
+					continue
+				}
+			} else {
+				row.Reason = fmt.Sprintf("A song with the same title and artist already exists (ID %d)", duplicateOf[i])
+			}
+			id := duplicateOf[i]
+			row.SongID = &id
+		}
+
+		batch = batch[:0]
+		batchRows = batchRows[:0]
+		return nil
+	}
+
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				errorResp := models.NewErrorResponse("Payload Too Large", 413, "CSV file cannot exceed 100MB", c.Request.URL.Path)
+				c.JSON(http.StatusRequestEntityTooLarge, errorResp)
+				return
+			}
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to read CSV file", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+
+		report.Total++
+		result := models.SongImportRow{
+			Line:   row.Line,
+			Title:  row.Title,
+			Artist: row.Artist,
+		}
+
+		if row.Err != nil {
+			result.Status = models.ImportStatusInvalid
+			result.Reason = row.Err.Error()
+			report.Invalid++
+		} else if reason := songFieldsError(row.Title, row.Artist); reason != "" {
+			result.Status = models.ImportStatusInvalid
+			result.Reason = reason
+			report.Invalid++
+		} else {
+			batch = append(batch, &models.Song{
+				Title:       row.Title,
+				Artist:      row.Artist,
+				Album:       truncateText(row.Album, maxTextLength),
+				TrackNumber: row.TrackNumber,
+				Year:        row.Year,
+				DurationMs:  row.DurationMs,
+			})
+			batchRows = append(batchRows, len(report.Rows))
+		}
+		report.Rows = append(report.Rows, result)
+
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to import songs", c.Request.URL.Path)
+				c.JSON(http.StatusBadRequest, errorResp)
+				return
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to import songs", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	status := http.StatusOK
+	if !dryRun {
+		if err := songBatch.Commit(); err != nil {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to import songs", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		if report.Created > 0 {
+			status = http.StatusCreated
+		}
+	}
+
+	response := models.SongImportResponse{
+		Data: report,
+	}
+
+	c.JSON(status, response)
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"melodia/internal/models"
+	"melodia/internal/repositories"
+	"melodia/internal/signing"
+	"melodia/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultStreamURLTTL is how long a signed stream URL stays valid by default
+	defaultStreamURLTTL = 15 * time.Minute
+	// maxStreamURLTTL is the longest validity a client can request
+	maxStreamURLTTL = 24 * time.Hour
+)
+
+// StreamController handles audio streaming HTTP requests
+type StreamController struct {
+	songRepo *repositories.SongRepository
+}
+
+// NewStreamController creates a new stream controller
+func NewStreamController() *StreamController {
+	return &StreamController{
+		songRepo: repositories.NewSongRepository(),
+	}
+}
+
+// CreateStreamURL handles POST /songs/{id}/stream-url
+// @Summary Create a signed stream URL
+// @Description Returns a short-lived signed URL to stream a song, usable by players that cannot send auth headers
+// @Tags songs
+// @Produce json
+// @Param id path int true "Song ID"
+// @Param ttl query int false "Validity in seconds (default 900, max 86400)"
+// @Success 200 {object} models.StreamURLResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /songs/{id}/stream-url [post]
+func (stc *StreamController) CreateStreamURL(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid song ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	ttl := defaultStreamURLTTL
+	if ttlStr := c.Query("ttl"); ttlStr != "" {
+		seconds, err := strconv.Atoi(ttlStr)
+		if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > maxStreamURLTTL {
+			errorResp := models.NewErrorResponse("Bad Request", 400, "TTL must be between 1 and 86400 seconds", c.Request.URL.Path)
+			c.JSON(http.StatusBadRequest, errorResp)
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	song, err := stc.songRepo.GetSongByID(uint(id))
+	if err != nil {
+		if err.Error() == "song not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Song not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve song", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if !song.HasAudio {
+		errorResp := models.NewErrorResponse("Not Found", 404, "Song has no audio", c.Request.URL.Path)
+		c.JSON(http.StatusNotFound, errorResp)
+		return
+	}
+
+	url, expires := signedStreamURL(song.ID, ttl)
+	response := models.StreamURLResponse{
+		Data: models.StreamURL{
+			URL:       url,
+			ExpiresAt: expires,
+		},
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// StreamSong handles GET /songs/{id}/stream
+// @Summary Stream a song's audio
+// @Description Serves the stored audio file through a signed URL. Supports Range requests (206 Partial Content) for seeking and ETag/Last-Modified conditional requests.
+// @Tags songs
+// @Produce audio/mpeg,audio/flac,audio/ogg
+// @Param id path int true "Song ID"
+// @Param expires query int true "Expiry as a Unix timestamp"
+// @Param signature query string true "URL signature"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Success 200 {file} binary
+// @Success 206 {file} binary
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 416 {object} models.ErrorResponse
+// @Router /songs/{id}/stream [get]
+func (stc *StreamController) StreamSong(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Invalid song ID", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Forbidden", 403, "Missing or invalid stream signature", c.Request.URL.Path)
+		c.JSON(http.StatusForbidden, errorResp)
+		return
+	}
+
+	streamPath := fmt.Sprintf("/songs/%d/stream", id)
+	if err := signing.Verify(streamPath, expires, c.Query("signature")); err != nil {
+		detail := "Missing or invalid stream signature"
+		if err == signing.ErrExpired {
+			detail = "Stream URL has expired"
+		}
+		errorResp := models.NewErrorResponse("Forbidden", 403, detail, c.Request.URL.Path)
+		c.JSON(http.StatusForbidden, errorResp)
+		return
+	}
+
+	song, err := stc.songRepo.GetSongByID(uint(id))
+	if err != nil {
+		if err.Error() == "song not found" {
+			errorResp := models.NewErrorResponse("Not Found", 404, "Song not found", c.Request.URL.Path)
+			c.JSON(http.StatusNotFound, errorResp)
+			return
+		}
+		errorResp := models.NewErrorResponse("Bad Request", 400, "Failed to retrieve song", c.Request.URL.Path)
+		c.JSON(http.StatusBadRequest, errorResp)
+		return
+	}
+
+	if !song.HasAudio {
+		errorResp := models.NewErrorResponse("Not Found", 404, "Song has no audio", c.Request.URL.Path)
+		c.JSON(http.StatusNotFound, errorResp)
+		return
+	}
+
+	blob, info, err := storage.Blobs.Open(song.AudioKey)
+	if err != nil {
+		errorResp := models.NewErrorResponse("Not Found", 404, "Song audio is not available", c.Request.URL.Path)
+		c.JSON(http.StatusNotFound, errorResp)
+		return
+	}
+	defer blob.Close()
+
+	// The content hash never changes for a stored file, so it makes a strong ETag.
+	// ServeContent takes care of Range, If-Range, If-None-Match and If-Modified-Since.
+	c.Header("Content-Type", song.AudioContentType)
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("ETag", `"`+song.AudioHash+`"`)
+	c.Header("Cache-Control", "private, max-age=3600")
+	http.ServeContent(c.Writer, c.Request, "", info.ModTime, blob)
+}
+
+// signedStreamURL returns the relative signed stream URL of a song and its expiry
+func signedStreamURL(songID uint, ttl time.Duration) (string, time.Time) {
+	streamPath := fmt.Sprintf("/songs/%d/stream", songID)
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	signature := signing.Sign(streamPath, expires)
+	return fmt.Sprintf("%s?expires=%d&signature=%s", streamPath, expires.Unix(), signature), expires
+}
@@ -95,6 +95,287 @@ func CreateTablesIfNotExist() error {
 		return fmt.Errorf("error creating playlist_songs table: %v", err)
 	}
 
+	// Add normalized key used for duplicate detection
+	_, err = DB.Exec(`
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS normalized_key VARCHAR(512);
+		CREATE INDEX IF NOT EXISTS idx_songs_normalized_key ON songs(normalized_key);
+	`)
+	if err != nil {
+		return fmt.Errorf("error adding songs normalized_key column: %v", err)
+	}
+
+	// Create song_lyrics table
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS song_lyrics (
+			song_id INTEGER PRIMARY KEY REFERENCES songs(id) ON DELETE CASCADE,
+			format VARCHAR(10) NOT NULL CHECK (format IN ('lrc', 'plain')),
+			raw TEXT NOT NULL,
+			plain_text TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_song_lyrics_plain_text ON song_lyrics USING GIN (to_tsvector('simple', plain_text));
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating song_lyrics table: %v", err)
+	}
+
+	// Add playlist cover columns
+	_, err = DB.Exec(`
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS cover_key VARCHAR(255);
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS cover_content_type VARCHAR(50);
+	`)
+	if err != nil {
+		return fmt.Errorf("error adding playlists cover columns: %v", err)
+	}
+
+	// Add song tag metadata and audio file columns
+	_, err = DB.Exec(`
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS album VARCHAR(255);
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS track_number INTEGER;
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS year INTEGER;
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS duration_ms INTEGER;
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS audio_key VARCHAR(255);
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS audio_hash CHAR(64);
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS audio_content_type VARCHAR(50);
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS audio_size BIGINT;
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS cover_key VARCHAR(255);
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS cover_content_type VARCHAR(50);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_songs_audio_hash ON songs(audio_hash) WHERE audio_hash IS NOT NULL;
+	`)
+	if err != nil {
+		return fmt.Errorf("error adding songs audio columns: %v", err)
+	}
+
+	// Add playlist creator column
+	_, err = DB.Exec(`
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS creator VARCHAR(255);
+	`)
+	if err != nil {
+		return fmt.Errorf("error adding playlists creator column: %v", err)
+	}
+
+	// Add library scanner source columns
+	_, err = DB.Exec(`
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS source_path TEXT;
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS source_hash CHAR(64);
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS source_size BIGINT;
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS source_mod_time TIMESTAMP WITH TIME ZONE;
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS source_missing_at TIMESTAMP WITH TIME ZONE;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_songs_source_path ON songs(source_path) WHERE source_path IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_songs_source_hash ON songs(source_hash) WHERE source_hash IS NOT NULL;
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS source_path TEXT;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_playlists_source_path ON playlists(source_path) WHERE source_path IS NOT NULL;
+	`)
+	if err != nil {
+		return fmt.Errorf("error adding library source columns: %v", err)
+	}
+
+	// Create playlist_share_links table
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS playlist_share_links (
+			id SERIAL PRIMARY KEY,
+			playlist_id INTEGER NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+			label VARCHAR(255),
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			revoked_at TIMESTAMP WITH TIME ZONE,
+			use_count BIGINT NOT NULL DEFAULT 0,
+			last_used_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_playlist_share_links_playlist_id ON playlist_share_links(playlist_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating playlist_share_links table: %v", err)
+	}
+
+	// Add playlist fork provenance
+	_, err = DB.Exec(`
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS forked_from INTEGER REFERENCES playlists(id) ON DELETE SET NULL;
+		CREATE INDEX IF NOT EXISTS idx_playlists_forked_from ON playlists(forked_from);
+	`)
+	if err != nil {
+		return fmt.Errorf("error adding playlists forked_from column: %v", err)
+	}
+
+	// Create plays table, partitioned by month
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS plays (
+			id BIGSERIAL,
+			listener_id VARCHAR(255) NOT NULL,
+			song_id INTEGER NOT NULL,
+			song_title VARCHAR(255) NOT NULL,
+			song_artist VARCHAR(255) NOT NULL,
+			playlist_id INTEGER,
+			started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			ms_played INTEGER NOT NULL CHECK (ms_played >= 0),
+			completed BOOLEAN NOT NULL DEFAULT false,
+			skipped BOOLEAN NOT NULL DEFAULT false,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (id, started_at)
+		) PARTITION BY RANGE (started_at);
+		CREATE INDEX IF NOT EXISTS idx_plays_listener_started_at ON plays(listener_id, started_at DESC, id DESC);
+		CREATE INDEX IF NOT EXISTS idx_plays_song_id ON plays(song_id);
+		CREATE OR REPLACE FUNCTION reject_play_changes() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'UPDATE' AND to_jsonb(NEW) - 'song_id' = to_jsonb(OLD) - 'song_id' THEN
+				RETURN NEW;
+			END IF;
+			RAISE EXCEPTION 'plays are append-only';
+		END;
+		$$ LANGUAGE plpgsql;
+		CREATE OR REPLACE TRIGGER plays_append_only
+			BEFORE UPDATE OR DELETE ON plays
+			FOR EACH ROW EXECUTE FUNCTION reject_play_changes();
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating plays table: %v", err)
+	}
+
+	// Create play rollup tables for charts
+	_, err = DB.Exec(`
+		ALTER TABLE plays ADD COLUMN IF NOT EXISTS playlist_name VARCHAR(255);
+		CREATE TABLE IF NOT EXISTS play_rollups (
+			kind VARCHAR(10) NOT NULL,
+			day DATE NOT NULL,
+			item_id TEXT NOT NULL,
+			title TEXT NOT NULL,
+			subtitle TEXT NOT NULL DEFAULT '',
+			play_count BIGINT NOT NULL,
+			PRIMARY KEY (kind, day, item_id)
+		);
+		CREATE TABLE IF NOT EXISTS play_rollup_state (
+			id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+			refreshed_until TIMESTAMP WITH TIME ZONE NOT NULL
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating play rollup tables: %v", err)
+	}
+
+	// Create play queue tables
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS play_queues (
+			listener_id VARCHAR(255) PRIMARY KEY,
+			current_index INTEGER NOT NULL DEFAULT -1,
+			shuffle BOOLEAN NOT NULL DEFAULT false,
+			repeat_mode VARCHAR(3) NOT NULL DEFAULT 'off',
+			next_item_id BIGINT NOT NULL DEFAULT 1,
+			version BIGINT NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS play_queue_items (
+			listener_id VARCHAR(255) NOT NULL REFERENCES play_queues(listener_id) ON DELETE CASCADE,
+			item_id BIGINT NOT NULL,
+			song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+			play_index INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			PRIMARY KEY (listener_id, item_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_play_queue_items_song_id ON play_queue_items(song_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating play queue tables: %v", err)
+	}
+
+	// Create song_similarities table
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS song_similarities (
+			song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+			related_song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+			co_occurrences INTEGER NOT NULL,
+			jaccard DOUBLE PRECISION NOT NULL,
+			pmi DOUBLE PRECISION NOT NULL,
+			PRIMARY KEY (song_id, related_song_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_song_similarities_related_song_id ON song_similarities(related_song_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating song_similarities table: %v", err)
+	}
+
+	// Add song genres and smart playlist rules
+	_, err = DB.Exec(`
+		ALTER TABLE songs ADD COLUMN IF NOT EXISTS genre VARCHAR(255);
+		CREATE INDEX IF NOT EXISTS idx_songs_genre ON songs(lower(genre));
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS rules JSONB;
+	`)
+	if err != nil {
+		return fmt.Errorf("error adding smart playlist columns: %v", err)
+	}
+
+	// Create radio_stations table
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS radio_stations (
+			id SERIAL PRIMARY KEY,
+			seed_type VARCHAR(10) NOT NULL CHECK (seed_type IN ('song', 'artist', 'playlist')),
+			seed VARCHAR(255) NOT NULL,
+			seed_song_ids INTEGER[] NOT NULL,
+			recent_song_ids INTEGER[] NOT NULL DEFAULT '{}',
+			last_artist VARCHAR(255) NOT NULL DEFAULT '',
+			artist_run INTEGER NOT NULL DEFAULT 0,
+			rng_state BYTEA NOT NULL,
+			played BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating radio_stations table: %v", err)
+	}
+
+	// Create playlist_shuffles table
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS playlist_shuffles (
+			playlist_id INTEGER PRIMARY KEY REFERENCES playlists(id) ON DELETE CASCADE,
+			seed VARCHAR(64) NOT NULL,
+			song_ids INTEGER[] NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating playlist_shuffles table: %v", err)
+	}
+
+	// Create listen_tokens table
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS listen_tokens (
+			id SERIAL PRIMARY KEY,
+			listener_id VARCHAR(255) NOT NULL,
+			token_hash CHAR(64) NOT NULL UNIQUE,
+			create_missing BOOLEAN NOT NULL DEFAULT false,
+			last_used_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_listen_tokens_listener_id ON listen_tokens(listener_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating listen_tokens table: %v", err)
+	}
+
+	// Create song_likes and playlist_likes tables
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS song_likes (
+			listener_id VARCHAR(255) NOT NULL,
+			song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (listener_id, song_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_song_likes_listener_created_at ON song_likes(listener_id, created_at DESC, song_id DESC);
+		CREATE INDEX IF NOT EXISTS idx_song_likes_song_id ON song_likes(song_id);
+
+		CREATE TABLE IF NOT EXISTS playlist_likes (
+			listener_id VARCHAR(255) NOT NULL,
+			playlist_id INTEGER NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (listener_id, playlist_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_playlist_likes_playlist_id ON playlist_likes(playlist_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating likes tables: %v", err)
+	}
+
 	log.Println("Database tables created successfully")
 	return nil
 }
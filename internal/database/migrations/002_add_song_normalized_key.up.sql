@@ -0,0 +1,4 @@
+-- Normalized "artist|title" key used to detect duplicate songs
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS normalized_key VARCHAR(512);
+
+CREATE INDEX IF NOT EXISTS idx_songs_normalized_key ON songs(normalized_key);
@@ -0,0 +1,12 @@
+-- Create song_lyrics table (one lyrics document per song)
+CREATE TABLE IF NOT EXISTS song_lyrics (
+    song_id INTEGER PRIMARY KEY REFERENCES songs(id) ON DELETE CASCADE,
+    format VARCHAR(10) NOT NULL CHECK (format IN ('lrc', 'plain')),
+    raw TEXT NOT NULL,
+    plain_text TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Full text index used by lyrics search
+CREATE INDEX IF NOT EXISTS idx_song_lyrics_plain_text ON song_lyrics USING GIN (to_tsvector('simple', plain_text));
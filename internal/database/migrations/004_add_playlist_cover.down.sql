@@ -0,0 +1,2 @@
+ALTER TABLE playlists DROP COLUMN IF EXISTS cover_content_type;
+ALTER TABLE playlists DROP COLUMN IF EXISTS cover_key;
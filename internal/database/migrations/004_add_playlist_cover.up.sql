@@ -0,0 +1,3 @@
+-- Blob storage key prefix and MIME type of the playlist cover image
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS cover_key VARCHAR(255);
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS cover_content_type VARCHAR(50);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_songs_audio_hash;
+ALTER TABLE songs DROP COLUMN IF EXISTS cover_content_type;
+ALTER TABLE songs DROP COLUMN IF EXISTS cover_key;
+ALTER TABLE songs DROP COLUMN IF EXISTS audio_size;
+ALTER TABLE songs DROP COLUMN IF EXISTS audio_content_type;
+ALTER TABLE songs DROP COLUMN IF EXISTS audio_hash;
+ALTER TABLE songs DROP COLUMN IF EXISTS audio_key;
+ALTER TABLE songs DROP COLUMN IF EXISTS duration_ms;
+ALTER TABLE songs DROP COLUMN IF EXISTS year;
+ALTER TABLE songs DROP COLUMN IF EXISTS track_number;
+ALTER TABLE songs DROP COLUMN IF EXISTS album;
@@ -0,0 +1,16 @@
+-- Metadata read from audio tags
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS album VARCHAR(255);
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS track_number INTEGER;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS year INTEGER;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS duration_ms INTEGER;
+
+-- Uploaded audio file and embedded cover, stored in blob storage
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS audio_key VARCHAR(255);
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS audio_hash CHAR(64);
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS audio_content_type VARCHAR(50);
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS audio_size BIGINT;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS cover_key VARCHAR(255);
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS cover_content_type VARCHAR(50);
+
+-- Identical uploads are deduplicated by content hash
+CREATE UNIQUE INDEX IF NOT EXISTS idx_songs_audio_hash ON songs(audio_hash) WHERE audio_hash IS NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE playlists DROP COLUMN IF EXISTS creator;
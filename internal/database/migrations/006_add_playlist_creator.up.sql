@@ -0,0 +1,2 @@
+-- Author of the playlist as recorded by interchange formats such as XSPF
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS creator VARCHAR(255);
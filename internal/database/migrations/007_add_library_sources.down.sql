@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_playlists_source_path;
+ALTER TABLE playlists DROP COLUMN IF EXISTS source_path;
+DROP INDEX IF EXISTS idx_songs_source_hash;
+DROP INDEX IF EXISTS idx_songs_source_path;
+ALTER TABLE songs DROP COLUMN IF EXISTS source_missing_at;
+ALTER TABLE songs DROP COLUMN IF EXISTS source_mod_time;
+ALTER TABLE songs DROP COLUMN IF EXISTS source_size;
+ALTER TABLE songs DROP COLUMN IF EXISTS source_hash;
+ALTER TABLE songs DROP COLUMN IF EXISTS source_path;
@@ -0,0 +1,12 @@
+-- File a song was scanned from, identified by path and content hash
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS source_path TEXT;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS source_hash CHAR(64);
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS source_size BIGINT;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS source_mod_time TIMESTAMP WITH TIME ZONE;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS source_missing_at TIMESTAMP WITH TIME ZONE;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_songs_source_path ON songs(source_path) WHERE source_path IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_songs_source_hash ON songs(source_hash) WHERE source_hash IS NOT NULL;
+
+-- Playlist file a playlist was scanned from
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS source_path TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_playlists_source_path ON playlists(source_path) WHERE source_path IS NOT NULL;
@@ -0,0 +1,13 @@
+-- Signed links giving read-only access to a playlist, published or not
+CREATE TABLE IF NOT EXISTS playlist_share_links (
+    id SERIAL PRIMARY KEY,
+    playlist_id INTEGER NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+    label VARCHAR(255),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    use_count BIGINT NOT NULL DEFAULT 0,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_playlist_share_links_playlist_id ON playlist_share_links(playlist_id);
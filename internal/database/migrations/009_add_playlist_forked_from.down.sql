@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_playlists_forked_from;
+ALTER TABLE playlists DROP COLUMN IF EXISTS forked_from;
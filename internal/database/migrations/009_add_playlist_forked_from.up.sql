@@ -0,0 +1,3 @@
+-- Playlist a playlist was duplicated from; forks outlive the original
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS forked_from INTEGER REFERENCES playlists(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_playlists_forked_from ON playlists(forked_from);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS plays;
+DROP FUNCTION IF EXISTS reject_play_changes();
@@ -0,0 +1,34 @@
+-- Play events, append-only and partitioned by month of started_at. Songs and
+-- playlists are not foreign keys so history outlives them; title and artist are
+-- copied so deleted songs still read well.
+CREATE TABLE IF NOT EXISTS plays (
+    id BIGSERIAL,
+    listener_id VARCHAR(255) NOT NULL,
+    song_id INTEGER NOT NULL,
+    song_title VARCHAR(255) NOT NULL,
+    song_artist VARCHAR(255) NOT NULL,
+    playlist_id INTEGER,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ms_played INTEGER NOT NULL CHECK (ms_played >= 0),
+    completed BOOLEAN NOT NULL DEFAULT false,
+    skipped BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id, started_at)
+) PARTITION BY RANGE (started_at);
+
+CREATE INDEX IF NOT EXISTS idx_plays_listener_started_at ON plays(listener_id, started_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_plays_song_id ON plays(song_id);
+
+-- The only change allowed is moving plays to another song when songs are merged
+CREATE OR REPLACE FUNCTION reject_play_changes() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND to_jsonb(NEW) - 'song_id' = to_jsonb(OLD) - 'song_id' THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'plays are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER plays_append_only
+    BEFORE UPDATE OR DELETE ON plays
+    FOR EACH ROW EXECUTE FUNCTION reject_play_changes();
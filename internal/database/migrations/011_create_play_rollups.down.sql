@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS play_rollup_state;
+DROP TABLE IF EXISTS play_rollups;
+ALTER TABLE plays DROP COLUMN IF EXISTS playlist_name;
@@ -0,0 +1,22 @@
+-- Playlist names are copied into plays like song titles, so charts show the
+-- name a playlist had when it was played
+ALTER TABLE plays ADD COLUMN IF NOT EXISTS playlist_name VARCHAR(255);
+
+-- Daily play counts per song, artist and playlist, rebuilt from plays by the
+-- chart refresh job
+CREATE TABLE IF NOT EXISTS play_rollups (
+    kind VARCHAR(10) NOT NULL,
+    day DATE NOT NULL,
+    item_id TEXT NOT NULL,
+    title TEXT NOT NULL,
+    subtitle TEXT NOT NULL DEFAULT '',
+    play_count BIGINT NOT NULL,
+    PRIMARY KEY (kind, day, item_id)
+);
+
+-- Plays created up to refreshed_until are reflected in play_rollups. Each
+-- refresh also rechecks the hour before it, for plays committed late.
+CREATE TABLE IF NOT EXISTS play_rollup_state (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    refreshed_until TIMESTAMP WITH TIME ZONE NOT NULL
+);
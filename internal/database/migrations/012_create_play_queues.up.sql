@@ -0,0 +1,24 @@
+-- Play queue of each listener. current_index is the play_index of the playing
+-- item, or -1; if that item is gone the following one is playing.
+CREATE TABLE IF NOT EXISTS play_queues (
+    listener_id VARCHAR(255) PRIMARY KEY,
+    current_index INTEGER NOT NULL DEFAULT -1,
+    shuffle BOOLEAN NOT NULL DEFAULT false,
+    repeat_mode VARCHAR(3) NOT NULL DEFAULT 'off',
+    next_item_id BIGINT NOT NULL DEFAULT 1,
+    version BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- play_index is the place in play order and position the place in the order
+-- songs were queued in, restored when shuffle is turned off
+CREATE TABLE IF NOT EXISTS play_queue_items (
+    listener_id VARCHAR(255) NOT NULL REFERENCES play_queues(listener_id) ON DELETE CASCADE,
+    item_id BIGINT NOT NULL,
+    song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    play_index INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (listener_id, item_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_play_queue_items_song_id ON play_queue_items(song_id);
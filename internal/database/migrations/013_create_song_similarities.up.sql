@@ -0,0 +1,12 @@
+-- Song pairs put together in published playlists, rebuilt by the similarity job.
+-- Each pair is stored in both directions.
+CREATE TABLE IF NOT EXISTS song_similarities (
+    song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    related_song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    co_occurrences INTEGER NOT NULL,
+    jaccard DOUBLE PRECISION NOT NULL,
+    pmi DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (song_id, related_song_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_song_similarities_related_song_id ON song_similarities(related_song_id);
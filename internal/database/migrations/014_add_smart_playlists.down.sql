@@ -0,0 +1,3 @@
+ALTER TABLE playlists DROP COLUMN IF EXISTS rules;
+DROP INDEX IF EXISTS idx_songs_genre;
+ALTER TABLE songs DROP COLUMN IF EXISTS genre;
@@ -0,0 +1,6 @@
+-- Genre of a song, from its tags or set by hand
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS genre VARCHAR(255);
+CREATE INDEX IF NOT EXISTS idx_songs_genre ON songs(lower(genre));
+
+-- Rules of smart playlists, whose songs are selected when read instead of stored
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS rules JSONB;
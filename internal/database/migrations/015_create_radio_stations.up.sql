@@ -0,0 +1,17 @@
+-- Radio stations. seed_song_ids are the songs the seed expanded to when the
+-- station was created; recent_song_ids the latest songs played, oldest first,
+-- the last one being where the walk continues from. rng_state is the state of
+-- the random generator so a station picks up where it left off.
+CREATE TABLE IF NOT EXISTS radio_stations (
+    id SERIAL PRIMARY KEY,
+    seed_type VARCHAR(10) NOT NULL CHECK (seed_type IN ('song', 'artist', 'playlist')),
+    seed VARCHAR(255) NOT NULL,
+    seed_song_ids INTEGER[] NOT NULL,
+    recent_song_ids INTEGER[] NOT NULL DEFAULT '{}',
+    last_artist VARCHAR(255) NOT NULL DEFAULT '',
+    artist_run INTEGER NOT NULL DEFAULT 0,
+    rng_state BYTEA NOT NULL,
+    played BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,7 @@
+-- Stored shuffled order of a playlist, shared by every device of its listeners
+CREATE TABLE IF NOT EXISTS playlist_shuffles (
+    playlist_id INTEGER PRIMARY KEY REFERENCES playlists(id) ON DELETE CASCADE,
+    seed VARCHAR(64) NOT NULL,
+    song_ids INTEGER[] NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
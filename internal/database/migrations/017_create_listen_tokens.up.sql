@@ -0,0 +1,12 @@
+-- Tokens letting ListenBrainz clients submit listens on behalf of a listener.
+-- Only a SHA-256 digest of each token is stored.
+CREATE TABLE IF NOT EXISTS listen_tokens (
+    id SERIAL PRIMARY KEY,
+    listener_id VARCHAR(255) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    create_missing BOOLEAN NOT NULL DEFAULT false,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_listen_tokens_listener_id ON listen_tokens(listener_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS playlist_likes;
+DROP TABLE IF EXISTS song_likes;
@@ -0,0 +1,19 @@
+-- Songs and playlists liked by each listener
+CREATE TABLE IF NOT EXISTS song_likes (
+    listener_id VARCHAR(255) NOT NULL,
+    song_id INTEGER NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (listener_id, song_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_song_likes_listener_created_at ON song_likes(listener_id, created_at DESC, song_id DESC);
+CREATE INDEX IF NOT EXISTS idx_song_likes_song_id ON song_likes(song_id);
+
+CREATE TABLE IF NOT EXISTS playlist_likes (
+    listener_id VARCHAR(255) NOT NULL,
+    playlist_id INTEGER NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (listener_id, playlist_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_playlist_likes_playlist_id ON playlist_likes(playlist_id);
@@ -0,0 +1,115 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxDimension is the largest width or height accepted, guarding against decompression bombs
+	MaxDimension = 8000
+
+	// thumbnailQuality is the JPEG quality used for generated thumbnails
+	thumbnailQuality = 85
+)
+
+// Decode validates that data holds a JPEG or PNG image within the allowed
+// dimensions and decodes it. It returns the image and its MIME type.
+func Decode(data []byte) (image.Image, string, error) {
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return nil, "", fmt.Errorf("unsupported image type: %s", contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid image: %v", err)
+	}
+
+	if config.Width > MaxDimension || config.Height > MaxDimension {
+		return nil, "", fmt.Errorf("image dimensions %dx%d exceed %dx%d", config.Width, config.Height, MaxDimension, MaxDimension)
+	}
+
+	var img image.Image
+	if contentType == "image/png" {
+		img, err = png.Decode(bytes.NewReader(data))
+	} else {
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid image: %v", err)
+	}
+
+	return img, contentType, nil
+}
+
+// Thumbnail center-crops img to a square and scales it to size x size,
+// averaging every source pixel that falls into each destination pixel
+func Thumbnail(img image.Image, size int) *image.RGBA {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+
+	// Work on an RGBA copy so pixels can be read directly from Pix
+	src := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(src, src.Bounds(), img, crop.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for dy := 0; dy < size; dy++ {
+		y0, y1 := span(dy, size, side)
+		for dx := 0; dx < size; dx++ {
+			x0, x1 := span(dx, size, side)
+
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride:]
+				for x := x0; x < x1; x++ {
+					p := row[x*4 : x*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+
+			o := dst.PixOffset(dx, dy)
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(b / n)
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// span returns the source range covered by destination index i, always at least one pixel wide
+func span(i, dstSize, srcSize int) (int, int) {
+	start := i * srcSize / dstSize
+	end := (i + 1) * srcSize / dstSize
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+// EncodeJPEG encodes img as a JPEG thumbnail
+func EncodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("error encoding thumbnail: %v", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,72 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Expected no error encoding test image, got %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+
+	_, contentType, err := Decode(encodePNG(t, img))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if contentType != "image/png" {
+		t.Errorf("Expected image/png, got %s", contentType)
+	}
+
+	if _, _, err := Decode([]byte("not an image")); err == nil {
+		t.Error("Expected error for non image data")
+	}
+}
+
+func TestThumbnailCropsAndScales(t *testing.T) {
+	// 200x100: left half red, right half blue. The centered square crop is half red, half blue.
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			if x < 100 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+
+	thumb := Thumbnail(img, 10)
+
+	if thumb.Bounds().Dx() != 10 || thumb.Bounds().Dy() != 10 {
+		t.Fatalf("Expected 10x10 thumbnail, got %v", thumb.Bounds())
+	}
+
+	left := thumb.RGBAAt(0, 5)
+	right := thumb.RGBAAt(9, 5)
+	if left.R != 255 || left.B != 0 {
+		t.Errorf("Expected left edge to be red, got %+v", left)
+	}
+	if right.B != 255 || right.R != 0 {
+		t.Errorf("Expected right edge to be blue, got %+v", right)
+	}
+}
+
+func TestThumbnailUpscalesSmallImages(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+
+	thumb := Thumbnail(img, 64)
+
+	if thumb.Bounds().Dx() != 64 {
+		t.Errorf("Expected 64px thumbnail, got %v", thumb.Bounds())
+	}
+}
@@ -0,0 +1,29 @@
+// Package jobs runs periodic background work inside the server
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every runs fn right away and then every interval until ctx is done. Runs never
+// overlap: a slow run delays the next one. Errors are logged and the job keeps
+// going.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		if err := fn(ctx); err != nil {
+			log.Printf("Job %s failed after %v: %v", name, time.Since(start).Round(time.Millisecond), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
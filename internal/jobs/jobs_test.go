@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEvery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := make(chan struct{}, 10)
+
+	done := make(chan struct{})
+	go func() {
+		Every(ctx, "test", 10*time.Millisecond, func(ctx context.Context) error {
+			runs <- struct{}{}
+			// A failing run must not stop the job
+			return errors.New("failed")
+		})
+		close(done)
+	}()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected run %d", i+1)
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected job to stop when the context is done")
+	}
+}
@@ -0,0 +1,466 @@
+// Package library builds the song catalog from a folder of audio files
+package library
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"melodia/internal/audio"
+	"melodia/internal/models"
+	"melodia/internal/normalize"
+	"melodia/internal/playlistformat"
+	"melodia/internal/repositories"
+)
+
+const (
+	// maxTextLength is the longest title, artist, album or genre stored, in characters
+	maxTextLength = 255
+	// maxPlaylistFileSize is the largest playlist file read, in bytes
+	maxPlaylistFileSize = 5 << 20
+	// unknownArtist is stored for files without an artist tag
+	unknownArtist = "Unknown Artist"
+)
+
+// audioExtensions lists the file extensions read as audio
+var audioExtensions = map[string]bool{
+	".mp3":  true,
+	".flac": true,
+	".ogg":  true,
+	".oga":  true,
+	".opus": true,
+}
+
+// playlistExtensions lists the file extensions read as playlists
+var playlistExtensions = map[string]bool{
+	".m3u":  true,
+	".m3u8": true,
+}
+
+// Summary reports the outcome of a scan
+type Summary struct {
+	Files            int
+	Created          int
+	Updated          int
+	Linked           int
+	Moved            int
+	Unchanged        int
+	Duplicates       int
+	Unsupported      int
+	Failed           int
+	Missing          int64
+	Playlists        int
+	PlaylistsCreated int
+	PlaylistsUpdated int
+	Duration         time.Duration
+}
+
+// Scanner walks a music folder and mirrors it into the catalog
+type Scanner struct {
+	repo    *repositories.LibraryRepository
+	workers int
+}
+
+// NewScanner creates a scanner reading up to workers files at a time, or one per
+// CPU when workers is not positive
+func NewScanner(workers int) *Scanner {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &Scanner{
+		repo:    repositories.NewLibraryRepository(),
+		workers: workers,
+	}
+}
+
+// fileResult is what a worker learned about one audio file
+type fileResult struct {
+	path    string
+	size    int64
+	modTime time.Time
+	// known is the stored source of the file, if it was scanned before
+	known *repositories.SongSource
+	// hash and metadata are empty when the file is known and its size and
+	// modification time did not change
+	hash     string
+	metadata *audio.Metadata
+	err      error
+}
+
+// Scan walks root, creating and updating songs from its audio files and playlists
+// from its M3U files, and flags the songs whose files are gone. Files are read
+// concurrently while database writes happen one at a time, so a scan never races
+// itself on duplicate detection.
+func (s *Scanner) Scan(ctx context.Context, root string) (*Summary, error) {
+	start := time.Now()
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving %s: %v", root, err)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	prefix := strings.TrimSuffix(root, string(filepath.Separator)) + string(filepath.Separator)
+
+	sources, err := s.repo.GetSongSources(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(chan string)
+	results := make(chan fileResult)
+	var playlists []string
+	var walkErr error
+	// incomplete is set when part of the tree could not be read, so files below
+	// it are not mistaken for missing ones
+	incomplete := false
+
+	go func() {
+		defer close(paths)
+		walkErr = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				log.Printf("Skipping %s: %v", path, err)
+				incomplete = true
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if path != root && strings.HasPrefix(entry.Name(), ".") {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+
+			ext := strings.ToLower(filepath.Ext(path))
+			switch {
+			case audioExtensions[ext]:
+				paths <- path
+			case playlistExtensions[ext]:
+				playlists = append(playlists, path)
+			}
+			return nil
+		})
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for path := range paths {
+				var known *repositories.SongSource
+				if source, ok := sources[path]; ok {
+					known = &source
+				}
+				results <- inspectFile(path, known)
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	summary := &Summary{}
+	seen := make([]string, 0, len(sources))
+	var applyErr error
+	for result := range results {
+		summary.Files++
+		seen = append(seen, result.path)
+		if applyErr != nil {
+			continue
+		}
+		if err := s.apply(result, summary); err != nil {
+			// Keep draining so the walker and workers can finish
+			applyErr = err
+		}
+	}
+
+	if applyErr != nil {
+		return nil, applyErr
+	}
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	if incomplete {
+		log.Println("Some folders could not be read, skipping the check for missing files")
+	} else if summary.Missing, err = s.repo.MarkMissingSongs(prefix, seen); err != nil {
+		return nil, err
+	}
+
+	for _, path := range playlists {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err := s.syncPlaylist(path, root, summary); err != nil {
+			log.Printf("Failed to import playlist %s: %v", path, err)
+		}
+	}
+
+	summary.Duration = time.Since(start)
+	return summary, nil
+}
+
+// apply stores what was learned about a file
+func (s *Scanner) apply(result fileResult, summary *Summary) error {
+	if result.err != nil {
+		if errors.Is(result.err, audio.ErrUnsupportedFormat) {
+			summary.Unsupported++
+			return nil
+		}
+		log.Printf("Failed to read %s: %v", result.path, result.err)
+		summary.Failed++
+		return nil
+	}
+
+	source := repositories.SongSource{
+		Path:    result.path,
+		Hash:    result.hash,
+		Size:    result.size,
+		ModTime: result.modTime,
+	}
+
+	if known := result.known; known != nil {
+		source.SongID = known.SongID
+		switch {
+		case result.hash == "":
+			// Same size and modification time, only a missing mark may need clearing
+			summary.Unchanged++
+			if !known.Missing {
+				return nil
+			}
+			source.Hash = known.Hash
+			return s.repo.TouchSongSource(source)
+		case result.hash == known.Hash:
+			summary.Unchanged++
+			return s.repo.TouchSongSource(source)
+		default:
+			summary.Updated++
+			return s.repo.UpdateScannedSong(songFromMetadata(result.path, result.metadata), source)
+		}
+	}
+
+	// A file with known content at a new path was moved or renamed
+	previous, err := s.repo.FindSongSourceByHash(result.hash)
+	if err != nil && err.Error() != "song not found" {
+		return err
+	}
+	if previous != nil {
+		if _, statErr := os.Stat(previous.Path); previous.Missing || errors.Is(statErr, fs.ErrNotExist) {
+			source.SongID = previous.SongID
+			summary.Moved++
+			return s.repo.TouchSongSource(source)
+		}
+		log.Printf("Skipping %s: same content as %s", result.path, previous.Path)
+		summary.Duplicates++
+		return nil
+	}
+
+	song := songFromMetadata(result.path, result.metadata)
+	id, scanned, err := s.repo.FindSongByKey(normalize.SongKey(song.Title, song.Artist))
+	switch {
+	case err == nil && scanned:
+		log.Printf("Skipping %s: song %d has the same title and artist", result.path, id)
+		summary.Duplicates++
+		return nil
+	case err == nil:
+		// The song is already in the catalog without a file, attach this one to it
+		source.SongID = id
+		summary.Linked++
+		return s.repo.LinkSongSource(song, source)
+	case err.Error() != "song not found":
+		return err
+	}
+
+	summary.Created++
+	return s.repo.CreateScannedSong(song, source)
+}
+
+// syncPlaylist mirrors a playlist file into the catalog. Files that list no
+// scanned song are ignored.
+func (s *Scanner) syncPlaylist(path, root string, summary *Summary) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxPlaylistFileSize+1))
+	file.Close()
+	if err != nil {
+		return err
+	}
+	if len(data) > maxPlaylistFileSize {
+		return fmt.Errorf("playlist file exceeds 5MB")
+	}
+
+	doc, err := playlistformat.ParseM3U(data)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	var entryPaths []string
+	for _, entry := range doc.Entries {
+		if entryPath := resolveEntry(dir, entry.Location); entryPath != "" {
+			entryPaths = append(entryPaths, entryPath)
+		}
+	}
+	if len(entryPaths) == 0 {
+		return nil
+	}
+
+	ids, err := s.repo.GetSongIDsBySourcePaths(entryPaths)
+	if err != nil {
+		return err
+	}
+	var songIDs []uint
+	for _, entryPath := range entryPaths {
+		if id, ok := ids[entryPath]; ok {
+			songIDs = append(songIDs, id)
+		}
+	}
+	if len(songIDs) == 0 {
+		return nil
+	}
+
+	relative, err := filepath.Rel(root, path)
+	if err != nil {
+		relative = path
+	}
+	playlist := &models.Playlist{
+		Name:        truncate(firstNonEmpty(doc.Title, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))),
+		Description: truncate("Scanned from " + filepath.ToSlash(relative)),
+	}
+
+	summary.Playlists++
+	created, changed, err := s.repo.SyncPlaylistSource(path, playlist, songIDs)
+	if err != nil {
+		return err
+	}
+	switch {
+	case created:
+		summary.PlaylistsCreated++
+	case changed:
+		summary.PlaylistsUpdated++
+	}
+	return nil
+}
+
+// inspectFile stats a file and, unless its size and modification time match the
+// stored source, hashes it and reads its tags
+func inspectFile(path string, known *repositories.SongSource) fileResult {
+	result := fileResult{path: path, known: known}
+
+	file, err := os.Open(path)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.size = info.Size()
+	// The database keeps microseconds
+	result.modTime = info.ModTime().UTC().Truncate(time.Microsecond)
+
+	if known != nil && known.Size == result.size && known.ModTime.Equal(result.modTime) {
+		return result
+	}
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		result.err = err
+		return result
+	}
+	result.hash = hex.EncodeToString(hasher.Sum(nil))
+
+	// Unchanged content needs no tags
+	if known != nil && known.Hash == result.hash {
+		return result
+	}
+
+	result.metadata, result.err = audio.Read(file)
+	return result
+}
+
+// songFromMetadata builds the song for an audio file, falling back to the file
+// name for the title
+func songFromMetadata(path string, metadata *audio.Metadata) *models.Song {
+	fileTitle := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return &models.Song{
+		Title:       truncate(firstNonEmpty(metadata.Title, fileTitle)),
+		Artist:      truncate(firstNonEmpty(metadata.Artist, unknownArtist)),
+		Album:       truncate(strings.TrimSpace(metadata.Album)),
+		Genre:       truncate(strings.TrimSpace(metadata.Genre)),
+		TrackNumber: metadata.TrackNumber,
+		Year:        metadata.Year,
+		DurationMs:  metadata.DurationMs,
+	}
+}
+
+// resolveEntry turns the location of a playlist entry into a clean absolute path,
+// or "" for remote entries
+func resolveEntry(dir, location string) string {
+	if location == "" {
+		return ""
+	}
+	if strings.Contains(location, "://") {
+		parsed, err := url.Parse(location)
+		if err != nil || parsed.Scheme != "file" {
+			return ""
+		}
+		location = parsed.Path
+	}
+
+	// Playlists written on Windows use backslashes
+	location = filepath.FromSlash(strings.ReplaceAll(location, `\`, "/"))
+	if !filepath.IsAbs(location) {
+		location = filepath.Join(dir, location)
+	}
+	return filepath.Clean(location)
+}
+
+// firstNonEmpty returns the first value that is not blank
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// truncate cuts text to maxTextLength characters
+func truncate(text string) string {
+	if utf8.RuneCountInString(text) <= maxTextLength {
+		return text
+	}
+	return string([]rune(text)[:maxTextLength])
+}
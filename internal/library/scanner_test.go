@@ -0,0 +1,78 @@
+package library
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"melodia/internal/audio"
+	"melodia/internal/repositories"
+)
+
+func TestResolveEntry(t *testing.T) {
+	dir := filepath.FromSlash("/music/rock")
+	tests := []struct {
+		location string
+		expected string
+	}{
+		{"queen/bohemian.mp3", "/music/rock/queen/bohemian.mp3"},
+		{"../pop/song.flac", "/music/pop/song.flac"},
+		{`Queen\Bohemian.mp3`, "/music/rock/Queen/Bohemian.mp3"},
+		{"/other/track.ogg", "/other/track.ogg"},
+		{"file:///other/with%20space.mp3", "/other/with space.mp3"},
+		{"http://example.com/stream.mp3", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		expected := test.expected
+		if expected != "" {
+			expected = filepath.FromSlash(expected)
+		}
+		if got := resolveEntry(dir, test.location); got != expected {
+			t.Errorf("resolveEntry(%q) = %q, expected %q", test.location, got, expected)
+		}
+	}
+}
+
+func TestSongFromMetadata(t *testing.T) {
+	song := songFromMetadata("/music/01 Intro.mp3", &audio.Metadata{Album: " Album ", Year: 1999})
+	if song.Title != "01 Intro" || song.Artist != unknownArtist || song.Album != "Album" || song.Year != 1999 {
+		t.Errorf("Expected file name fallbacks, got %+v", song)
+	}
+
+	long := strings.Repeat("á", 300)
+	song = songFromMetadata("/music/x.mp3", &audio.Metadata{Title: long, Artist: "Artist"})
+	if len([]rune(song.Title)) != maxTextLength {
+		t.Errorf("Expected title truncated to %d characters, got %d", maxTextLength, len([]rune(song.Title)))
+	}
+}
+
+func TestInspectFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.mp3")
+	if err := os.WriteFile(path, []byte("not really audio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := inspectFile(path, nil)
+	if result.hash == "" || result.size != 16 {
+		t.Fatalf("Expected new file to be hashed, got %+v", result)
+	}
+	if !errors.Is(result.err, audio.ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", result.err)
+	}
+
+	// Known files with the same size and modification time are not read again
+	known := &repositories.SongSource{SongID: 1, Path: path, Hash: "old", Size: result.size, ModTime: result.modTime}
+	if again := inspectFile(path, known); again.hash != "" || again.err != nil {
+		t.Errorf("Expected unchanged file to be skipped, got %+v", again)
+	}
+
+	// Known content is not parsed again even when the modification time changed
+	known = &repositories.SongSource{SongID: 1, Path: path, Hash: result.hash, Size: result.size}
+	if again := inspectFile(path, known); again.hash != result.hash || again.err != nil || again.metadata != nil {
+		t.Errorf("Expected known content to skip tag reading, got %+v", again)
+	}
+}
@@ -0,0 +1,181 @@
+// Package listenbrainz reads the listen submissions of the ListenBrainz API, so
+// players that scrobble to ListenBrainz can report plays by pointing them at
+// this server. Only the parts of the API needed to submit listens are covered.
+package listenbrainz
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Listen types of a submission
+const (
+	ListenTypeSingle     = "single"
+	ListenTypePlayingNow = "playing_now"
+	ListenTypeImport     = "import"
+)
+
+const (
+	// MaxListensPerRequest is the most listens an import may carry
+	MaxListensPerRequest = 1000
+	// MaxListenSize is the largest accepted listen, in bytes of JSON
+	MaxListenSize = 10240
+	// MaxFieldLength is the longest accepted artist, track or release name
+	MaxFieldLength = 255
+)
+
+// MinListenedAt is the earliest listen accepted, as ListenBrainz does
+var MinListenedAt = time.Date(2002, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+// Submission is the body of POST /1/submit-listens
+type Submission struct {
+	ListenType string   `json:"listen_type" enums:"single,playing_now,import"`
+	Payload    []Listen `json:"payload"`
+}
+
+// Listen is a listen of a submission. listened_at, in Unix seconds, is when the
+// track started playing and is absent from playing_now submissions.
+type Listen struct {
+	ListenedAt    *int64         `json:"listened_at,omitempty"`
+	TrackMetadata *TrackMetadata `json:"track_metadata"`
+}
+
+// TrackMetadata describes the track of a listen
+type TrackMetadata struct {
+	ArtistName     string                     `json:"artist_name"`
+	TrackName      string                     `json:"track_name"`
+	ReleaseName    string                     `json:"release_name,omitempty"`
+	AdditionalInfo map[string]json.RawMessage `json:"additional_info,omitempty" swaggertype:"object"`
+}
+
+// Validate checks a submission against the rules of ListenBrainz, returning an
+// error worded for the client when it breaks one. Listens may start up to skew
+// after now, to allow for clients with a fast clock.
+func Validate(s *Submission, now time.Time, skew time.Duration) error {
+	switch s.ListenType {
+	case "":
+		return fmt.Errorf("JSON document must contain the key listen_type at the top level")
+	case ListenTypeSingle, ListenTypePlayingNow:
+		if len(s.Payload) != 1 {
+			return fmt.Errorf("JSON document should contain exactly one listen in the payload for listen_type %s", s.ListenType)
+		}
+	case ListenTypeImport:
+		if len(s.Payload) == 0 {
+			return fmt.Errorf("JSON document does not contain any listens")
+		}
+		if len(s.Payload) > MaxListensPerRequest {
+			return fmt.Errorf("too many listens, you may not submit more than %d listens at once", MaxListensPerRequest)
+		}
+	default:
+		return fmt.Errorf("JSON document contains an invalid listen_type: %s", s.ListenType)
+	}
+
+	for i, listen := range s.Payload {
+		if err := validateListen(&listen, s.ListenType, now, skew); err != nil {
+			return fmt.Errorf("listen %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func validateListen(l *Listen, listenType string, now time.Time, skew time.Duration) error {
+	if encoded, err := json.Marshal(l); err == nil && len(encoded) > MaxListenSize {
+		return fmt.Errorf("listen exceeds the maximum size of %d bytes", MaxListenSize)
+	}
+
+	if listenType == ListenTypePlayingNow {
+		if l.ListenedAt != nil {
+			return fmt.Errorf("playing_now listens must not have a listened_at timestamp")
+		}
+	} else {
+		if l.ListenedAt == nil {
+			return fmt.Errorf("listened_at is required")
+		}
+		at := time.Unix(*l.ListenedAt, 0)
+		if at.Before(MinListenedAt) || at.After(now.Add(skew)) {
+			return fmt.Errorf("listened_at must be between %s and now", MinListenedAt.Format("2006-01-02"))
+		}
+	}
+
+	m := l.TrackMetadata
+	if m == nil {
+		return fmt.Errorf("track_metadata is required")
+	}
+	for _, field := range []struct{ name, value string }{
+		{"artist_name", m.ArtistName},
+		{"track_name", m.TrackName},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			return fmt.Errorf("track_metadata.%s is required", field.name)
+		}
+	}
+	for _, field := range []struct{ name, value string }{
+		{"artist_name", m.ArtistName},
+		{"track_name", m.TrackName},
+		{"release_name", m.ReleaseName},
+	} {
+		if utf8.RuneCountInString(field.value) > MaxFieldLength {
+			return fmt.Errorf("track_metadata.%s cannot exceed %d characters", field.name, MaxFieldLength)
+		}
+	}
+	return nil
+}
+
+// DurationMs returns the length of the track in milliseconds from the
+// duration_ms or duration (seconds) additional info, or 0 when neither is a
+// positive number of less than a day
+func (m *TrackMetadata) DurationMs() int64 {
+	const day = float64(24 * time.Hour / time.Millisecond)
+	if ms := number(m.AdditionalInfo["duration_ms"]); ms > 0 && ms < day {
+		return int64(ms)
+	}
+	if seconds := number(m.AdditionalInfo["duration"]); seconds > 0 && seconds*1000 < day {
+		return int64(seconds * 1000)
+	}
+	return 0
+}
+
+// number decodes a JSON number, returning 0 for anything else
+func number(raw json.RawMessage) float64 {
+	var value float64
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return 0
+	}
+	return value
+}
+
+// TokenFromHeader returns the token of an "Authorization: Token <token>"
+// header, or an empty string when the header has another form
+func TokenFromHeader(header string) string {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Token") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// NewToken returns a random token shaped like the UUIDs ListenBrainz hands out,
+// which some clients expect
+func NewToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
+
+// HashToken returns the digest under which a token is stored, so a leaked
+// database does not leak usable tokens
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,138 @@
+package listenbrainz
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// decode parses a submission the way the API receives it
+func decode(t *testing.T, body string) *Submission {
+	t.Helper()
+	var s Submission
+	if err := json.Unmarshal([]byte(body), &s); err != nil {
+		t.Fatalf("Expected a valid document, got %v", err)
+	}
+	return &s
+}
+
+func TestValidateAcceptsListenTypes(t *testing.T) {
+	listen := `{"listened_at":1767225600,"track_metadata":{"artist_name":"Queen","track_name":"Bohemian Rhapsody","release_name":"A Night at the Opera"}}`
+	bodies := []string{
+		`{"listen_type":"single","payload":[` + listen + `]}`,
+		`{"listen_type":"import","payload":[` + listen + `,` + listen + `]}`,
+		`{"listen_type":"playing_now","payload":[{"track_metadata":{"artist_name":"Queen","track_name":"Bohemian Rhapsody"}}]}`,
+	}
+	for _, body := range bodies {
+		if err := Validate(decode(t, body), now, time.Minute); err != nil {
+			t.Errorf("Expected %s to be valid, got %v", body, err)
+		}
+	}
+}
+
+func TestValidateRejectsInvalidSubmissions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"missing listen type", `{"payload":[]}`, "listen_type"},
+		{"unknown listen type", `{"listen_type":"bulk","payload":[]}`, "invalid listen_type"},
+		{"two singles", `{"listen_type":"single","payload":[{"listened_at":1767225600,"track_metadata":{"artist_name":"A","track_name":"B"}},{"listened_at":1767225600,"track_metadata":{"artist_name":"A","track_name":"B"}}]}`, "exactly one listen"},
+		{"empty import", `{"listen_type":"import","payload":[]}`, "any listens"},
+		{"missing timestamp", `{"listen_type":"single","payload":[{"track_metadata":{"artist_name":"A","track_name":"B"}}]}`, "listened_at is required"},
+		{"timestamp on playing now", `{"listen_type":"playing_now","payload":[{"listened_at":1767225600,"track_metadata":{"artist_name":"A","track_name":"B"}}]}`, "must not have"},
+		{"too old", `{"listen_type":"single","payload":[{"listened_at":1000000000,"track_metadata":{"artist_name":"A","track_name":"B"}}]}`, "listened_at must be"},
+		{"in the future", `{"listen_type":"single","payload":[{"listened_at":1800000000,"track_metadata":{"artist_name":"A","track_name":"B"}}]}`, "listened_at must be"},
+		{"missing metadata", `{"listen_type":"single","payload":[{"listened_at":1767225600}]}`, "track_metadata is required"},
+		{"missing artist", `{"listen_type":"single","payload":[{"listened_at":1767225600,"track_metadata":{"artist_name":" ","track_name":"B"}}]}`, "artist_name is required"},
+		{"long track name", `{"listen_type":"single","payload":[{"listened_at":1767225600,"track_metadata":{"artist_name":"A","track_name":"` + strings.Repeat("x", 256) + `"}}]}`, "track_name cannot exceed"},
+		{"oversized listen", `{"listen_type":"single","payload":[{"listened_at":1767225600,"track_metadata":{"artist_name":"A","track_name":"B","additional_info":{"notes":"` + strings.Repeat("x", MaxListenSize) + `"}}}]}`, "maximum size"},
+	}
+
+	for _, tt := range tests {
+		err := Validate(decode(t, tt.body), now, time.Minute)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestValidateLimitsImportSize(t *testing.T) {
+	listen := Listen{ListenedAt: new(int64), TrackMetadata: &TrackMetadata{ArtistName: "A", TrackName: "B"}}
+	*listen.ListenedAt = now.Unix()
+	s := &Submission{ListenType: ListenTypeImport}
+	for i := 0; i <= MaxListensPerRequest; i++ {
+		s.Payload = append(s.Payload, listen)
+	}
+
+	if err := Validate(s, now, time.Minute); err == nil || !strings.Contains(err.Error(), "too many listens") {
+		t.Errorf("Expected too many listens, got %v", err)
+	}
+	s.Payload = s.Payload[:MaxListensPerRequest]
+	if err := Validate(s, now, time.Minute); err != nil {
+		t.Errorf("Expected %d listens to be accepted, got %v", MaxListensPerRequest, err)
+	}
+}
+
+func TestDurationMs(t *testing.T) {
+	tests := []struct {
+		info string
+		want int64
+	}{
+		{`{"duration_ms":354000}`, 354000},
+		{`{"duration":354}`, 354000},
+		{`{"duration_ms":"354000","duration":354.5}`, 354500},
+		{`{"duration_ms":-1}`, 0},
+		{`{"duration":99999999}`, 0},
+		{`{}`, 0},
+	}
+
+	for _, tt := range tests {
+		m := TrackMetadata{}
+		if err := json.Unmarshal([]byte(tt.info), &m.AdditionalInfo); err != nil {
+			t.Fatalf("Expected valid additional info, got %v", err)
+		}
+		if got := m.DurationMs(); got != tt.want {
+			t.Errorf("DurationMs(%s) = %d, want %d", tt.info, got, tt.want)
+		}
+	}
+}
+
+func TestTokenFromHeader(t *testing.T) {
+	tests := map[string]string{
+		"Token abc-123":   "abc-123",
+		"token  abc-123 ": "abc-123",
+		"Bearer abc-123":  "",
+		"abc-123":         "",
+		"":                "",
+	}
+	for header, want := range tests {
+		if got := TokenFromHeader(header); got != want {
+			t.Errorf("TokenFromHeader(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first, err := NewToken()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	second, _ := NewToken()
+
+	if !uuid.MatchString(first) {
+		t.Errorf("Expected a version 4 UUID, got %q", first)
+	}
+	if first == second {
+		t.Error("Expected different tokens")
+	}
+	if HashToken(first) == HashToken(second) || HashToken(first) != HashToken(first) || len(HashToken(first)) != 64 {
+		t.Errorf("Expected a stable 64 character digest per token, got %q", HashToken(first))
+	}
+}
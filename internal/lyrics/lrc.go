@@ -0,0 +1,197 @@
+package lyrics
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FormatLRC identifies time-synced lyrics in LRC format
+	FormatLRC = "lrc"
+	// FormatPlain identifies unsynced plain text lyrics
+	FormatPlain = "plain"
+)
+
+var (
+	// timestampPattern matches a leading [mm:ss], [mm:ss.x], [mm:ss.xx] or [mm:ss.xxx] tag
+	timestampPattern = regexp.MustCompile(`^\[(\d{1,3}):(\d{2})(?:[.:](\d{1,3}))?\]`)
+	// metadataPattern matches ID tags such as [ar:Artist] or [offset:+250]
+	metadataPattern = regexp.MustCompile(`^\[([a-zA-Z#]+):(.*)\]$`)
+)
+
+// Line is a single lyrics line, TimeMs is nil for plain text lyrics
+type Line struct {
+	TimeMs *int64
+	Text   string
+}
+
+// Lyrics is the parsed representation of plain or LRC lyrics
+type Lyrics struct {
+	Format   string
+	Metadata map[string]string
+	OffsetMs int64
+	Lines    []Line
+}
+
+// ParseError describes an invalid LRC line
+type ParseError struct {
+	Line   int
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+// Parse parses lyrics in the given format. An empty format auto-detects LRC
+// when any line starts with a timestamp tag.
+func Parse(raw, format string) (*Lyrics, error) {
+	raw = strings.TrimPrefix(raw, "\ufeff")
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+
+	if format == "" {
+		format = Detect(raw)
+	}
+
+	switch format {
+	case FormatLRC:
+		return parseLRC(raw)
+	case FormatPlain:
+		return parsePlain(raw), nil
+	default:
+		return nil, fmt.Errorf("unsupported lyrics format: %s", format)
+	}
+}
+
+// Detect returns FormatLRC if any line starts with a timestamp tag, FormatPlain otherwise
+func Detect(raw string) string {
+	for _, line := range strings.Split(raw, "\n") {
+		if timestampPattern.MatchString(strings.TrimSpace(line)) {
+			return FormatLRC
+		}
+	}
+	return FormatPlain
+}
+
+func parsePlain(raw string) *Lyrics {
+	lyrics := &Lyrics{Format: FormatPlain, Metadata: map[string]string{}}
+	for _, line := range strings.Split(strings.TrimRight(raw, "\n"), "\n") {
+		lyrics.Lines = append(lyrics.Lines, Line{Text: strings.TrimSpace(line)})
+	}
+	return lyrics
+}
+
+func parseLRC(raw string) (*Lyrics, error) {
+	lyrics := &Lyrics{Format: FormatLRC, Metadata: map[string]string{}}
+
+	for i, line := range strings.Split(raw, "\n") {
+		lineNumber := i + 1
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		// A line may carry several timestamps: [00:12.00][01:15.30]Chorus
+		var times []int64
+		rest := line
+		for {
+			match := timestampPattern.FindStringSubmatch(rest)
+			if match == nil {
+				break
+			}
+			ms, err := timestampToMs(match[1], match[2], match[3])
+			if err != nil {
+				return nil, &ParseError{Line: lineNumber, Reason: err.Error()}
+			}
+			times = append(times, ms)
+			rest = rest[len(match[0]):]
+		}
+
+		if len(times) == 0 {
+			tag := metadataPattern.FindStringSubmatch(line)
+			if tag == nil {
+				return nil, &ParseError{Line: lineNumber, Reason: "missing timestamp"}
+			}
+			key := strings.ToLower(tag[1])
+			value := strings.TrimSpace(tag[2])
+			if key == "offset" {
+				offset, err := strconv.ParseInt(strings.TrimPrefix(value, "+"), 10, 64)
+				if err != nil {
+					return nil, &ParseError{Line: lineNumber, Reason: "invalid offset"}
+				}
+				lyrics.OffsetMs = offset
+			}
+			lyrics.Metadata[key] = value
+			continue
+		}
+
+		text := strings.TrimSpace(rest)
+		for _, ms := range times {
+			ms := ms
+			lyrics.Lines = append(lyrics.Lines, Line{TimeMs: &ms, Text: text})
+		}
+	}
+
+	if len(lyrics.Lines) == 0 {
+		return nil, &ParseError{Line: 0, Reason: "no timed lines found"}
+	}
+
+	// A positive offset shifts lyrics earlier, as defined by the LRC format
+	for i := range lyrics.Lines {
+		adjusted := *lyrics.Lines[i].TimeMs - lyrics.OffsetMs
+		if adjusted < 0 {
+			adjusted = 0
+		}
+		lyrics.Lines[i].TimeMs = &adjusted
+	}
+
+	sort.SliceStable(lyrics.Lines, func(a, b int) bool {
+		return *lyrics.Lines[a].TimeMs < *lyrics.Lines[b].TimeMs
+	})
+
+	return lyrics, nil
+}
+
+func timestampToMs(minutes, seconds, fraction string) (int64, error) {
+	m, err := strconv.ParseInt(minutes, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid minutes")
+	}
+
+	s, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil || s >= 60 {
+		return 0, fmt.Errorf("invalid seconds")
+	}
+
+	var ms int64
+	if fraction != "" {
+		f, err := strconv.ParseInt(fraction, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid fraction")
+		}
+		// .5 is 500ms, .25 is 250ms, .125 is 125ms
+		for i := len(fraction); i < 3; i++ {
+			f *= 10
+		}
+		ms = f
+	}
+
+	return m*60_000 + s*1_000 + ms, nil
+}
+
+// FormatTimestamp renders milliseconds as an LRC mm:ss.xx timestamp
+func FormatTimestamp(ms int64) string {
+	return fmt.Sprintf("%02d:%02d.%02d", ms/60_000, (ms/1_000)%60, (ms%1_000)/10)
+}
+
+// Text returns the lyrics without timestamps, one line per row
+func (l *Lyrics) Text() string {
+	lines := make([]string, 0, len(l.Lines))
+	for _, line := range l.Lines {
+		lines = append(lines, line.Text)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,82 @@
+package lyrics
+
+import "testing"
+
+func TestParseLRC(t *testing.T) {
+	raw := "[ti:Song]\n[ar:Artist]\n[00:12.00]First line\n[00:05.5]Intro\n[00:20.00][01:00.25]Chorus\n"
+
+	parsed, err := Parse(raw, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if parsed.Format != FormatLRC {
+		t.Errorf("Expected format to be lrc, got %s", parsed.Format)
+	}
+
+	if parsed.Metadata["ar"] != "Artist" {
+		t.Errorf("Expected artist metadata to be 'Artist', got %s", parsed.Metadata["ar"])
+	}
+
+	if len(parsed.Lines) != 4 {
+		t.Fatalf("Expected 4 lines, got %d", len(parsed.Lines))
+	}
+
+	expected := []int64{5500, 12000, 20000, 60250}
+	for i, ms := range expected {
+		if *parsed.Lines[i].TimeMs != ms {
+			t.Errorf("Expected line %d at %dms, got %d", i, ms, *parsed.Lines[i].TimeMs)
+		}
+	}
+
+	if parsed.Lines[3].Text != "Chorus" {
+		t.Errorf("Expected last line to be 'Chorus', got %s", parsed.Lines[3].Text)
+	}
+}
+
+func TestParseLRCOffset(t *testing.T) {
+	parsed, err := Parse("[offset:+500]\n[00:10.00]Line\n", FormatLRC)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if *parsed.Lines[0].TimeMs != 9500 {
+		t.Errorf("Expected offset to be applied, got %d", *parsed.Lines[0].TimeMs)
+	}
+}
+
+func TestParseLRCInvalid(t *testing.T) {
+	cases := []string{
+		"[00:75.00]Seconds out of range",
+		"[00:10.00]Line\nuntimed line",
+		"[ti:Only metadata]",
+		"[offset:abc]\n[00:10.00]Line",
+	}
+
+	for _, raw := range cases {
+		if _, err := Parse(raw, FormatLRC); err == nil {
+			t.Errorf("Expected error for %q", raw)
+		}
+	}
+}
+
+func TestParsePlain(t *testing.T) {
+	parsed, err := Parse("First line\nSecond line\n", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if parsed.Format != FormatPlain {
+		t.Errorf("Expected format to be plain, got %s", parsed.Format)
+	}
+
+	if len(parsed.Lines) != 2 || parsed.Lines[0].TimeMs != nil {
+		t.Errorf("Expected 2 untimed lines, got %+v", parsed.Lines)
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	if got := FormatTimestamp(61_230); got != "01:01.23" {
+		t.Errorf("Expected '01:01.23', got %s", got)
+	}
+}
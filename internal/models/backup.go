@@ -0,0 +1,119 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Conflict strategies applied when a restored row already exists
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictFail      = "fail"
+)
+
+// Backup is a full dump of the catalog. Rows keep their IDs and timestamps so
+// references between tables survive a restore.
+type Backup struct {
+	Format        string               `json:"format"`
+	Version       int                  `json:"version"`
+	CreatedAt     time.Time            `json:"created_at"`
+	Songs         []BackupSong         `json:"songs"`
+	Lyrics        []BackupLyrics       `json:"lyrics"`
+	Playlists     []BackupPlaylist     `json:"playlists"`
+	PlaylistSongs []BackupPlaylistSong `json:"playlist_songs"`
+	SongLikes     []BackupSongLike     `json:"song_likes"`
+	PlaylistLikes []BackupPlaylistLike `json:"playlist_likes"`
+}
+
+// BackupSong is a songs row. Blob keys refer to files in blob storage, which
+// is not part of the backup.
+type BackupSong struct {
+	ID               uint       `json:"id"`
+	Title            string     `json:"title"`
+	Artist           string     `json:"artist"`
+	Album            string     `json:"album,omitempty"`
+	TrackNumber      int        `json:"track_number,omitempty"`
+	Year             int        `json:"year,omitempty"`
+	DurationMs       int64      `json:"duration_ms,omitempty"`
+	Genre            string     `json:"genre,omitempty"`
+	AudioKey         string     `json:"audio_key,omitempty"`
+	AudioHash        string     `json:"audio_hash,omitempty"`
+	AudioContentType string     `json:"audio_content_type,omitempty"`
+	AudioSize        int64      `json:"audio_size,omitempty"`
+	CoverKey         string     `json:"cover_key,omitempty"`
+	CoverContentType string     `json:"cover_content_type,omitempty"`
+	SourcePath       string     `json:"source_path,omitempty"`
+	SourceHash       string     `json:"source_hash,omitempty"`
+	SourceSize       int64      `json:"source_size,omitempty"`
+	SourceModTime    *time.Time `json:"source_mod_time,omitempty"`
+	SourceMissingAt  *time.Time `json:"source_missing_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// BackupLyrics is a song_lyrics row
+type BackupLyrics struct {
+	SongID    uint      `json:"song_id"`
+	Format    string    `json:"format"`
+	Raw       string    `json:"raw"`
+	PlainText string    `json:"plain_text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BackupPlaylist is a playlists row
+type BackupPlaylist struct {
+	ID               uint            `json:"id"`
+	Name             string          `json:"name"`
+	Description      string          `json:"description"`
+	Creator          string          `json:"creator,omitempty"`
+	IsPublished      bool            `json:"is_published"`
+	PublishedAt      *time.Time      `json:"published_at,omitempty"`
+	CoverKey         string          `json:"cover_key,omitempty"`
+	CoverContentType string          `json:"cover_content_type,omitempty"`
+	Rules            json.RawMessage `json:"rules,omitempty"`
+	ForkedFrom       *uint           `json:"forked_from,omitempty"`
+	SourcePath       string          `json:"source_path,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+}
+
+// BackupPlaylistSong is a playlist_songs row
+type BackupPlaylistSong struct {
+	ID         uint      `json:"id"`
+	PlaylistID uint      `json:"playlist_id"`
+	SongID     uint      `json:"song_id"`
+	AddedAt    time.Time `json:"added_at"`
+}
+
+// BackupSongLike is a song_likes row
+type BackupSongLike struct {
+	ListenerID string    `json:"listener_id"`
+	SongID     uint      `json:"song_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// BackupPlaylistLike is a playlist_likes row
+type BackupPlaylistLike struct {
+	ListenerID string    `json:"listener_id"`
+	PlaylistID uint      `json:"playlist_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// RestoreCounts reports what happened to the rows of one table during a restore
+type RestoreCounts struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Skipped  int `json:"skipped"`
+}
+
+// RestoreResult reports the outcome of a restore per table
+type RestoreResult struct {
+	Songs         RestoreCounts `json:"songs"`
+	Lyrics        RestoreCounts `json:"lyrics"`
+	Playlists     RestoreCounts `json:"playlists"`
+	PlaylistSongs RestoreCounts `json:"playlist_songs"`
+	SongLikes     RestoreCounts `json:"song_likes"`
+	PlaylistLikes RestoreCounts `json:"playlist_likes"`
+}
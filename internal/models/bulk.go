@@ -0,0 +1,57 @@
+package models
+
+// Modes of a bulk operation
+const (
+	// BulkModeAllOrNothing applies every item or none of them
+	BulkModeAllOrNothing = "all_or_nothing"
+	// BulkModePartial applies the valid items and reports the rest
+	BulkModePartial = "partial"
+)
+
+// BulkCreateSongsRequest represents the request to create several songs at once
+type BulkCreateSongsRequest struct {
+	Songs []CreateSongRequest `json:"songs" binding:"required"`
+}
+
+// BulkUpdateSongItem represents one song of a bulk update. An omitted genre
+// keeps the current one.
+type BulkUpdateSongItem struct {
+	ID     uint    `json:"id"`
+	Title  string  `json:"title"`
+	Artist string  `json:"artist"`
+	Genre  *string `json:"genre"`
+}
+
+// BulkUpdateSongsRequest represents the request to update several songs at once
+type BulkUpdateSongsRequest struct {
+	Songs []BulkUpdateSongItem `json:"songs" binding:"required"`
+}
+
+// BulkDeleteSongsRequest represents the request to delete several songs at once
+type BulkDeleteSongsRequest struct {
+	IDs []uint `json:"ids" binding:"required"`
+}
+
+// BulkItemResult reports the outcome of one item of a bulk operation, using the
+// status code the item would get from the single-item endpoint
+type BulkItemResult struct {
+	Index  int    `json:"index"`
+	ID     *uint  `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Song   *Song  `json:"song,omitempty"`
+}
+
+// BulkResult is the outcome of a bulk operation
+type BulkResult struct {
+	Mode      string           `json:"mode"`
+	Applied   bool             `json:"applied"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Items     []BulkItemResult `json:"items"`
+}
+
+// BulkResponse represents the response for a bulk operation
+type BulkResponse struct {
+	Data BulkResult `json:"data"`
+}
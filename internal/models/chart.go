@@ -0,0 +1,38 @@
+package models
+
+// Chart kinds
+const (
+	ChartSongs     = "songs"
+	ChartArtists   = "artists"
+	ChartPlaylists = "playlists"
+)
+
+// ChartEntry is a ranked item of a chart. Songs fill song_id, title and artist,
+// artists fill artist and playlists fill playlist_id and name.
+type ChartEntry struct {
+	Rank         int    `json:"rank"`
+	PreviousRank *int   `json:"previous_rank"`
+	Movement     string `json:"movement" enums:"new,up,down,same"`
+	RankChange   int    `json:"rank_change"`
+	PlayCount    int64  `json:"play_count"`
+	SongID       *uint  `json:"song_id,omitempty"`
+	PlaylistID   *uint  `json:"playlist_id,omitempty"`
+	Title        string `json:"title,omitempty"`
+	Artist       string `json:"artist,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// Chart is the ranking of a kind of item over a period. start and end are UTC
+// dates, end excluded.
+type Chart struct {
+	Kind    string       `json:"kind"`
+	Period  string       `json:"period"`
+	Start   string       `json:"start"`
+	End     string       `json:"end"`
+	Entries []ChartEntry `json:"entries"`
+}
+
+// ChartResponse represents the response for a chart
+type ChartResponse struct {
+	Data Chart `json:"data"`
+}
@@ -0,0 +1,35 @@
+package models
+
+// Statuses of the entries of an import report
+const (
+	ImportStatusMatched   = "matched"
+	ImportStatusCreated   = "created"
+	ImportStatusUnmatched = "unmatched"
+	ImportStatusDuplicate = "duplicate"
+	ImportStatusInvalid   = "invalid"
+)
+
+// SongImportRow reports the outcome of a single CSV row
+type SongImportRow struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	SongID *uint  `json:"song_id,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Artist string `json:"artist,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// SongImportReport is the result of a CSV song import
+type SongImportReport struct {
+	DryRun     bool            `json:"dry_run"`
+	Total      int             `json:"total"`
+	Created    int             `json:"created"`
+	Duplicates int             `json:"duplicates"`
+	Invalid    int             `json:"invalid"`
+	Rows       []SongImportRow `json:"rows"`
+}
+
+// SongImportResponse represents the response for a CSV song import
+type SongImportResponse struct {
+	Data SongImportReport `json:"data"`
+}
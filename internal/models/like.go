@@ -0,0 +1,14 @@
+package models
+
+// LikedSongsPlaylistName is the name of the virtual playlist of liked songs
+const LikedSongsPlaylistName = "Liked songs"
+
+// LikedSongsResponse represents a page of the virtual "Liked songs" playlist of
+// a listener, shaped like PlaylistResponse. Its songs are ordered by when they
+// were liked, most recent first, with added_at set to that time; total counts
+// every liked song. next_cursor is empty on the last page.
+type LikedSongsResponse struct {
+	Data       Playlist `json:"data"`
+	Total      int      `json:"total"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
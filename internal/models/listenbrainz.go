@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// ListenToken lets a ListenBrainz client submit listens for a listener. The
+// token itself is only returned when it is created.
+type ListenToken struct {
+	ID            uint       `json:"id" db:"id"`
+	ListenerID    string     `json:"listener_id" db:"listener_id"`
+	Token         string     `json:"token,omitempty" db:"-"`
+	CreateMissing bool       `json:"create_missing" db:"create_missing"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+// CreateListenTokenRequest represents the request to create a listen token.
+// With create_missing, listens of songs not in the catalog create them.
+type CreateListenTokenRequest struct {
+	CreateMissing bool `json:"create_missing"`
+}
+
+// ListenTokenResponse represents the response for listen token operations
+type ListenTokenResponse struct {
+	Data ListenToken `json:"data"`
+}
+
+// ListenBrainzSubmitResponse is the ListenBrainz response to a submission,
+// extended with how many listens were recorded as plays, were already
+// recorded, or matched no song
+type ListenBrainzSubmitResponse struct {
+	Status     string `json:"status"`
+	Recorded   int    `json:"recorded"`
+	Duplicates int    `json:"duplicates"`
+	Unmatched  int    `json:"unmatched"`
+}
+
+// ListenBrainzTokenResponse is the ListenBrainz response to a token validation
+type ListenBrainzTokenResponse struct {
+	Code     int    `json:"code"`
+	Message  string `json:"message"`
+	Valid    bool   `json:"valid"`
+	UserName string `json:"user_name,omitempty"`
+}
+
+// ListenBrainzError is an error in the shape ListenBrainz clients expect
+type ListenBrainzError struct {
+	Code  int    `json:"code"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Lyrics represents the lyrics attached to a song
+type Lyrics struct {
+	SongID    uint              `json:"song_id" db:"song_id"`
+	Format    string            `json:"format" db:"format"`
+	Metadata  map[string]string `json:"metadata,omitempty" db:"-"`
+	Lines     []LyricsLine      `json:"lines" db:"-"`
+	Raw       string            `json:"-" db:"raw"`
+	PlainText string            `json:"text" db:"plain_text"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// LyricsLine represents a single line of lyrics, time fields are only set for LRC lyrics
+type LyricsLine struct {
+	TimeMs    *int64 `json:"time_ms,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Text      string `json:"text"`
+}
+
+// UpdateLyricsRequest represents the JSON request to set a song's lyrics
+type UpdateLyricsRequest struct {
+	Lyrics string `json:"lyrics" binding:"required"`
+	Format string `json:"format"`
+}
+
+// LyricsResponse represents the response for lyrics operations
+type LyricsResponse struct {
+	Data Lyrics `json:"data"`
+}
+
+// LyricsSearchResult represents a song whose lyrics match a search
+type LyricsSearchResult struct {
+	Song    Song    `json:"song"`
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
+
+// LyricsSearchResponse represents the response for a lyrics search
+type LyricsSearchResponse struct {
+	Data []LyricsSearchResult `json:"data"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// ListenerHeader is the request header identifying who is listening
+const ListenerHeader = "X-Listener-ID"
+
+// Play is a single listening event. Title and artist are copied from the song
+// when the play is recorded, so the history stays readable after the song is
+// deleted.
+type Play struct {
+	ID          int64     `json:"id" db:"id"`
+	ListenerID  string    `json:"listener_id" db:"listener_id"`
+	SongID      uint      `json:"song_id" db:"song_id"`
+	SongTitle   string    `json:"song_title" db:"song_title"`
+	SongArtist  string    `json:"song_artist" db:"song_artist"`
+	SongDeleted bool      `json:"song_deleted,omitempty" db:"-"`
+	PlaylistID  *uint     `json:"playlist_id,omitempty" db:"playlist_id"`
+	StartedAt   time.Time `json:"started_at" db:"started_at"`
+	MsPlayed    int       `json:"ms_played" db:"ms_played"`
+	Completed   bool      `json:"completed" db:"completed"`
+	Skipped     bool      `json:"skipped" db:"skipped"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreatePlayRequest represents the request to record a play. started_at
+// defaults to now.
+type CreatePlayRequest struct {
+	SongID     uint       `json:"song_id" binding:"required"`
+	PlaylistID *uint      `json:"playlist_id"`
+	StartedAt  *time.Time `json:"started_at"`
+	MsPlayed   int        `json:"ms_played" binding:"min=0"`
+	Completed  bool       `json:"completed"`
+	Skipped    bool       `json:"skipped"`
+}
+
+// PlayResponse represents the response for a recorded play
+type PlayResponse struct {
+	Data Play `json:"data"`
+}
+
+// PlaysResponse represents a page of listening history. next_cursor is empty
+// on the last page.
+type PlaysResponse struct {
+	Data       []Play `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
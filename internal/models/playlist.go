@@ -1,31 +1,49 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+// PlaylistCoverSizes are the square thumbnail sizes generated for playlist covers, in pixels
+var PlaylistCoverSizes = []int{64, 256, 640}
 
 // Playlist represents a playlist in the system
 type Playlist struct {
-	ID          uint           `json:"id" db:"id"`
-	Name        string         `json:"name" db:"name"`
-	Description string         `json:"description" db:"description"`
-	IsPublished bool           `json:"is_published" db:"is_published"`
-	PublishedAt *time.Time     `json:"published_at,omitempty" db:"published_at"`
-	Songs       []PlaylistSong `json:"songs" db:"-"`
-	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at" db:"updated_at"`
+	ID          uint                `json:"id" db:"id"`
+	Name        string              `json:"name" db:"name"`
+	Description string              `json:"description" db:"description"`
+	Creator     string              `json:"creator,omitempty" db:"creator"`
+	IsPublished bool                `json:"is_published" db:"is_published"`
+	PublishedAt *time.Time          `json:"published_at,omitempty" db:"published_at"`
+	CoverURL    *string             `json:"cover_url,omitempty" db:"-"`
+	Thumbnails  map[string]string   `json:"cover_thumbnails,omitempty" db:"-"`
+	ForkedFrom  *uint               `json:"forked_from,omitempty" db:"forked_from"`
+	ForkCount   int                 `json:"fork_count" db:"-"`
+	LikeCount   int                 `json:"like_count" db:"-"`
+	Rules       *SmartPlaylistRules `json:"rules,omitempty" db:"rules"`
+	ShuffleSeed *string             `json:"shuffle_seed,omitempty" db:"-"`
+	Songs       []PlaylistSong      `json:"songs" db:"-"`
+	CreatedAt   time.Time           `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at" db:"updated_at"`
 }
 
 // PlaylistSong represents a song within a playlist
 type PlaylistSong struct {
-	ID      uint      `json:"id" db:"id"`
-	Title   string    `json:"title" db:"title"`
-	Artist  string    `json:"artist" db:"artist"`
-	AddedAt time.Time `json:"added_at" db:"added_at"`
+	ID         uint      `json:"id" db:"id"`
+	Title      string    `json:"title" db:"title"`
+	Artist     string    `json:"artist" db:"artist"`
+	Album      string    `json:"album,omitempty" db:"album"`
+	DurationMs int64     `json:"duration_ms,omitempty" db:"duration_ms"`
+	HasAudio   bool      `json:"has_audio" db:"-"`
+	AddedAt    time.Time `json:"added_at" db:"added_at"`
 }
 
 // CreatePlaylistRequest represents the request to create a playlist
 type CreatePlaylistRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Description string `json:"description" binding:"required,min=50,max=255"`
+	Name        string              `json:"name" binding:"required"`
+	Description string              `json:"description" binding:"required,min=50,max=255"`
+	Rules       *SmartPlaylistRules `json:"rules"`
 }
 
 // PublishPlaylistRequest represents the request to publish a playlist
@@ -47,3 +65,166 @@ type PlaylistResponse struct {
 type PlaylistsResponse struct {
 	Data []Playlist `json:"data"`
 }
+
+// Ways a duplicated playlist sets the added_at of its entries
+const (
+	// AddedAtPreserve copies the added_at of every entry from the original
+	AddedAtPreserve = "preserve"
+	// AddedAtReset stamps every entry with the time of the copy, keeping the order
+	AddedAtReset = "reset"
+)
+
+// DuplicatePlaylistRequest represents the request to duplicate a playlist. The
+// copy keeps the original name unless one is given.
+type DuplicatePlaylistRequest struct {
+	Name    string `json:"name" binding:"max=255"`
+	AddedAt string `json:"added_at"`
+}
+
+// ShufflePlaylistRequest represents the request to store a shuffled order of a
+// playlist. A random seed is used unless one is given.
+type ShufflePlaylistRequest struct {
+	Seed string `json:"seed" binding:"max=64"`
+}
+
+// PlaylistFork is a node of the fork tree of a playlist
+type PlaylistFork struct {
+	ID          uint           `json:"id"`
+	Name        string         `json:"name"`
+	IsPublished bool           `json:"is_published"`
+	ForkCount   int            `json:"fork_count"`
+	CreatedAt   time.Time      `json:"created_at"`
+	Forks       []PlaylistFork `json:"forks"`
+}
+
+// PlaylistForkTreeResponse represents the response for the fork tree of a playlist
+type PlaylistForkTreeResponse struct {
+	Data PlaylistFork `json:"data"`
+}
+
+// PlaylistImportEntry reports how a single entry of an imported playlist was resolved
+type PlaylistImportEntry struct {
+	Line     int    `json:"line"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist,omitempty"`
+	Location string `json:"location,omitempty"`
+	Status   string `json:"status"`
+	SongID   *uint  `json:"song_id,omitempty"`
+}
+
+// PlaylistImportReport is the result of importing a playlist file. Public is
+// the public flag of an XSPF or JSPF file, which the imported playlist does not
+// take: it is always created unpublished.
+type PlaylistImportReport struct {
+	Playlist  Playlist              `json:"playlist"`
+	Public    *bool                 `json:"public,omitempty"`
+	Matched   int                   `json:"matched"`
+	Created   int                   `json:"created"`
+	Unmatched int                   `json:"unmatched"`
+	Entries   []PlaylistImportEntry `json:"entries"`
+}
+
+// PlaylistImportResponse represents the response for a playlist import
+type PlaylistImportResponse struct {
+	Data PlaylistImportReport `json:"data"`
+}
+
+// ComposeExpression is a set expression over playlists: either a single playlist
+// or an operator applied left to right to two or more operands
+type ComposeExpression struct {
+	Playlist uint                `json:"playlist,omitempty"`
+	Op       string              `json:"op,omitempty" enums:"union,intersect,except"`
+	Operands []ComposeExpression `json:"operands,omitempty"`
+}
+
+// ComposePlaylistRequest represents the request to compose a playlist from others.
+// Name and description are required when materializing.
+type ComposePlaylistRequest struct {
+	Expression  *ComposeExpression `json:"expression" binding:"required"`
+	Materialize bool               `json:"materialize"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+}
+
+// ComposePreview is the song list a composition would produce
+type ComposePreview struct {
+	Expression string         `json:"expression"`
+	SongCount  int            `json:"song_count"`
+	Songs      []PlaylistSong `json:"songs"`
+}
+
+// ComposePreviewResponse represents the response for a composition preview
+type ComposePreviewResponse struct {
+	Data ComposePreview `json:"data"`
+}
+
+// SmartRule is a condition on the songs of a smart playlist: either a field
+// compared with a value, or all or any of other rules
+type SmartRule struct {
+	All   []SmartRule     `json:"all,omitempty"`
+	Any   []SmartRule     `json:"any,omitempty"`
+	Field string          `json:"field,omitempty" enums:"title,artist,album,genre,year,duration_ms,track_number,created_at,has_audio"`
+	Op    string          `json:"op,omitempty" enums:"eq,neq,contains,not_contains,in,not_in,lt,lte,gt,gte,within_days,older_than_days"`
+	Value json.RawMessage `json:"value,omitempty" swaggertype:"object"`
+}
+
+// SmartPlaylistRules is the saved query giving the songs of a smart playlist
+type SmartPlaylistRules struct {
+	Match SmartRule `json:"match"`
+	Sort  string    `json:"sort,omitempty"`
+	Limit int       `json:"limit,omitempty"`
+}
+
+// PlaylistStats summarizes the songs of a playlist. shared_songs are the songs
+// that also appear in another published playlist.
+type PlaylistStats struct {
+	PlaylistID        uint                 `json:"playlist_id"`
+	IsPublished       bool                 `json:"-"`
+	TrackCount        int                  `json:"track_count"`
+	TotalDurationMs   int64                `json:"total_duration_ms"`
+	AverageDurationMs int64                `json:"average_duration_ms"`
+	ArtistCount       int                  `json:"artist_count"`
+	DistinctSongs     int                  `json:"distinct_songs"`
+	SharedSongs       int                  `json:"shared_songs"`
+	SharedRatio       float64              `json:"shared_ratio"`
+	Artists           []PlaylistArtistStat `json:"artists"`
+	Decades           []PlaylistDecadeStat `json:"decades"`
+	Genres            []PlaylistGenreStat  `json:"genres"`
+	WeeklyAdditions   []PlaylistWeekStat   `json:"weekly_additions"`
+}
+
+// PlaylistArtistStat is the number of entries of a playlist by an artist
+type PlaylistArtistStat struct {
+	Artist string  `json:"artist"`
+	Count  int     `json:"count"`
+	Share  float64 `json:"share"`
+}
+
+// PlaylistDecadeStat is the number of entries of a playlist released in a
+// decade, or with no known year when decade is null
+type PlaylistDecadeStat struct {
+	Decade *int    `json:"decade"`
+	Count  int     `json:"count"`
+	Share  float64 `json:"share"`
+}
+
+// PlaylistGenreStat is the number of entries of a playlist of a genre, or with
+// no known genre when genre is null
+type PlaylistGenreStat struct {
+	Genre *string `json:"genre"`
+	Count int     `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// PlaylistWeekStat is the number of entries added to a playlist in the week
+// starting on Monday week, and the running total up to that week
+type PlaylistWeekStat struct {
+	Week  string `json:"week"`
+	Added int    `json:"added"`
+	Total int    `json:"total"`
+}
+
+// PlaylistStatsResponse represents the response for playlist statistics
+type PlaylistStatsResponse struct {
+	Data PlaylistStats `json:"data"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// QueueItem is a song lined up in a play queue
+type QueueItem struct {
+	ID         int64  `json:"id"`
+	SongID     uint   `json:"song_id"`
+	Title      string `json:"title"`
+	Artist     string `json:"artist"`
+	Album      string `json:"album,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+	HasAudio   bool   `json:"has_audio"`
+}
+
+// Queue is the play queue of a listener. Items are in play order and
+// current_index is null when nothing is playing. version grows with every
+// change and can be sent back in If-Match to avoid overwriting changes made
+// from another device.
+type Queue struct {
+	Items        []QueueItem `json:"items"`
+	CurrentIndex *int        `json:"current_index"`
+	Shuffle      bool        `json:"shuffle"`
+	Repeat       string      `json:"repeat" enums:"off,all,one"`
+	Version      int64       `json:"version"`
+	UpdatedAt    *time.Time  `json:"updated_at,omitempty"`
+}
+
+// EnqueueRequest represents the request to queue a song or every song of a
+// playlist, at the end or right after the playing song
+type EnqueueRequest struct {
+	SongID     uint `json:"song_id"`
+	PlaylistID uint `json:"playlist_id"`
+	Next       bool `json:"next"`
+}
+
+// QueueIndexRequest represents a request pointing at an index of the play order
+type QueueIndexRequest struct {
+	Index *int `json:"index" binding:"required"`
+}
+
+// UpdateQueueRequest represents the request to change the modes of a queue
+type UpdateQueueRequest struct {
+	Shuffle *bool   `json:"shuffle"`
+	Repeat  *string `json:"repeat" enums:"off,all,one"`
+}
+
+// QueueResponse represents the response for queue operations
+type QueueResponse struct {
+	Data Queue `json:"data"`
+}
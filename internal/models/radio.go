@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// RadioStation is an endless station of songs generated from a seed song,
+// artist or playlist
+type RadioStation struct {
+	ID        uint      `json:"id"`
+	SeedType  string    `json:"seed_type" enums:"song,artist,playlist"`
+	Seed      string    `json:"seed"`
+	SeedSongs int       `json:"seed_songs"`
+	Played    int64     `json:"played"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateRadioRequest represents the request to start a station. Exactly one
+// seed must be given.
+type CreateRadioRequest struct {
+	SongID     uint   `json:"song_id"`
+	Artist     string `json:"artist" binding:"max=255"`
+	PlaylistID uint   `json:"playlist_id"`
+}
+
+// RadioStationResponse represents the response for station operations
+type RadioStationResponse struct {
+	Data RadioStation `json:"data"`
+}
+
+// RadioSongsResponse represents the response with the next songs of a station
+type RadioSongsResponse struct {
+	Data []Song `json:"data"`
+}
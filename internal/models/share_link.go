@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// ShareLink is a signed, expiring and revocable link giving read-only access to
+// a playlist whether it is published or not
+type ShareLink struct {
+	ID         uint       `json:"id" db:"id"`
+	PlaylistID uint       `json:"playlist_id" db:"playlist_id"`
+	Label      string     `json:"label,omitempty" db:"label"`
+	Token      string     `json:"token" db:"-"`
+	URL        string     `json:"url" db:"-"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	UseCount   int64      `json:"use_count" db:"use_count"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// CreateShareLinkRequest represents the request to share a playlist. The link
+// lasts 7 days unless expires_in_hours says otherwise.
+type CreateShareLinkRequest struct {
+	Label          string `json:"label" binding:"max=255"`
+	ExpiresInHours int    `json:"expires_in_hours" binding:"min=0"`
+}
+
+// ShareLinkResponse represents the response for share link operations
+type ShareLinkResponse struct {
+	Data ShareLink `json:"data"`
+}
+
+// ShareLinksResponse represents the response for the share links of a playlist
+type ShareLinksResponse struct {
+	Data []ShareLink `json:"data"`
+}
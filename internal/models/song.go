@@ -4,23 +4,41 @@ import "time"
 
 // Song represents a song in the system
 type Song struct {
-	ID        uint      `json:"id" db:"id"`
-	Title     string    `json:"title" db:"title"`
-	Artist    string    `json:"artist" db:"artist"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID               uint      `json:"id" db:"id"`
+	Title            string    `json:"title" db:"title"`
+	Artist           string    `json:"artist" db:"artist"`
+	Album            string    `json:"album,omitempty" db:"album"`
+	TrackNumber      int       `json:"track_number,omitempty" db:"track_number"`
+	Year             int       `json:"year,omitempty" db:"year"`
+	DurationMs       int64     `json:"duration_ms,omitempty" db:"duration_ms"`
+	Genre            string    `json:"genre,omitempty" db:"genre"`
+	CoverURL         *string   `json:"cover_url,omitempty" db:"-"`
+	HasAudio         bool      `json:"has_audio" db:"-"`
+	FileMissing      bool      `json:"file_missing,omitempty" db:"-"`
+	LikeCount        int       `json:"like_count" db:"-"`
+	AudioKey         string    `json:"-" db:"audio_key"`
+	AudioHash        string    `json:"-" db:"audio_hash"`
+	AudioContentType string    `json:"-" db:"audio_content_type"`
+	AudioSize        int64     `json:"-" db:"audio_size"`
+	CoverKey         string    `json:"-" db:"cover_key"`
+	CoverContentType string    `json:"-" db:"cover_content_type"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // CreateSongRequest represents the request to create a song
 type CreateSongRequest struct {
 	Title  string `json:"title" binding:"required"`
 	Artist string `json:"artist" binding:"required"`
+	Genre  string `json:"genre" binding:"max=255"`
 }
 
-// UpdateSongRequest represents the request to update a song
+// UpdateSongRequest represents the request to update a song. An omitted genre
+// keeps the current one and an empty genre clears it.
 type UpdateSongRequest struct {
-	Title  string `json:"title" binding:"required"`
-	Artist string `json:"artist" binding:"required"`
+	Title  string  `json:"title" binding:"required"`
+	Artist string  `json:"artist" binding:"required"`
+	Genre  *string `json:"genre" binding:"omitempty,max=255"`
 }
 
 // SongResponse represents the response for song operations
@@ -32,3 +50,66 @@ type SongResponse struct {
 type SongsResponse struct {
 	Data []Song `json:"data"`
 }
+
+// DuplicateSongGroup represents a set of songs sharing the same normalized title and artist
+type DuplicateSongGroup struct {
+	Key   string `json:"key"`
+	Songs []Song `json:"songs"`
+}
+
+// DuplicateSongsResponse represents the response for the duplicates report
+type DuplicateSongsResponse struct {
+	Data []DuplicateSongGroup `json:"data"`
+}
+
+// MergeSongsRequest represents the request to merge duplicates into a canonical song.
+// Songs whose title and artist don't normalize like the canonical song's are
+// only merged with force.
+type MergeSongsRequest struct {
+	DuplicateIDs []uint `json:"duplicate_ids" binding:"required"`
+	Force        bool   `json:"force"`
+}
+
+// MergeSongsResult represents the outcome of a merge
+type MergeSongsResult struct {
+	Song              Song   `json:"song"`
+	MergedSongIDs     []uint `json:"merged_song_ids"`
+	PlaylistsAffected int64  `json:"playlists_affected"`
+}
+
+// MergeSongsResponse represents the response for a merge operation
+type MergeSongsResponse struct {
+	Data MergeSongsResult `json:"data"`
+}
+
+// StreamURL represents a signed, short-lived URL to stream a song's audio
+type StreamURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// StreamURLResponse represents the response for a signed stream URL
+type StreamURLResponse struct {
+	Data StreamURL `json:"data"`
+}
+
+// Similarity metrics for related songs
+const (
+	SimilarityJaccard = "jaccard"
+	SimilarityPMI     = "pmi"
+)
+
+// RelatedSong is a song that curators put in the same published playlists as
+// another one. co_occurrences counts those playlists; jaccard divides it by the
+// playlists holding either song and pmi compares it with what chance would give.
+type RelatedSong struct {
+	Song
+	CoOccurrences int     `json:"co_occurrences"`
+	Jaccard       float64 `json:"jaccard"`
+	PMI           float64 `json:"pmi"`
+}
+
+// RelatedSongsResponse represents the response for the songs related to a song
+type RelatedSongsResponse struct {
+	Data []RelatedSong `json:"data"`
+}
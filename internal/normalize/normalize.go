@@ -0,0 +1,55 @@
+package normalize
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// featuringPattern matches "feat." style credits and everything after them,
+// optionally wrapped in parentheses or brackets
+var featuringPattern = regexp.MustCompile(`(?i)[\(\[]?\s*\b(feat\.?|ft\.?|featuring)\s.*$`)
+
+// Text lowercases s, strips accents and punctuation and collapses whitespace
+func Text(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	stripped, _, err := transform.String(t, s)
+	if err != nil {
+		stripped = s
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(stripped) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '\'' || r == '’':
+			// Apostrophes join words ("don't" -> "dont")
+		case r == '&':
+			b.WriteString(" and ")
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Title normalizes a song title, dropping any "feat." credit
+func Title(title string) string {
+	return Text(featuringPattern.ReplaceAllString(title, ""))
+}
+
+// Artist normalizes an artist name, keeping only the main artist
+func Artist(artist string) string {
+	return Text(featuringPattern.ReplaceAllString(artist, ""))
+}
+
+// SongKey returns the key used to detect duplicate songs
+func SongKey(title, artist string) string {
+	return Artist(artist) + "|" + Title(title)
+}
@@ -0,0 +1,46 @@
+package normalize
+
+import "testing"
+
+func TestText(t *testing.T) {
+	cases := map[string]string{
+		"Canción Número Uno": "cancion numero uno",
+		"  Don't   Stop!  ":  "dont stop",
+		"Simon & Garfunkel":  "simon and garfunkel",
+		"BEYONCÉ":            "beyonce",
+	}
+
+	for input, expected := range cases {
+		if got := Text(input); got != expected {
+			t.Errorf("Text(%q): expected %q, got %q", input, expected, got)
+		}
+	}
+}
+
+func TestTitleStripsFeaturing(t *testing.T) {
+	cases := map[string]string{
+		"Despacito (feat. Justin Bieber)": "despacito",
+		"Despacito [ft. Justin Bieber]":   "despacito",
+		"Despacito featuring Someone":     "despacito",
+		"Despacito":                       "despacito",
+	}
+
+	for input, expected := range cases {
+		if got := Title(input); got != expected {
+			t.Errorf("Title(%q): expected %q, got %q", input, expected, got)
+		}
+	}
+}
+
+func TestSongKey(t *testing.T) {
+	a := SongKey("Despacito (feat. Justin Bieber)", "Luis Fonsi")
+	b := SongKey("despacito", "Luis Fonsi feat. Daddy Yankee")
+
+	if a != b {
+		t.Errorf("Expected keys to match, got %q and %q", a, b)
+	}
+
+	if SongKey("Despacito", "Luis Fonsi") == SongKey("Despacito", "Other Artist") {
+		t.Error("Expected keys for different artists to differ")
+	}
+}
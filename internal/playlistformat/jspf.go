@@ -0,0 +1,225 @@
+package playlistformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type jspfDocument struct {
+	Playlist *jspfPlaylist `json:"playlist"`
+}
+
+type jspfPlaylist struct {
+	Title      string                     `json:"title,omitempty"`
+	Creator    string                     `json:"creator,omitempty"`
+	Annotation string                     `json:"annotation,omitempty"`
+	Info       string                     `json:"info,omitempty"`
+	Location   string                     `json:"location,omitempty"`
+	Identifier string                     `json:"identifier,omitempty"`
+	Image      string                     `json:"image,omitempty"`
+	Date       string                     `json:"date,omitempty"`
+	License    string                     `json:"license,omitempty"`
+	Extension  map[string]json.RawMessage `json:"extension,omitempty"`
+	Track      []jspfTrack                `json:"track"`
+}
+
+type jspfTrack struct {
+	Location   jspfURIs                   `json:"location,omitempty"`
+	Identifier jspfURIs                   `json:"identifier,omitempty"`
+	Title      string                     `json:"title,omitempty"`
+	Creator    string                     `json:"creator,omitempty"`
+	Annotation string                     `json:"annotation,omitempty"`
+	Info       string                     `json:"info,omitempty"`
+	Image      string                     `json:"image,omitempty"`
+	Album      string                     `json:"album,omitempty"`
+	TrackNum   *int                       `json:"trackNum,omitempty"`
+	Duration   *int64                     `json:"duration,omitempty"`
+	Extension  map[string]json.RawMessage `json:"extension,omitempty"`
+}
+
+// jspfURIs is a list of URIs. The spec uses arrays, but a single string is
+// accepted as well since older ListenBrainz exports write identifiers that way.
+type jspfURIs []string
+
+func (u *jspfURIs) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*u = jspfURIs{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("must be a string or an array of strings")
+	}
+	*u = list
+	return nil
+}
+
+type jspfPlaylistExtension struct {
+	Public *bool `json:"public,omitempty"`
+}
+
+type jspfTrackExtension struct {
+	AddedAt string `json:"added_at,omitempty"`
+}
+
+// EncodeJSPF renders doc as a JSPF document
+func EncodeJSPF(doc *Document) ([]byte, error) {
+	playlist := &jspfPlaylist{
+		Title:      doc.Title,
+		Creator:    doc.Creator,
+		Annotation: doc.Annotation,
+		Identifier: doc.Identifier,
+		Image:      doc.Image,
+		Track:      []jspfTrack{},
+	}
+	if doc.Date != nil {
+		playlist.Date = doc.Date.Format(time.RFC3339)
+	}
+	if doc.Public != nil {
+		ext, err := json.Marshal(jspfPlaylistExtension{Public: doc.Public})
+		if err != nil {
+			return nil, fmt.Errorf("error encoding JSPF playlist: %v", err)
+		}
+		playlist.Extension = map[string]json.RawMessage{ExtensionPlaylist: ext}
+	}
+
+	for _, entry := range doc.Entries {
+		track := jspfTrack{
+			Identifier: entry.Identifiers,
+			Title:      entry.Title,
+			Creator:    entry.Artist,
+			Album:      entry.Album,
+		}
+		if entry.Location != "" {
+			track.Location = jspfURIs{entry.Location}
+		}
+		if entry.TrackNumber > 0 {
+			trackNum := entry.TrackNumber
+			track.TrackNum = &trackNum
+		}
+		if entry.Duration > 0 {
+			duration := entry.Duration.Milliseconds()
+			track.Duration = &duration
+		}
+		if entry.AddedAt != nil {
+			ext, err := json.Marshal(jspfTrackExtension{AddedAt: entry.AddedAt.Format(time.RFC3339)})
+			if err != nil {
+				return nil, fmt.Errorf("error encoding JSPF playlist: %v", err)
+			}
+			track.Extension = map[string]json.RawMessage{ExtensionTrack: ext}
+		}
+		playlist.Track = append(playlist.Track, track)
+	}
+
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(jspfDocument{Playlist: playlist}); err != nil {
+		return nil, fmt.Errorf("error encoding JSPF playlist: %v", err)
+	}
+	return b.Bytes(), nil
+}
+
+// ParseJSPF parses and validates a JSPF document
+func ParseJSPF(data []byte) (*Document, error) {
+	var file jspfDocument
+	decoder := json.NewDecoder(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	if err := decoder.Decode(&file); err != nil {
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			return nil, fmt.Errorf("%s has the wrong type", typeErr.Field)
+		}
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	if file.Playlist == nil {
+		return nil, fmt.Errorf("document must contain a playlist object")
+	}
+	playlist := file.Playlist
+	if playlist.Track == nil {
+		return nil, fmt.Errorf("playlist must contain a track array")
+	}
+
+	v := &validator{}
+	doc := &Document{
+		Title:      playlist.Title,
+		Creator:    playlist.Creator,
+		Annotation: playlist.Annotation,
+		Identifier: v.uri("identifier", playlist.Identifier),
+		Image:      v.uri("image", playlist.Image),
+		Date:       v.dateTime("date", playlist.Date),
+	}
+	v.uri("info", playlist.Info)
+	v.uri("location", playlist.Location)
+	v.uri("license", playlist.License)
+	for application, raw := range playlist.Extension {
+		v.uri("extension application", application)
+		if application == ExtensionPlaylist {
+			var ext jspfPlaylistExtension
+			if err := json.Unmarshal(raw, &ext); err != nil {
+				v.fail("extension %s is invalid", application)
+			}
+			doc.Public = ext.Public
+		}
+	}
+	if v.err != nil {
+		return nil, v.err
+	}
+
+	for i, track := range playlist.Track {
+		v := &validator{prefix: fmt.Sprintf("track %d: ", i+1)}
+		entry := Entry{
+			Line:   i + 1,
+			Title:  track.Title,
+			Artist: track.Creator,
+			Album:  track.Album,
+		}
+		v.uri("info", track.Info)
+		v.uri("image", track.Image)
+
+		if track.TrackNum != nil {
+			if *track.TrackNum < 1 {
+				v.fail("trackNum must be a positive integer")
+			}
+			entry.TrackNumber = *track.TrackNum
+		}
+		if track.Duration != nil {
+			if *track.Duration < 0 {
+				v.fail("duration must be a non-negative integer")
+			}
+			entry.Duration = time.Duration(*track.Duration) * time.Millisecond
+		}
+		for _, location := range track.Location {
+			v.uri("location", strings.TrimSpace(location))
+		}
+		if len(track.Location) > 0 {
+			entry.Location = strings.TrimSpace(track.Location[0])
+		}
+		for _, identifier := range track.Identifier {
+			entry.Identifiers = append(entry.Identifiers, v.uri("identifier", strings.TrimSpace(identifier)))
+		}
+		for application, raw := range track.Extension {
+			v.uri("extension application", application)
+			if application == ExtensionTrack {
+				var ext jspfTrackExtension
+				if err := json.Unmarshal(raw, &ext); err != nil {
+					v.fail("extension %s is invalid", application)
+				} else if ext.AddedAt != "" {
+					entry.AddedAt = v.dateTime("extension added_at", ext.AddedAt)
+				}
+			}
+		}
+		if v.err != nil {
+			return nil, v.err
+		}
+
+		entry.fillFromLocation()
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,108 @@
+package playlistformat
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// EncodeM3U renders doc as an extended M3U playlist. Plain .m3u files are
+// Latin-1 encoded, characters outside that charset are replaced.
+func EncodeM3U(doc *Document, format string) ([]byte, error) {
+	var b strings.Builder
+
+	b.WriteString("#EXTM3U\n")
+	if doc.Title != "" {
+		fmt.Fprintf(&b, "#PLAYLIST:%s\n", singleLine(doc.Title))
+	}
+	for _, entry := range doc.Entries {
+		fmt.Fprintf(&b, "#EXTINF:%d,%s\n", entry.seconds(), singleLine(entry.DisplayName()))
+		b.WriteString(singleLine(entry.Location) + "\n")
+	}
+
+	if format == FormatM3U8 {
+		return []byte(b.String()), nil
+	}
+
+	encoder := charmap.ISO8859_1.NewEncoder()
+	var out bytes.Buffer
+	for _, r := range b.String() {
+		encoded, err := encoder.String(string(r))
+		if err != nil {
+			out.WriteByte('?')
+			continue
+		}
+		out.WriteString(encoded)
+	}
+	return out.Bytes(), nil
+}
+
+// ParseM3U parses a plain or extended M3U/M3U8 playlist. Content that is not
+// valid UTF-8 is decoded as Windows-1252, the usual encoding of .m3u files.
+func ParseM3U(data []byte) (*Document, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		decoded, err := charmap.Windows1252.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid M3U encoding: %v", err)
+		}
+		data = decoded
+	}
+
+	doc := &Document{}
+	var pending *Entry
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			durationPart, display, _ := strings.Cut(info, ",")
+			// Attributes such as tvg-id="..." may follow the duration
+			if fields := strings.Fields(durationPart); len(fields) > 0 {
+				durationPart = fields[0]
+			}
+			entry := Entry{Line: lineNumber}
+			if seconds, err := strconv.ParseFloat(durationPart, 64); err == nil && seconds > 0 {
+				entry.Duration = time.Duration(seconds * float64(time.Second))
+			}
+			entry.Artist, entry.Title = SplitArtistTitle(display)
+			pending = &entry
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			doc.Title = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			entry := Entry{Line: lineNumber}
+			if pending != nil {
+				entry = *pending
+				pending = nil
+			}
+			entry.Location = line
+			entry.fillFromLocation()
+			doc.Entries = append(doc.Entries, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading M3U playlist: %v", err)
+	}
+
+	return doc, nil
+}
+
+// singleLine keeps values from breaking the line based formats
+func singleLine(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
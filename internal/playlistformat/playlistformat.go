@@ -0,0 +1,204 @@
+package playlistformat
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// FormatM3U is an extended M3U playlist encoded as Latin-1
+	FormatM3U = "m3u"
+	// FormatM3U8 is an extended M3U playlist encoded as UTF-8
+	FormatM3U8 = "m3u8"
+	// FormatPLS is a Winamp/SHOUTcast PLS playlist
+	FormatPLS = "pls"
+	// FormatXSPF is an XML Shareable Playlist Format document
+	FormatXSPF = "xspf"
+	// FormatJSPF is the JSON rendering of XSPF used by ListenBrainz
+	FormatJSPF = "jspf"
+)
+
+const (
+	// ExtensionPlaylist is the application URI of the playlist extension,
+	// shared with ListenBrainz, carrying the published state
+	ExtensionPlaylist = "https://musicbrainz.org/doc/jspf#playlist"
+	// ExtensionTrack is the application URI of the track extension carrying
+	// the time a track was added to the playlist
+	ExtensionTrack = "https://musicbrainz.org/doc/jspf#track"
+)
+
+// Entry is a single track of an interchange playlist
+type Entry struct {
+	// Line is the 1-based line (M3U) or track number (PLS, XSPF, JSPF) in the source document
+	Line        int
+	Title       string
+	Artist      string
+	Album       string
+	TrackNumber int
+	// Duration is the track length, zero when unknown
+	Duration time.Duration
+	// Location is where the track can be fetched from
+	Location string
+	// Identifiers are canonical URIs of the track, independent of its location
+	Identifiers []string
+	// AddedAt is when the track was added to the playlist, if the format records it
+	AddedAt *time.Time
+}
+
+// Document is a playlist in an interchange format
+type Document struct {
+	Title      string
+	Creator    string
+	Annotation string
+	Identifier string
+	Image      string
+	Date       *time.Time
+	// Public mirrors the published state of the playlist when the format records it
+	Public  *bool
+	Entries []Entry
+}
+
+// ContentTypes maps each format to the MIME type used when serving it
+var ContentTypes = map[string]string{
+	FormatM3U:  "audio/x-mpegurl",
+	FormatM3U8: "audio/x-mpegurl; charset=utf-8",
+	FormatPLS:  "audio/x-scpls",
+	FormatXSPF: "application/xspf+xml",
+	FormatJSPF: "application/json; charset=utf-8",
+}
+
+// Detect guesses the format of a playlist from its file name and content
+func Detect(data []byte, filename string) string {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".m3u":
+		return FormatM3U
+	case ".m3u8":
+		return FormatM3U8
+	case ".pls":
+		return FormatPLS
+	case ".xspf":
+		return FormatXSPF
+	case ".jspf", ".json":
+		return FormatJSPF
+	}
+
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	switch {
+	case bytes.HasPrefix(bytes.ToLower(trimmed), []byte("[playlist]")):
+		return FormatPLS
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return FormatXSPF
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatJSPF
+	}
+	return FormatM3U8
+}
+
+// SplitArtistTitle splits the conventional "Artist - Title" display string
+func SplitArtistTitle(display string) (string, string) {
+	display = strings.TrimSpace(display)
+	if artist, title, found := strings.Cut(display, " - "); found {
+		return strings.TrimSpace(artist), strings.TrimSpace(title)
+	}
+	return "", display
+}
+
+// DisplayName returns the "Artist - Title" string of an entry
+func (e Entry) DisplayName() string {
+	if e.Artist == "" {
+		return e.Title
+	}
+	return e.Artist + " - " + e.Title
+}
+
+// seconds returns the duration rounded to whole seconds, -1 when unknown as
+// expected by the M3U and PLS formats
+func (e Entry) seconds() int {
+	if e.Duration <= 0 {
+		return -1
+	}
+	return int(e.Duration.Round(time.Second) / time.Second)
+}
+
+// fillFromLocation derives artist and title from a file name such as
+// "Queen - Bohemian Rhapsody.mp3" when the playlist carries no track info
+func (e *Entry) fillFromLocation() {
+	if e.Title != "" || e.Location == "" {
+		return
+	}
+	base := path.Base(strings.ReplaceAll(e.Location, "\\", "/"))
+	if i := strings.IndexAny(base, "?#"); i >= 0 {
+		base = base[:i]
+	}
+	e.Artist, e.Title = SplitArtistTitle(strings.TrimSuffix(base, path.Ext(base)))
+}
+
+// validator records the first spec violation found while reading a document
+type validator struct {
+	prefix string
+	err    error
+}
+
+func (v *validator) fail(format string, args ...interface{}) {
+	if v.err == nil {
+		v.err = fmt.Errorf(v.prefix+format, args...)
+	}
+}
+
+// single returns the value of an element allowed at most once
+func (v *validator) single(name string, values []string) string {
+	if len(values) > 1 {
+		v.fail("%s must appear at most once", name)
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(values[0])
+}
+
+func (v *validator) required(name, value string) string {
+	if value == "" {
+		v.fail("%s is required", name)
+	}
+	return value
+}
+
+func (v *validator) uri(name, value string) string {
+	if value == "" {
+		return ""
+	}
+	if _, err := url.Parse(value); err != nil {
+		v.fail("%s must be a valid URI", name)
+	}
+	return value
+}
+
+func (v *validator) positive(name, value string) int {
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		v.fail("%s must be a positive integer", name)
+	}
+	return n
+}
+
+// dateTime parses an xsd:dateTime, whose timezone is optional
+func (v *validator) dateTime(name, value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t
+		}
+	}
+	v.fail("%s must be an XML Schema dateTime", name)
+	return nil
+}
@@ -0,0 +1,245 @@
+package playlistformat
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestM3URoundTrip(t *testing.T) {
+	doc := &Document{
+		Title: "Rock Classics",
+		Entries: []Entry{
+			{Title: "Bohemian Rhapsody", Artist: "Queen", Duration: 354 * time.Second, Location: "http://localhost/songs/1/stream"},
+			{Title: "Canción", Artist: "Artista", Location: "music/cancion.mp3"},
+		},
+	}
+
+	data, err := EncodeM3U(doc, FormatM3U8)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(string(data), "#EXTINF:354,Queen - Bohemian Rhapsody\n") {
+		t.Errorf("Expected EXTINF line, got:\n%s", data)
+	}
+
+	parsed, err := ParseM3U(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if parsed.Title != "Rock Classics" || len(parsed.Entries) != 2 {
+		t.Fatalf("Unexpected document: %+v", parsed)
+	}
+	if parsed.Entries[1].Title != "Canción" || parsed.Entries[1].Artist != "Artista" {
+		t.Errorf("Expected UTF-8 entry to round trip, got %+v", parsed.Entries[1])
+	}
+}
+
+func TestEncodeM3ULatin1(t *testing.T) {
+	doc := &Document{Entries: []Entry{{Title: "Canción 日本", Artist: "A", Duration: time.Second, Location: "x"}}}
+
+	data, err := EncodeM3U(doc, FormatM3U)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(string(data), "Canci\xf3n ??") {
+		t.Errorf("Expected Latin-1 output with replacements, got %q", data)
+	}
+
+	parsed, _ := ParseM3U(data)
+	if parsed.Entries[0].Title != "Canción ??" {
+		t.Errorf("Expected Latin-1 input to be decoded, got %q", parsed.Entries[0].Title)
+	}
+}
+
+func TestParseM3UPlain(t *testing.T) {
+	parsed, err := ParseM3U([]byte("# comment\nC:\\Music\\Eagles - Hotel California.mp3\n\n/music/track.flac\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(parsed.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(parsed.Entries))
+	}
+	first := parsed.Entries[0]
+	if first.Artist != "Eagles" || first.Title != "Hotel California" || first.Line != 2 {
+		t.Errorf("Expected artist and title from file name, got %+v", first)
+	}
+	if parsed.Entries[1].Title != "track" || parsed.Entries[1].Duration != 0 {
+		t.Errorf("Unexpected entry: %+v", parsed.Entries[1])
+	}
+}
+
+func TestPLSRoundTrip(t *testing.T) {
+	doc := &Document{Entries: []Entry{
+		{Title: "One", Artist: "A", Duration: 10 * time.Second, Location: "one.mp3"},
+		{Title: "Two", Artist: "B", Location: "two.mp3"},
+	}}
+
+	parsed, err := ParsePLS(EncodePLS(doc))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(parsed.Entries) != 2 || parsed.Entries[0].Title != "One" || parsed.Entries[1].Artist != "B" {
+		t.Errorf("Unexpected entries: %+v", parsed.Entries)
+	}
+	if parsed.Entries[0].Duration != 10*time.Second {
+		t.Errorf("Expected length 10, got %v", parsed.Entries[0].Duration)
+	}
+}
+
+func TestParsePLSInvalid(t *testing.T) {
+	if _, err := ParsePLS([]byte("File1=one.mp3\n")); err == nil {
+		t.Error("Expected error for missing [playlist] header")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	if Detect([]byte("[playlist]\nFile1=a"), "") != FormatPLS {
+		t.Error("Expected PLS to be detected from content")
+	}
+	if Detect([]byte("#EXTM3U"), "list.m3u") != FormatM3U {
+		t.Error("Expected M3U to be detected from extension")
+	}
+	if Detect([]byte("#EXTM3U"), "") != FormatM3U8 {
+		t.Error("Expected M3U8 to be the default")
+	}
+	if Detect([]byte(`<?xml version="1.0"?><playlist/>`), "") != FormatXSPF {
+		t.Error("Expected XSPF to be detected from content")
+	}
+	if Detect([]byte(`{"playlist":{}}`), "") != FormatJSPF {
+		t.Error("Expected JSPF to be detected from content")
+	}
+}
+
+func interchangeDocument() *Document {
+	public := true
+	addedAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	return &Document{
+		Title:      "Road Trip",
+		Creator:    "Melodia",
+		Annotation: "Songs for the long drive",
+		Identifier: "http://localhost/playlists/3",
+		Public:     &public,
+		Entries: []Entry{
+			{
+				Title:       "Bohemian Rhapsody",
+				Artist:      "Queen",
+				Album:       "A Night at the Opera",
+				TrackNumber: 11,
+				Duration:    354321 * time.Millisecond,
+				Location:    "http://localhost/songs/1/stream?expires=1&signature=abc",
+				Identifiers: []string{"http://localhost/songs/1"},
+				AddedAt:     &addedAt,
+			},
+			{Title: "Hotel California", Artist: "Eagles"},
+		},
+	}
+}
+
+func assertInterchangeDocument(t *testing.T, parsed *Document) {
+	t.Helper()
+
+	if parsed.Title != "Road Trip" || parsed.Creator != "Melodia" || parsed.Annotation != "Songs for the long drive" {
+		t.Errorf("Unexpected playlist fields: %+v", parsed)
+	}
+	if parsed.Public == nil || !*parsed.Public {
+		t.Error("Expected public extension to round trip")
+	}
+	if len(parsed.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(parsed.Entries))
+	}
+
+	first := parsed.Entries[0]
+	if first.Title != "Bohemian Rhapsody" || first.Artist != "Queen" || first.Album != "A Night at the Opera" {
+		t.Errorf("Unexpected entry: %+v", first)
+	}
+	if first.TrackNumber != 11 || first.Duration != 354321*time.Millisecond {
+		t.Errorf("Expected track number and millisecond duration to round trip, got %+v", first)
+	}
+	if len(first.Identifiers) != 1 || first.Identifiers[0] != "http://localhost/songs/1" {
+		t.Errorf("Expected identifier to round trip, got %v", first.Identifiers)
+	}
+	if first.AddedAt == nil || !first.AddedAt.Equal(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected added_at extension to round trip, got %v", first.AddedAt)
+	}
+	if parsed.Entries[1].Line != 2 || parsed.Entries[1].Location != "" {
+		t.Errorf("Unexpected second entry: %+v", parsed.Entries[1])
+	}
+}
+
+func TestXSPFRoundTrip(t *testing.T) {
+	data, err := EncodeXSPF(interchangeDocument())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(string(data), `<playlist xmlns="http://xspf.org/ns/0/" version="1">`) {
+		t.Errorf("Expected namespaced playlist element, got:\n%s", data)
+	}
+
+	parsed, err := ParseXSPF(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	assertInterchangeDocument(t, parsed)
+}
+
+func TestJSPFRoundTrip(t *testing.T) {
+	data, err := EncodeJSPF(interchangeDocument())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	parsed, err := ParseJSPF(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	assertInterchangeDocument(t, parsed)
+}
+
+func TestParseXSPFInvalid(t *testing.T) {
+	tests := map[string]string{
+		"wrong namespace":               `<playlist version="1"><trackList/></playlist>`,
+		"bad version":                   `<playlist version="2" xmlns="http://xspf.org/ns/0/"><trackList/></playlist>`,
+		"missing trackList":             `<playlist version="1" xmlns="http://xspf.org/ns/0/"><title>x</title></playlist>`,
+		"repeated title":                `<playlist version="1" xmlns="http://xspf.org/ns/0/"><title>a</title><title>b</title><trackList/></playlist>`,
+		"negative duration":             `<playlist version="1" xmlns="http://xspf.org/ns/0/"><trackList><track><duration>-5</duration></track></trackList></playlist>`,
+		"zero trackNum":                 `<playlist version="1" xmlns="http://xspf.org/ns/0/"><trackList><track><trackNum>0</trackNum></track></trackList></playlist>`,
+		"extension without application": `<playlist version="1" xmlns="http://xspf.org/ns/0/"><extension/><trackList/></playlist>`,
+	}
+
+	for name, doc := range tests {
+		if _, err := ParseXSPF([]byte(doc)); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestParseJSPFInvalid(t *testing.T) {
+	tests := map[string]string{
+		"missing playlist":  `{"title":"x"}`,
+		"missing track":     `{"playlist":{"title":"x"}}`,
+		"wrong type":        `{"playlist":{"title":1,"track":[]}}`,
+		"negative duration": `{"playlist":{"track":[{"duration":-1}]}}`,
+		"bad date":          `{"playlist":{"date":"yesterday","track":[]}}`,
+	}
+
+	for name, doc := range tests {
+		if _, err := ParseJSPF([]byte(doc)); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestParseJSPFStringIdentifier(t *testing.T) {
+	doc := `{"playlist":{"track":[{"title":"Song","creator":"Artist","identifier":"https://musicbrainz.org/recording/1"}]}}`
+	parsed, err := ParseJSPF([]byte(doc))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(parsed.Entries[0].Identifiers) != 1 {
+		t.Errorf("Expected single string identifier to be accepted, got %v", parsed.Entries[0].Identifiers)
+	}
+}
@@ -0,0 +1,117 @@
+package playlistformat
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// EncodePLS renders doc as a version 2 PLS playlist
+func EncodePLS(doc *Document) []byte {
+	var b strings.Builder
+
+	b.WriteString("[playlist]\n")
+	for i, entry := range doc.Entries {
+		n := i + 1
+		fmt.Fprintf(&b, "File%d=%s\n", n, singleLine(entry.Location))
+		fmt.Fprintf(&b, "Title%d=%s\n", n, singleLine(entry.DisplayName()))
+		fmt.Fprintf(&b, "Length%d=%d\n", n, entry.seconds())
+	}
+	fmt.Fprintf(&b, "NumberOfEntries=%d\n", len(doc.Entries))
+	b.WriteString("Version=2\n")
+
+	return []byte(b.String())
+}
+
+// ParsePLS parses a PLS playlist, ordering entries by their number
+func ParsePLS(data []byte) (*Document, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		decoded, err := charmap.Windows1252.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PLS encoding: %v", err)
+		}
+		data = decoded
+	}
+
+	entries := map[int]*Entry{}
+	entry := func(n int) *Entry {
+		if entries[n] == nil {
+			entries[n] = &Entry{Line: n}
+		}
+		return entries[n]
+	}
+
+	sawHeader := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.EqualFold(line, "[playlist]") {
+			sawHeader = true
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid PLS line: %q", line)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		for _, field := range []string{"file", "title", "length"} {
+			if !strings.HasPrefix(key, field) {
+				continue
+			}
+			n, err := strconv.Atoi(strings.TrimPrefix(key, field))
+			if err != nil || n < 1 {
+				continue
+			}
+			switch field {
+			case "file":
+				entry(n).Location = value
+			case "title":
+				entry(n).Artist, entry(n).Title = SplitArtistTitle(value)
+			case "length":
+				if length, err := strconv.Atoi(value); err == nil && length > 0 {
+					entry(n).Duration = time.Duration(length) * time.Second
+				}
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading PLS playlist: %v", err)
+	}
+	if !sawHeader {
+		return nil, fmt.Errorf("missing [playlist] header")
+	}
+
+	numbers := make([]int, 0, len(entries))
+	for n := range entries {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	doc := &Document{}
+	for _, n := range numbers {
+		e := entries[n]
+		if e.Location == "" {
+			continue
+		}
+		e.fillFromLocation()
+		doc.Entries = append(doc.Entries, *e)
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,204 @@
+package playlistformat
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XSPFNamespace is the XML namespace of XSPF version 0 and 1 documents
+const XSPFNamespace = "http://xspf.org/ns/0/"
+
+// xspfPlaylist mirrors the XSPF playlist element. Single valued elements are
+// decoded as slices so documents repeating them can be rejected.
+type xspfPlaylist struct {
+	XMLName    xml.Name        `xml:"http://xspf.org/ns/0/ playlist"`
+	Version    string          `xml:"version,attr"`
+	Title      []string        `xml:"title"`
+	Creator    []string        `xml:"creator"`
+	Annotation []string        `xml:"annotation"`
+	Info       []string        `xml:"info"`
+	Location   []string        `xml:"location"`
+	Identifier []string        `xml:"identifier"`
+	Image      []string        `xml:"image"`
+	Date       []string        `xml:"date"`
+	License    []string        `xml:"license"`
+	Extensions []xspfExtension `xml:"extension"`
+	TrackLists []xspfTrackList `xml:"trackList"`
+}
+
+type xspfTrackList struct {
+	Tracks []xspfTrack `xml:"track"`
+}
+
+type xspfTrack struct {
+	Location   []string        `xml:"location"`
+	Identifier []string        `xml:"identifier"`
+	Title      []string        `xml:"title"`
+	Creator    []string        `xml:"creator"`
+	Annotation []string        `xml:"annotation"`
+	Info       []string        `xml:"info"`
+	Image      []string        `xml:"image"`
+	Album      []string        `xml:"album"`
+	TrackNum   []string        `xml:"trackNum"`
+	Duration   []string        `xml:"duration"`
+	Extensions []xspfExtension `xml:"extension"`
+}
+
+// xspfExtension holds the extension fields understood by this package; the
+// content of other applications' extensions is ignored
+type xspfExtension struct {
+	Application string `xml:"application,attr"`
+	Public      string `xml:"public,omitempty"`
+	AddedAt     string `xml:"added_at,omitempty"`
+}
+
+// EncodeXSPF renders doc as an XSPF version 1 document
+func EncodeXSPF(doc *Document) ([]byte, error) {
+	playlist := xspfPlaylist{
+		Version:    "1",
+		Title:      optional(doc.Title),
+		Creator:    optional(doc.Creator),
+		Annotation: optional(doc.Annotation),
+		Identifier: optional(doc.Identifier),
+		Image:      optional(doc.Image),
+		TrackLists: []xspfTrackList{{Tracks: []xspfTrack{}}},
+	}
+	if doc.Date != nil {
+		playlist.Date = []string{doc.Date.Format(time.RFC3339)}
+	}
+	if doc.Public != nil {
+		playlist.Extensions = []xspfExtension{{Application: ExtensionPlaylist, Public: strconv.FormatBool(*doc.Public)}}
+	}
+
+	for _, entry := range doc.Entries {
+		track := xspfTrack{
+			Location:   optional(entry.Location),
+			Identifier: entry.Identifiers,
+			Title:      optional(entry.Title),
+			Creator:    optional(entry.Artist),
+			Album:      optional(entry.Album),
+		}
+		if entry.TrackNumber > 0 {
+			track.TrackNum = []string{strconv.Itoa(entry.TrackNumber)}
+		}
+		if entry.Duration > 0 {
+			track.Duration = []string{strconv.FormatInt(entry.Duration.Milliseconds(), 10)}
+		}
+		if entry.AddedAt != nil {
+			track.Extensions = []xspfExtension{{Application: ExtensionTrack, AddedAt: entry.AddedAt.Format(time.RFC3339)}}
+		}
+		playlist.TrackLists[0].Tracks = append(playlist.TrackLists[0].Tracks, track)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&b)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(playlist); err != nil {
+		return nil, fmt.Errorf("error encoding XSPF playlist: %v", err)
+	}
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+// ParseXSPF parses and validates an XSPF version 0 or 1 document
+func ParseXSPF(data []byte) (*Document, error) {
+	var playlist xspfPlaylist
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&playlist); err != nil {
+		if strings.Contains(err.Error(), "expected element type") {
+			return nil, fmt.Errorf("root element must be a playlist in the %s namespace", XSPFNamespace)
+		}
+		return nil, fmt.Errorf("invalid XML: %v", err)
+	}
+
+	if playlist.Version != "0" && playlist.Version != "1" {
+		return nil, fmt.Errorf("playlist version must be 0 or 1")
+	}
+	if len(playlist.TrackLists) != 1 {
+		return nil, fmt.Errorf("playlist must contain exactly one trackList")
+	}
+
+	v := &validator{}
+	doc := &Document{
+		Title:      v.single("title", playlist.Title),
+		Creator:    v.single("creator", playlist.Creator),
+		Annotation: v.single("annotation", playlist.Annotation),
+		Identifier: v.uri("identifier", v.single("identifier", playlist.Identifier)),
+		Image:      v.uri("image", v.single("image", playlist.Image)),
+	}
+	v.uri("info", v.single("info", playlist.Info))
+	v.uri("location", v.single("location", playlist.Location))
+	v.uri("license", v.single("license", playlist.License))
+	doc.Date = v.dateTime("date", v.single("date", playlist.Date))
+	for _, ext := range playlist.Extensions {
+		v.uri("extension application", v.required("extension application", ext.Application))
+		if ext.Application == ExtensionPlaylist && ext.Public != "" {
+			public, err := strconv.ParseBool(ext.Public)
+			if err != nil {
+				v.fail("extension public must be a boolean")
+			}
+			doc.Public = &public
+		}
+	}
+	if v.err != nil {
+		return nil, v.err
+	}
+
+	for i, track := range playlist.TrackLists[0].Tracks {
+		v := &validator{prefix: fmt.Sprintf("track %d: ", i+1)}
+		entry := Entry{
+			Line:        i + 1,
+			Title:       v.single("title", track.Title),
+			Artist:      v.single("creator", track.Creator),
+			Album:       v.single("album", track.Album),
+			TrackNumber: v.positive("trackNum", v.single("trackNum", track.TrackNum)),
+		}
+		v.single("annotation", track.Annotation)
+		v.uri("info", v.single("info", track.Info))
+		v.uri("image", v.single("image", track.Image))
+
+		if duration := v.single("duration", track.Duration); duration != "" {
+			ms, err := strconv.ParseInt(duration, 10, 64)
+			if err != nil || ms < 0 {
+				v.fail("duration must be a non-negative integer")
+			}
+			entry.Duration = time.Duration(ms) * time.Millisecond
+		}
+		for _, location := range track.Location {
+			v.uri("location", strings.TrimSpace(location))
+		}
+		if len(track.Location) > 0 {
+			entry.Location = strings.TrimSpace(track.Location[0])
+		}
+		for _, identifier := range track.Identifier {
+			entry.Identifiers = append(entry.Identifiers, v.uri("identifier", strings.TrimSpace(identifier)))
+		}
+		for _, ext := range track.Extensions {
+			v.uri("extension application", v.required("extension application", ext.Application))
+			if ext.Application == ExtensionTrack && ext.AddedAt != "" {
+				entry.AddedAt = v.dateTime("extension added_at", ext.AddedAt)
+			}
+		}
+		if v.err != nil {
+			return nil, v.err
+		}
+
+		entry.fillFromLocation()
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return doc, nil
+}
+
+// optional turns an empty value into an omitted element
+func optional(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
@@ -0,0 +1,283 @@
+// Package playqueue implements the play queue of a listener: the songs lined up,
+// which one is playing and how the queue moves on when a song ends
+package playqueue
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sort"
+)
+
+// Repeat modes
+const (
+	RepeatOff = "off"
+	RepeatAll = "all"
+	RepeatOne = "one"
+)
+
+// MaxItems is how many songs a queue can hold
+const MaxItems = 1000
+
+var (
+	// ErrItemNotFound is returned for item IDs that are not in the queue
+	ErrItemNotFound = errors.New("queue item not found")
+	// ErrFull is returned when adding songs would exceed MaxItems
+	ErrFull = errors.New("queue is full")
+	// ErrIndexOutOfRange is returned for indexes outside the queue
+	ErrIndexOutOfRange = errors.New("index out of range")
+	// ErrInvalidRepeat is returned for unknown repeat modes
+	ErrInvalidRepeat = errors.New("invalid repeat mode")
+)
+
+// Item is a song in a queue. The same song can be queued several times, each
+// time as a different item.
+type Item struct {
+	ID     int64
+	SongID uint
+	// Position is the place of the item in the order songs were queued in,
+	// which is restored when shuffle is turned off
+	Position int
+}
+
+// Queue is the play queue of a listener
+type Queue struct {
+	// Items are in play order
+	Items []Item
+	// Current is the index of the playing item, or -1 when nothing is playing
+	Current int
+	Shuffle bool
+	Repeat  string
+	// NextID is the ID the next queued item gets
+	NextID int64
+}
+
+// New returns an empty queue
+func New() *Queue {
+	return &Queue{Current: -1, Repeat: RepeatOff, NextID: 1}
+}
+
+// Add queues songs at the end, or right after the playing item when next is
+// set. With shuffle on, songs played next still come after the playing item
+// once shuffle is turned off.
+func (q *Queue) Add(songIDs []uint, next bool) error {
+	if len(q.Items)+len(songIDs) > MaxItems {
+		return ErrFull
+	}
+
+	added := make([]Item, len(songIDs))
+	for i, songID := range songIDs {
+		added[i] = Item{ID: q.NextID, SongID: songID}
+		q.NextID++
+	}
+
+	at := len(q.Items)
+	if next {
+		at = q.Current + 1
+	}
+
+	// Place the new items in the original order too, after the playing item or at the end
+	original := q.originalOrder()
+	originalAt := len(original)
+	if next {
+		originalAt = 0
+		if q.Current >= 0 {
+			originalAt = q.Items[q.Current].Position + 1
+		}
+	}
+	if !q.Shuffle {
+		originalAt = at
+	}
+	original = insertItems(original, originalAt, added)
+
+	q.Items = insertItems(q.Items, at, added)
+	q.setPositions(original)
+	return nil
+}
+
+// Remove takes an item out of the queue. Removing the playing item makes the
+// following one current.
+func (q *Queue) Remove(id int64) error {
+	index := q.indexOf(id)
+	if index < 0 {
+		return ErrItemNotFound
+	}
+
+	original := removeItem(q.originalOrder(), id)
+	q.Items = append(q.Items[:index], q.Items[index+1:]...)
+	switch {
+	case index < q.Current:
+		q.Current--
+	case q.Current >= len(q.Items):
+		q.Current = -1
+	}
+
+	q.setPositions(original)
+	return nil
+}
+
+// Move moves an item to another index of the play order
+func (q *Queue) Move(id int64, index int) error {
+	from := q.indexOf(id)
+	if from < 0 {
+		return ErrItemNotFound
+	}
+	if index < 0 || index >= len(q.Items) {
+		return ErrIndexOutOfRange
+	}
+
+	current := q.currentID()
+	item := q.Items[from]
+	q.Items = append(q.Items[:from], q.Items[from+1:]...)
+	q.Items = insertItems(q.Items, index, []Item{item})
+	q.Current = q.indexOf(current)
+
+	if !q.Shuffle {
+		q.setPositions(q.Items)
+	}
+	return nil
+}
+
+// Clear removes every item. Modes are kept.
+func (q *Queue) Clear() {
+	q.Items = nil
+	q.Current = -1
+}
+
+// Jump starts playing the item at an index of the play order
+func (q *Queue) Jump(index int) error {
+	if index < 0 || index >= len(q.Items) {
+		return ErrIndexOutOfRange
+	}
+	q.Current = index
+	return nil
+}
+
+// Next moves on to the following item as if the playing one ended. Past the
+// last item the queue starts over with repeat all and stops otherwise.
+func (q *Queue) Next() {
+	switch {
+	case len(q.Items) == 0:
+		q.Current = -1
+	case q.Current < 0:
+		q.Current = 0
+	case q.Repeat == RepeatOne:
+	case q.Current+1 < len(q.Items):
+		q.Current++
+	case q.Repeat == RepeatAll:
+		q.Current = 0
+	default:
+		q.Current = -1
+	}
+}
+
+// Previous goes back to the item before the playing one. Before the first item
+// the queue goes to the last one with repeat all and stays at the first otherwise.
+func (q *Queue) Previous() {
+	switch {
+	case len(q.Items) == 0:
+		q.Current = -1
+	case q.Current < 0:
+		q.Current = len(q.Items) - 1
+	case q.Repeat == RepeatOne:
+	case q.Current > 0:
+		q.Current--
+	case q.Repeat == RepeatAll:
+		q.Current = len(q.Items) - 1
+	}
+}
+
+// SetShuffle turns shuffle on or off. Turning it on keeps the playing item
+// first and shuffles the rest; turning it off restores the original order.
+// The playing item stays current either way.
+func (q *Queue) SetShuffle(on bool, rng *rand.Rand) {
+	if on == q.Shuffle {
+		return
+	}
+	q.Shuffle = on
+	current := q.currentID()
+
+	if !on {
+		q.Items = q.originalOrder()
+		q.Current = q.indexOf(current)
+		return
+	}
+
+	rest := make([]Item, 0, len(q.Items))
+	var first []Item
+	for _, item := range q.Items {
+		if item.ID == current {
+			first = append(first, item)
+		} else {
+			rest = append(rest, item)
+		}
+	}
+	rng.Shuffle(len(rest), func(i, j int) { rest[i], rest[j] = rest[j], rest[i] })
+	q.Items = append(first, rest...)
+	q.Current = q.indexOf(current)
+}
+
+// SetRepeat changes the repeat mode
+func (q *Queue) SetRepeat(mode string) error {
+	switch mode {
+	case RepeatOff, RepeatAll, RepeatOne:
+		q.Repeat = mode
+		return nil
+	default:
+		return ErrInvalidRepeat
+	}
+}
+
+// indexOf returns the index of an item in the play order, or -1
+func (q *Queue) indexOf(id int64) int {
+	for i, item := range q.Items {
+		if item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// currentID returns the ID of the playing item, or 0
+func (q *Queue) currentID() int64 {
+	if q.Current < 0 || q.Current >= len(q.Items) {
+		return 0
+	}
+	return q.Items[q.Current].ID
+}
+
+// originalOrder returns a copy of the items in the order they were queued in
+func (q *Queue) originalOrder() []Item {
+	original := append([]Item(nil), q.Items...)
+	sort.SliceStable(original, func(i, j int) bool { return original[i].Position < original[j].Position })
+	return original
+}
+
+// setPositions numbers the items after their place in original
+func (q *Queue) setPositions(original []Item) {
+	positions := make(map[int64]int, len(original))
+	for i, item := range original {
+		positions[item.ID] = i
+	}
+	for i := range q.Items {
+		q.Items[i].Position = positions[q.Items[i].ID]
+	}
+}
+
+// insertItems returns items with added inserted at index
+func insertItems(items []Item, index int, added []Item) []Item {
+	result := make([]Item, 0, len(items)+len(added))
+	result = append(result, items[:index]...)
+	result = append(result, added...)
+	return append(result, items[index:]...)
+}
+
+// removeItem returns items without the item with the given ID
+func removeItem(items []Item, id int64) []Item {
+	result := items[:0]
+	for _, item := range items {
+		if item.ID != id {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,156 @@
+package playqueue
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+// songs returns the song IDs of a queue in play order
+func songs(q *Queue) []uint {
+	ids := make([]uint, len(q.Items))
+	for i, item := range q.Items {
+		ids[i] = item.SongID
+	}
+	return ids
+}
+
+func newQueue(t *testing.T, songIDs ...uint) *Queue {
+	q := New()
+	if err := q.Add(songIDs, false); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	return q
+}
+
+func TestAdd(t *testing.T) {
+	q := newQueue(t, 1, 2, 3)
+	if q.Current != -1 {
+		t.Errorf("Expected nothing playing, got %d", q.Current)
+	}
+
+	q.Next()
+	q.Next()
+	if err := q.Add([]uint{9, 8}, true); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if got := songs(q); !reflect.DeepEqual(got, []uint{1, 2, 9, 8, 3}) {
+		t.Errorf("Expected songs played next after the current one, got %v", got)
+	}
+	if q.Items[q.Current].SongID != 2 {
+		t.Errorf("Expected song 2 to keep playing, got %d", q.Items[q.Current].SongID)
+	}
+
+	// The same song can be queued twice as different items
+	if err := q.Add([]uint{1}, false); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if q.Items[0].ID == q.Items[5].ID {
+		t.Error("Expected different item IDs for the same song")
+	}
+
+	full := make([]uint, MaxItems)
+	if err := q.Add(full, false); err != ErrFull {
+		t.Errorf("Expected ErrFull, got %v", err)
+	}
+}
+
+func TestRemoveAndMove(t *testing.T) {
+	q := newQueue(t, 1, 2, 3, 4)
+	q.Jump(2)
+
+	if err := q.Remove(q.Items[0].ID); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if q.Items[q.Current].SongID != 3 {
+		t.Errorf("Expected song 3 to keep playing, got %d", q.Items[q.Current].SongID)
+	}
+
+	// Removing the playing item makes the following one current
+	q.Remove(q.Items[q.Current].ID)
+	if got := songs(q); !reflect.DeepEqual(got, []uint{2, 4}) || q.Items[q.Current].SongID != 4 {
+		t.Errorf("Unexpected queue %v playing %d", got, q.Current)
+	}
+	q.Remove(q.Items[q.Current].ID)
+	if q.Current != -1 {
+		t.Errorf("Expected nothing playing after removing the last item, got %d", q.Current)
+	}
+
+	q = newQueue(t, 1, 2, 3, 4)
+	q.Jump(0)
+	if err := q.Move(q.Items[0].ID, 3); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if got := songs(q); !reflect.DeepEqual(got, []uint{2, 3, 4, 1}) || q.Current != 3 {
+		t.Errorf("Unexpected queue %v playing %d", got, q.Current)
+	}
+	if err := q.Move(q.Items[0].ID, 4); err != ErrIndexOutOfRange {
+		t.Errorf("Expected ErrIndexOutOfRange, got %v", err)
+	}
+	if err := q.Remove(999); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+}
+
+func TestNextAndPrevious(t *testing.T) {
+	tests := []struct {
+		repeat   string
+		next     []int
+		previous []int
+	}{
+		{RepeatOff, []int{0, 1, 2, -1, 0}, []int{2, 1, 0, 0}},
+		{RepeatAll, []int{0, 1, 2, 0, 1}, []int{2, 1, 0, 2}},
+		{RepeatOne, []int{0, 0, 0}, []int{2, 2}},
+	}
+
+	for _, test := range tests {
+		q := newQueue(t, 1, 2, 3)
+		q.SetRepeat(test.repeat)
+		for i, expected := range test.next {
+			q.Next()
+			if q.Current != expected {
+				t.Errorf("%s: next %d expected %d, got %d", test.repeat, i, expected, q.Current)
+			}
+		}
+
+		q.Current = -1
+		for i, expected := range test.previous {
+			q.Previous()
+			if q.Current != expected {
+				t.Errorf("%s: previous %d expected %d, got %d", test.repeat, i, expected, q.Current)
+			}
+		}
+	}
+
+	q := New()
+	if err := q.SetRepeat("forever"); err != ErrInvalidRepeat {
+		t.Errorf("Expected ErrInvalidRepeat, got %v", err)
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	q := newQueue(t, 1, 2, 3, 4, 5, 6, 7, 8)
+	q.Jump(3)
+
+	q.SetShuffle(true, rand.New(rand.NewPCG(1, 2)))
+	if q.Current != 0 || q.Items[0].SongID != 4 {
+		t.Errorf("Expected the playing song first, got %v playing %d", songs(q), q.Current)
+	}
+	if len(q.Items) != 8 {
+		t.Fatalf("Expected every song to stay queued, got %v", songs(q))
+	}
+
+	// Songs played next while shuffled land after the playing song in the original order too
+	q.Add([]uint{9}, true)
+	q.Next()
+	q.Move(q.Items[len(q.Items)-1].ID, 0)
+
+	playing := q.Items[q.Current].SongID
+	q.SetShuffle(false, nil)
+	if got := songs(q); !reflect.DeepEqual(got, []uint{1, 2, 3, 4, 9, 5, 6, 7, 8}) {
+		t.Errorf("Expected the original order back, got %v", got)
+	}
+	if q.Items[q.Current].SongID != playing {
+		t.Errorf("Expected song %d to keep playing, got %d", playing, q.Items[q.Current].SongID)
+	}
+}
@@ -0,0 +1,129 @@
+// Package radio picks the songs of a radio station: a weighted random walk from
+// song to song that keeps away from recent songs and long runs of one artist
+package radio
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+// Seed types of a station
+const (
+	SeedSong     = "song"
+	SeedArtist   = "artist"
+	SeedPlaylist = "playlist"
+)
+
+const (
+	// RepeatWindow is how many of the latest songs a station does not repeat
+	RepeatWindow = 50
+	// MaxArtistRun is how many songs in a row a station plays by one artist
+	MaxArtistRun = 2
+	// RestartProbability is the chance of each step jumping back to the seed
+	// songs instead of following the current song, keeping stations close to
+	// their seed
+	RestartProbability = 0.15
+	// MaxSeedSongs is how many songs a seed expands to
+	MaxSeedSongs = 500
+	// MaxBatch is how many songs can be asked for at once
+	MaxBatch = 50
+)
+
+// Candidate is a song a station can move to. Weight is relative to the other
+// candidates of the same step and must be positive.
+type Candidate struct {
+	SongID uint
+	Artist string
+	Weight float64
+}
+
+// State is what a station remembers of the songs it played
+type State struct {
+	// Recent holds the latest songs, oldest first, at most RepeatWindow
+	Recent []uint
+	// LastArtist is the artist of the latest song, compared case-insensitively
+	LastArtist string
+	// ArtistRun is how many songs in a row LastArtist played
+	ArtistRun int
+}
+
+// Current returns the latest song, or 0 when nothing was played
+func (s *State) Current() uint {
+	if len(s.Recent) == 0 {
+		return 0
+	}
+	return s.Recent[len(s.Recent)-1]
+}
+
+// Allows reports whether playing c next keeps the station away from recent
+// songs and long artist runs
+func (s *State) Allows(c Candidate) bool {
+	if slices.Contains(s.Recent, c.SongID) {
+		return false
+	}
+	return s.ArtistRun < MaxArtistRun || !sameArtist(s.LastArtist, c.Artist)
+}
+
+// Record remembers c as played
+func (s *State) Record(c Candidate) {
+	if sameArtist(s.LastArtist, c.Artist) {
+		s.ArtistRun++
+	} else {
+		s.LastArtist = c.Artist
+		s.ArtistRun = 1
+	}
+
+	s.Recent = append(s.Recent, c.SongID)
+	if len(s.Recent) > RepeatWindow {
+		s.Recent = slices.Clone(s.Recent[len(s.Recent)-RepeatWindow:])
+	}
+}
+
+// Pick chooses among the candidates state allows, with a chance proportional
+// to their weight. It returns false when state allows none of them.
+func Pick(candidates []Candidate, state *State, rng *rand.Rand) (Candidate, bool) {
+	total := 0.0
+	allowed := make([]Candidate, 0, len(candidates))
+	for _, c := range candidates {
+		if c.Weight > 0 && state.Allows(c) {
+			allowed = append(allowed, c)
+			total += c.Weight
+		}
+	}
+	if len(allowed) == 0 {
+		return Candidate{}, false
+	}
+
+	target := rng.Float64() * total
+	for _, c := range allowed {
+		target -= c.Weight
+		if target < 0 {
+			return c, true
+		}
+	}
+	// Rounding can leave target at zero after the last candidate
+	return allowed[len(allowed)-1], true
+}
+
+// Oldest chooses the candidate played longest ago, for catalogs too small to
+// keep away from recent songs and artist runs. It returns false when there are
+// no candidates.
+func Oldest(candidates []Candidate, state *State) (Candidate, bool) {
+	best, bestIndex := Candidate{}, len(state.Recent)
+	found := false
+	for _, c := range candidates {
+		index := slices.Index(state.Recent, c.SongID)
+		if index < 0 {
+			return c, true
+		}
+		if !found || index < bestIndex {
+			best, bestIndex, found = c, index, true
+		}
+	}
+	return best, found
+}
+
+func sameArtist(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
@@ -0,0 +1,128 @@
+package radio
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+func TestPickFollowsWeights(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	candidates := []Candidate{
+		{SongID: 1, Artist: "A", Weight: 9},
+		{SongID: 2, Artist: "B", Weight: 1},
+	}
+
+	counts := map[uint]int{}
+	for i := 0; i < 10000; i++ {
+		c, ok := Pick(candidates, &State{}, rng)
+		if !ok {
+			t.Fatal("Expected a candidate")
+		}
+		counts[c.SongID]++
+	}
+
+	// 9 to 1, allowing for chance
+	if counts[1] < 8800 || counts[1] > 9200 {
+		t.Errorf("Expected about 9000 picks of the heavier song, got %d", counts[1])
+	}
+}
+
+func TestPickAvoidsRecentSongs(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	state := &State{}
+	state.Record(Candidate{SongID: 1, Artist: "A"})
+
+	candidates := []Candidate{
+		{SongID: 1, Artist: "A", Weight: 100},
+		{SongID: 2, Artist: "B", Weight: 1},
+	}
+	for i := 0; i < 100; i++ {
+		if c, _ := Pick(candidates, state, rng); c.SongID != 2 {
+			t.Fatalf("Expected the recent song to be skipped, got %d", c.SongID)
+		}
+	}
+
+	if _, ok := Pick(candidates[:1], state, rng); ok {
+		t.Error("Expected no candidate when only recent songs are left")
+	}
+}
+
+func TestPickLimitsArtistRuns(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	state := &State{}
+	state.Record(Candidate{SongID: 1, Artist: "Queen"})
+	state.Record(Candidate{SongID: 2, Artist: "queen "})
+	if state.ArtistRun != 2 {
+		t.Fatalf("Expected a run of 2, got %d", state.ArtistRun)
+	}
+
+	candidates := []Candidate{
+		{SongID: 3, Artist: "QUEEN", Weight: 100},
+		{SongID: 4, Artist: "ABBA", Weight: 1},
+	}
+	if c, _ := Pick(candidates, state, rng); c.SongID != 4 {
+		t.Errorf("Expected a different artist after a run of %d, got song %d", MaxArtistRun, c.SongID)
+	}
+
+	state.Record(Candidate{SongID: 4, Artist: "ABBA"})
+	if !state.Allows(candidates[0]) {
+		t.Error("Expected the artist to be allowed again after another artist")
+	}
+}
+
+func TestRecordKeepsWindow(t *testing.T) {
+	state := &State{}
+	for id := uint(1); id <= RepeatWindow+5; id++ {
+		state.Record(Candidate{SongID: id, Artist: "A"})
+	}
+
+	if len(state.Recent) != RepeatWindow {
+		t.Fatalf("Expected %d recent songs, got %d", RepeatWindow, len(state.Recent))
+	}
+	if state.Recent[0] != 6 || state.Current() != RepeatWindow+5 {
+		t.Errorf("Expected songs 6 to %d, got %v", RepeatWindow+5, state.Recent)
+	}
+	if !state.Allows(Candidate{SongID: 5, Artist: "B"}) {
+		t.Error("Expected songs out of the window to be allowed again")
+	}
+}
+
+func TestOldest(t *testing.T) {
+	state := &State{Recent: []uint{3, 1, 2}}
+
+	c, ok := Oldest([]Candidate{{SongID: 2}, {SongID: 1}}, state)
+	if !ok || c.SongID != 1 {
+		t.Errorf("Expected song 1, played longest ago, got %d", c.SongID)
+	}
+
+	c, ok = Oldest([]Candidate{{SongID: 2}, {SongID: 7}}, state)
+	if !ok || c.SongID != 7 {
+		t.Errorf("Expected song 7, never played, got %d", c.SongID)
+	}
+
+	if _, ok := Oldest(nil, state); ok {
+		t.Error("Expected no candidate")
+	}
+}
+
+func TestPickIsDeterministic(t *testing.T) {
+	candidates := []Candidate{
+		{SongID: 1, Artist: "A", Weight: 1},
+		{SongID: 2, Artist: "B", Weight: 2},
+		{SongID: 3, Artist: "C", Weight: 3},
+	}
+	run := func() []uint {
+		rng := rand.New(rand.NewPCG(7, 7))
+		var picked []uint
+		for i := 0; i < 20; i++ {
+			c, _ := Pick(candidates, &State{}, rng)
+			picked = append(picked, c.SongID)
+		}
+		return picked
+	}
+
+	if first, second := run(), run(); !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the same picks from the same seed, got %v and %v", first, second)
+	}
+}
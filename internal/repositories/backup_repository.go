@@ -0,0 +1,418 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"melodia/internal/database"
+	"melodia/internal/models"
+	"melodia/internal/normalize"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// BackupRepository dumps and restores the whole catalog
+type BackupRepository struct {
+	db *sql.DB
+}
+
+// NewBackupRepository creates a new backup repository
+func NewBackupRepository() *BackupRepository {
+	return &BackupRepository{
+		db: database.DB,
+	}
+}
+
+// Export reads every song, lyrics, playlist, playlist entry and like inside a
+// single read-only transaction so the dump is consistent
+func (r *BackupRepository) Export() (*models.Backup, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY`); err != nil {
+		return nil, fmt.Errorf("error setting transaction isolation: %v", err)
+	}
+
+	backup := &models.Backup{
+		Songs:         []models.BackupSong{},
+		Lyrics:        []models.BackupLyrics{},
+		Playlists:     []models.BackupPlaylist{},
+		PlaylistSongs: []models.BackupPlaylistSong{},
+		SongLikes:     []models.BackupSongLike{},
+		PlaylistLikes: []models.BackupPlaylistLike{},
+	}
+
+	songRows, err := tx.Query(`
+		SELECT id, title, artist, COALESCE(album, ''), COALESCE(track_number, 0), COALESCE(year, 0),
+			COALESCE(duration_ms, 0), COALESCE(genre, ''), COALESCE(audio_key, ''), COALESCE(audio_hash, ''),
+			COALESCE(audio_content_type, ''), COALESCE(audio_size, 0), COALESCE(cover_key, ''),
+			COALESCE(cover_content_type, ''), COALESCE(source_path, ''), COALESCE(source_hash, ''),
+			COALESCE(source_size, 0), source_mod_time, source_missing_at, created_at, updated_at
+		FROM songs
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying songs: %v", err)
+	}
+	defer songRows.Close()
+	for songRows.Next() {
+		var s models.BackupSong
+		err := songRows.Scan(&s.ID, &s.Title, &s.Artist, &s.Album, &s.TrackNumber, &s.Year,
+			&s.DurationMs, &s.Genre, &s.AudioKey, &s.AudioHash, &s.AudioContentType, &s.AudioSize, &s.CoverKey,
+			&s.CoverContentType, &s.SourcePath, &s.SourceHash, &s.SourceSize, &s.SourceModTime, &s.SourceMissingAt,
+			&s.CreatedAt, &s.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning song: %v", err)
+		}
+		backup.Songs = append(backup.Songs, s)
+	}
+	if err := songRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating songs: %v", err)
+	}
+
+	lyricsRows, err := tx.Query(`
+		SELECT song_id, format, raw, plain_text, created_at, updated_at
+		FROM song_lyrics
+		ORDER BY song_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying lyrics: %v", err)
+	}
+	defer lyricsRows.Close()
+	for lyricsRows.Next() {
+		var l models.BackupLyrics
+		if err := lyricsRows.Scan(&l.SongID, &l.Format, &l.Raw, &l.PlainText, &l.CreatedAt, &l.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning lyrics: %v", err)
+		}
+		backup.Lyrics = append(backup.Lyrics, l)
+	}
+	if err := lyricsRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating lyrics: %v", err)
+	}
+
+	playlistRows, err := tx.Query(`
+		SELECT id, name, COALESCE(description, ''), COALESCE(creator, ''), is_published, published_at,
+			COALESCE(cover_key, ''), COALESCE(cover_content_type, ''), rules, forked_from, COALESCE(source_path, ''),
+			created_at, updated_at
+		FROM playlists
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying playlists: %v", err)
+	}
+	defer playlistRows.Close()
+	for playlistRows.Next() {
+		var p models.BackupPlaylist
+		var rules []byte
+		var forkedFrom sql.NullInt64
+		err := playlistRows.Scan(&p.ID, &p.Name, &p.Description, &p.Creator, &p.IsPublished, &p.PublishedAt,
+			&p.CoverKey, &p.CoverContentType, &rules, &forkedFrom, &p.SourcePath, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning playlist: %v", err)
+		}
+		p.Rules = rules
+		if forkedFrom.Valid {
+			parent := uint(forkedFrom.Int64)
+			p.ForkedFrom = &parent
+		}
+		backup.Playlists = append(backup.Playlists, p)
+	}
+	if err := playlistRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating playlists: %v", err)
+	}
+
+	entryRows, err := tx.Query(`
+		SELECT id, playlist_id, song_id, added_at
+		FROM playlist_songs
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying playlist songs: %v", err)
+	}
+	defer entryRows.Close()
+	for entryRows.Next() {
+		var ps models.BackupPlaylistSong
+		if err := entryRows.Scan(&ps.ID, &ps.PlaylistID, &ps.SongID, &ps.AddedAt); err != nil {
+			return nil, fmt.Errorf("error scanning playlist song: %v", err)
+		}
+		backup.PlaylistSongs = append(backup.PlaylistSongs, ps)
+	}
+	if err := entryRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating playlist songs: %v", err)
+	}
+
+	songLikeRows, err := tx.Query(`
+		SELECT listener_id, song_id, created_at
+		FROM song_likes
+		ORDER BY song_id, listener_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying song likes: %v", err)
+	}
+	defer songLikeRows.Close()
+	for songLikeRows.Next() {
+		var l models.BackupSongLike
+		if err := songLikeRows.Scan(&l.ListenerID, &l.SongID, &l.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning song like: %v", err)
+		}
+		backup.SongLikes = append(backup.SongLikes, l)
+	}
+	if err := songLikeRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating song likes: %v", err)
+	}
+
+	playlistLikeRows, err := tx.Query(`
+		SELECT listener_id, playlist_id, created_at
+		FROM playlist_likes
+		ORDER BY playlist_id, listener_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying playlist likes: %v", err)
+	}
+	defer playlistLikeRows.Close()
+	for playlistLikeRows.Next() {
+		var l models.BackupPlaylistLike
+		if err := playlistLikeRows.Scan(&l.ListenerID, &l.PlaylistID, &l.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning playlist like: %v", err)
+		}
+		backup.PlaylistLikes = append(backup.PlaylistLikes, l)
+	}
+	if err := playlistLikeRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating playlist likes: %v", err)
+	}
+
+	return backup, nil
+}
+
+// Restore writes a backup into the database in a single transaction, keeping the
+// original IDs. Rows that already exist are skipped, overwritten or abort the
+// restore depending on strategy. Entries and likes whose song or playlist is
+// missing are skipped. Sequences are moved past the highest ID afterwards.
+func (r *BackupRepository) Restore(backup *models.Backup, strategy string) (*models.RestoreResult, error) {
+	if strategy != models.ConflictSkip && strategy != models.ConflictOverwrite && strategy != models.ConflictFail {
+		return nil, fmt.Errorf("unknown conflict strategy %q", strategy)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result := &models.RestoreResult{}
+
+	songStmt, err := tx.Prepare(`
+		INSERT INTO songs (id, title, artist, normalized_key, album, track_number, year, duration_ms,
+			genre, audio_key, audio_hash, audio_content_type, audio_size, cover_key, cover_content_type,
+			source_path, source_hash, source_size, source_mod_time, source_missing_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
+		` + conflictClause(strategy, "id", "title", "artist", "normalized_key", "album", "track_number",
+		"year", "duration_ms", "genre", "audio_key", "audio_hash", "audio_content_type", "audio_size", "cover_key",
+		"cover_content_type", "source_path", "source_hash", "source_size", "source_mod_time", "source_missing_at",
+		"created_at", "updated_at") + `
+		RETURNING (xmax = 0)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing song restore: %v", err)
+	}
+	defer songStmt.Close()
+	for _, s := range backup.Songs {
+		err := restoreRow(songStmt, &result.Songs, "song", s.ID,
+			s.ID, s.Title, s.Artist, normalize.SongKey(s.Title, s.Artist), nullIfEmpty(s.Album),
+			nullIfZero(int64(s.TrackNumber)), nullIfZero(int64(s.Year)), nullIfZero(s.DurationMs),
+			nullIfEmpty(s.Genre), nullIfEmpty(s.AudioKey), nullIfEmpty(s.AudioHash), nullIfEmpty(s.AudioContentType),
+			nullIfZero(s.AudioSize), nullIfEmpty(s.CoverKey), nullIfEmpty(s.CoverContentType),
+			nullIfEmpty(s.SourcePath), nullIfEmpty(s.SourceHash), nullIfZero(s.SourceSize), s.SourceModTime,
+			s.SourceMissingAt, s.CreatedAt, s.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lyricsStmt, err := tx.Prepare(`
+		INSERT INTO song_lyrics (song_id, format, raw, plain_text, created_at, updated_at)
+		SELECT $1::int, $2, $3, $4, $5::timestamptz, $6::timestamptz
+		WHERE EXISTS (SELECT 1 FROM songs WHERE id = $1)
+		` + conflictClause(strategy, "song_id", "format", "raw", "plain_text", "created_at", "updated_at") + `
+		RETURNING (xmax = 0)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing lyrics restore: %v", err)
+	}
+	defer lyricsStmt.Close()
+	for _, l := range backup.Lyrics {
+		err := restoreRow(lyricsStmt, &result.Lyrics, "lyrics of song", l.SongID,
+			l.SongID, l.Format, l.Raw, l.PlainText, l.CreatedAt, l.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	playlistStmt, err := tx.Prepare(`
+		INSERT INTO playlists (id, name, description, creator, is_published, published_at, cover_key,
+			cover_content_type, rules, source_path, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		` + conflictClause(strategy, "id", "name", "description", "creator", "is_published", "published_at",
+		"cover_key", "cover_content_type", "rules", "source_path", "created_at", "updated_at") + `
+		RETURNING (xmax = 0)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing playlist restore: %v", err)
+	}
+	defer playlistStmt.Close()
+	playlistIDs := make([]int64, 0, len(backup.Playlists))
+	var written []models.BackupPlaylist
+	for _, p := range backup.Playlists {
+		skipped := result.Playlists.Skipped
+		err := restoreRow(playlistStmt, &result.Playlists, "playlist", p.ID,
+			p.ID, p.Name, p.Description, nullIfEmpty(p.Creator), p.IsPublished, p.PublishedAt,
+			nullIfEmpty(p.CoverKey), nullIfEmpty(p.CoverContentType), nullIfEmpty(string(p.Rules)),
+			nullIfEmpty(p.SourcePath), p.CreatedAt, p.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		playlistIDs = append(playlistIDs, int64(p.ID))
+		if result.Playlists.Skipped == skipped {
+			written = append(written, p)
+		}
+	}
+
+	// Forks are linked once every playlist exists, since a fork may come before
+	// its parent. A parent missing from both the backup and the database is dropped.
+	forkStmt, err := tx.Prepare(`
+		UPDATE playlists SET forked_from = (SELECT id FROM playlists WHERE id = $2)
+		WHERE id = $1
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing playlist fork restore: %v", err)
+	}
+	defer forkStmt.Close()
+	for _, p := range written {
+		var parent interface{}
+		if p.ForkedFrom != nil {
+			parent = int64(*p.ForkedFrom)
+		}
+		if _, err := forkStmt.Exec(p.ID, parent); err != nil {
+			return nil, fmt.Errorf("error restoring fork of playlist %d: %v", p.ID, err)
+		}
+	}
+
+	// An overwritten playlist takes the song list of the backup, not a merge of both
+	if strategy == models.ConflictOverwrite && len(playlistIDs) > 0 {
+		if _, err := tx.Exec(`DELETE FROM playlist_songs WHERE playlist_id = ANY($1)`, pq.Array(playlistIDs)); err != nil {
+			return nil, fmt.Errorf("error clearing playlist songs: %v", err)
+		}
+	}
+
+	entryStmt, err := tx.Prepare(`
+		INSERT INTO playlist_songs (id, playlist_id, song_id, added_at)
+		SELECT $1::int, $2::int, $3::int, $4::timestamptz
+		WHERE EXISTS (SELECT 1 FROM playlists WHERE id = $2)
+			AND EXISTS (SELECT 1 FROM songs WHERE id = $3)
+		` + conflictClause(strategy, "id", "playlist_id", "song_id", "added_at") + `
+		RETURNING (xmax = 0)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing playlist song restore: %v", err)
+	}
+	defer entryStmt.Close()
+	for _, ps := range backup.PlaylistSongs {
+		err := restoreRow(entryStmt, &result.PlaylistSongs, "playlist song", ps.ID,
+			ps.ID, ps.PlaylistID, ps.SongID, ps.AddedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	songLikeStmt, err := tx.Prepare(`
+		INSERT INTO song_likes (listener_id, song_id, created_at)
+		SELECT $1, $2::int, $3::timestamptz
+		WHERE EXISTS (SELECT 1 FROM songs WHERE id = $2)
+		` + conflictClause(strategy, "listener_id, song_id", "created_at") + `
+		RETURNING (xmax = 0)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing song like restore: %v", err)
+	}
+	defer songLikeStmt.Close()
+	for _, l := range backup.SongLikes {
+		err := restoreRow(songLikeStmt, &result.SongLikes, "like of song", l.SongID,
+			l.ListenerID, l.SongID, l.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	playlistLikeStmt, err := tx.Prepare(`
+		INSERT INTO playlist_likes (listener_id, playlist_id, created_at)
+		SELECT $1, $2::int, $3::timestamptz
+		WHERE EXISTS (SELECT 1 FROM playlists WHERE id = $2)
+		` + conflictClause(strategy, "listener_id, playlist_id", "created_at") + `
+		RETURNING (xmax = 0)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing playlist like restore: %v", err)
+	}
+	defer playlistLikeStmt.Close()
+	for _, l := range backup.PlaylistLikes {
+		err := restoreRow(playlistLikeStmt, &result.PlaylistLikes, "like of playlist", l.PlaylistID,
+			l.ListenerID, l.PlaylistID, l.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Keep SERIAL columns from handing out IDs that were just restored
+	for _, table := range []string{"songs", "playlists", "playlist_songs"} {
+		query := fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %s`, table, table)
+		if _, err := tx.Exec(query); err != nil {
+			return nil, fmt.Errorf("error resetting %s sequence: %v", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return result, nil
+}
+
+// conflictClause returns the ON CONFLICT clause implementing a conflict strategy.
+// The fail strategy has none, so the unique violation aborts the restore.
+func conflictClause(strategy, target string, columns ...string) string {
+	switch strategy {
+	case models.ConflictSkip:
+		return "ON CONFLICT DO NOTHING"
+	case models.ConflictOverwrite:
+		assignments := make([]string, len(columns))
+		for i, column := range columns {
+			assignments[i] = column + " = EXCLUDED." + column
+		}
+		return "ON CONFLICT (" + target + ") DO UPDATE SET " + strings.Join(assignments, ", ")
+	}
+	return ""
+}
+
+// restoreRow runs a prepared restore statement returning whether the row was
+// inserted, and counts the outcome. No row back means it was skipped.
+func restoreRow(stmt *sql.Stmt, counts *models.RestoreCounts, what string, id uint, args ...interface{}) error {
+	var inserted bool
+	err := stmt.QueryRow(args...).Scan(&inserted)
+	switch {
+	case err == sql.ErrNoRows:
+		counts.Skipped++
+	case err != nil:
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("%s %d conflicts with an existing row", what, id)
+		}
+		return fmt.Errorf("error restoring %s %d: %v", what, id, err)
+	case inserted:
+		counts.Inserted++
+	default:
+		counts.Updated++
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"melodia/internal/charts"
+	"melodia/internal/database"
+	"melodia/internal/models"
+)
+
+const (
+	// minChartPlayMs is how long a play that was not completed must last to count in charts
+	minChartPlayMs = 30000
+	// rollupRecheckWindow is how far before the last refresh each refresh looks
+	// for new plays, so plays committed well after they were created still
+	// get rolled up
+	rollupRecheckWindow = time.Hour
+	// rollupLockID is the advisory lock keeping refreshes from running concurrently
+	rollupLockID = 7_042_001
+)
+
+// ChartRepository handles database operations for charts and their rollups
+type ChartRepository struct {
+	db *sql.DB
+}
+
+// NewChartRepository creates a new chart repository
+func NewChartRepository() *ChartRepository {
+	return &ChartRepository{
+		db: database.DB,
+	}
+}
+
+// RefreshRollups rebuilds the daily rollups of every day that received plays
+// since the last refresh, including plays recorded late for past days. Plays
+// created shortly before the last refresh are looked at again, since they may
+// have been committed after it. It returns without doing anything when
+// another refresh is running.
+func (r *ChartRepository) RefreshRollups(ctx context.Context) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, rollupLockID).Scan(&locked); err != nil {
+		return fmt.Errorf("error locking rollups: %v", err)
+	}
+	if !locked {
+		return nil
+	}
+
+	var since *time.Time
+	err = tx.QueryRowContext(ctx, `SELECT refreshed_until FROM play_rollup_state`).Scan(&since)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error querying rollup state: %v", err)
+	}
+	until := time.Now()
+	if since != nil {
+		recheckFrom := since.Add(-rollupRecheckWindow)
+		since = &recheckFrom
+	}
+
+	daysQuery := `
+		SELECT DISTINCT (started_at AT TIME ZONE 'UTC')::date
+		FROM plays
+		WHERE $1::timestamptz IS NULL OR created_at > $1
+	`
+	rows, err := tx.QueryContext(ctx, daysQuery, since)
+	if err != nil {
+		return fmt.Errorf("error querying changed days: %v", err)
+	}
+	var days []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning day: %v", err)
+		}
+		days = append(days, day)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating changed days: %v", err)
+	}
+
+	rollupQuery := `
+		WITH counted AS (
+			SELECT song_id, song_title, song_artist, playlist_id, playlist_name
+			FROM plays
+			WHERE started_at >= $2 AND started_at < $3 AND (completed OR ms_played >= $4)
+		)
+		INSERT INTO play_rollups (kind, day, item_id, title, subtitle, play_count)
+		SELECT 'songs', $1::date, song_id::text, MAX(song_title), MAX(song_artist), COUNT(*)
+		FROM counted
+		GROUP BY song_id
+		UNION ALL
+		SELECT 'artists', $1::date, lower(song_artist), MAX(song_artist), '', COUNT(*)
+		FROM counted
+		GROUP BY lower(song_artist)
+		UNION ALL
+		SELECT 'playlists', $1::date, playlist_id::text, COALESCE(MAX(playlist_name), ''), '', COUNT(*)
+		FROM counted
+		WHERE playlist_id IS NOT NULL
+		GROUP BY playlist_id
+	`
+	for _, day := range days {
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+		if _, err := tx.ExecContext(ctx, `DELETE FROM play_rollups WHERE day = $1::date`, dateOnly(start)); err != nil {
+			return fmt.Errorf("error clearing rollups: %v", err)
+		}
+		if _, err := tx.ExecContext(ctx, rollupQuery, dateOnly(start), start, start.AddDate(0, 0, 1), minChartPlayMs); err != nil {
+			return fmt.Errorf("error rolling up plays: %v", err)
+		}
+	}
+
+	stateQuery := `
+		INSERT INTO play_rollup_state (id, refreshed_until) VALUES (true, $1)
+		ON CONFLICT (id) DO UPDATE SET refreshed_until = EXCLUDED.refreshed_until
+	`
+	if _, err := tx.ExecContext(ctx, stateQuery, until); err != nil {
+		return fmt.Errorf("error saving rollup state: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+// GetChart ranks the items of a kind by play count over a window, breaking ties
+// by title and ID so a period always gives the same chart, and compares ranks
+// with the previous window
+func (r *ChartRepository) GetChart(kind string, window charts.Window, limit int) ([]models.ChartEntry, error) {
+	query := `
+		WITH current_period AS (
+			SELECT item_id, MAX(title) AS title, MAX(subtitle) AS subtitle, SUM(play_count) AS plays
+			FROM play_rollups
+			WHERE kind = $1 AND day >= $2::date AND day < $3::date
+			GROUP BY item_id
+		),
+		previous_period AS (
+			SELECT item_id, MAX(title) AS title, SUM(play_count) AS plays
+			FROM play_rollups
+			WHERE kind = $1 AND day >= $4::date AND day < $2::date
+			GROUP BY item_id
+		),
+		current_ranks AS (
+			SELECT *, ROW_NUMBER() OVER (ORDER BY plays DESC, title, item_id) AS rank
+			FROM current_period
+		),
+		previous_ranks AS (
+			SELECT item_id, ROW_NUMBER() OVER (ORDER BY plays DESC, title, item_id) AS rank
+			FROM previous_period
+		)
+		SELECT c.rank, c.item_id, c.title, c.subtitle, c.plays, p.rank
+		FROM current_ranks c
+		LEFT JOIN previous_ranks p ON p.item_id = c.item_id
+		ORDER BY c.rank
+		LIMIT $5
+	`
+
+	rows, err := r.db.Query(query, kind, dateOnly(window.Start), dateOnly(window.End), dateOnly(window.PreviousStart), limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying chart: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []models.ChartEntry{}
+	for rows.Next() {
+		var entry models.ChartEntry
+		var itemID, title, subtitle string
+		err := rows.Scan(&entry.Rank, &itemID, &title, &subtitle, &entry.PlayCount, &entry.PreviousRank)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning chart entry: %v", err)
+		}
+		entry.Movement, entry.RankChange = charts.Movement(entry.Rank, entry.PreviousRank)
+
+		switch kind {
+		case models.ChartSongs:
+			id, _ := strconv.ParseUint(itemID, 10, 32)
+			songID := uint(id)
+			entry.SongID = &songID
+			entry.Title = title
+			entry.Artist = subtitle
+		case models.ChartArtists:
+			entry.Artist = title
+		case models.ChartPlaylists:
+			id, _ := strconv.ParseUint(itemID, 10, 32)
+			playlistID := uint(id)
+			entry.PlaylistID = &playlistID
+			entry.Name = title
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating chart: %v", err)
+	}
+
+	return entries, nil
+}
+
+// dateOnly formats the date of t for DATE parameters, which would otherwise be
+// converted using the time zone of the database session
+func dateOnly(t time.Time) string {
+	return t.Format("2006-01-02")
+}
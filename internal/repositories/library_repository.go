@@ -0,0 +1,364 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"melodia/internal/database"
+	"melodia/internal/models"
+	"melodia/internal/normalize"
+
+	"github.com/lib/pq"
+)
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// SongSource is the file a song was scanned from
+type SongSource struct {
+	SongID  uint
+	Path    string
+	Hash    string
+	Size    int64
+	ModTime time.Time
+	Missing bool
+}
+
+// LibraryRepository handles the database operations of the library scanner
+type LibraryRepository struct {
+	db *sql.DB
+}
+
+// NewLibraryRepository creates a new library repository
+func NewLibraryRepository() *LibraryRepository {
+	return &LibraryRepository{
+		db: database.DB,
+	}
+}
+
+// GetSongSources retrieves the sources of every song scanned from below root,
+// keyed by path. Root must end with a path separator.
+func (r *LibraryRepository) GetSongSources(root string) (map[string]SongSource, error) {
+	query := `
+		SELECT id, source_path, COALESCE(source_hash, ''), COALESCE(source_size, 0),
+			COALESCE(source_mod_time, 'epoch'), source_missing_at IS NOT NULL
+		FROM songs
+		WHERE starts_with(source_path, $1)
+	`
+
+	rows, err := r.db.Query(query, root)
+	if err != nil {
+		return nil, fmt.Errorf("error querying song sources: %v", err)
+	}
+	defer rows.Close()
+
+	sources := make(map[string]SongSource)
+	for rows.Next() {
+		var source SongSource
+		if err := rows.Scan(&source.SongID, &source.Path, &source.Hash, &source.Size, &source.ModTime, &source.Missing); err != nil {
+			return nil, fmt.Errorf("error scanning song source: %v", err)
+		}
+		sources[source.Path] = source
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating song sources: %v", err)
+	}
+
+	return sources, nil
+}
+
+// FindSongSourceByHash retrieves the source of a song scanned from a file with the
+// given content hash, preferring songs whose file went missing
+func (r *LibraryRepository) FindSongSourceByHash(hash string) (*SongSource, error) {
+	query := `
+		SELECT id, source_path, source_hash, COALESCE(source_size, 0),
+			COALESCE(source_mod_time, 'epoch'), source_missing_at IS NOT NULL
+		FROM songs
+		WHERE source_hash = $1
+		ORDER BY source_missing_at IS NULL, id
+		LIMIT 1
+	`
+
+	var source SongSource
+	err := r.db.QueryRow(query, hash).Scan(&source.SongID, &source.Path, &source.Hash, &source.Size, &source.ModTime, &source.Missing)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("song not found")
+		}
+		return nil, fmt.Errorf("error querying song source: %v", err)
+	}
+
+	return &source, nil
+}
+
+// FindSongByKey retrieves the ID of the oldest song matching a normalized title/artist
+// key and whether it was already scanned from a file
+func (r *LibraryRepository) FindSongByKey(key string) (uint, bool, error) {
+	query := `
+		SELECT id, source_path IS NOT NULL
+		FROM songs
+		WHERE normalized_key = $1
+		ORDER BY id
+		LIMIT 1
+	`
+
+	var id uint
+	var scanned bool
+	if err := r.db.QueryRow(query, key).Scan(&id, &scanned); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, fmt.Errorf("song not found")
+		}
+		return 0, false, fmt.Errorf("error querying song: %v", err)
+	}
+
+	return id, scanned, nil
+}
+
+// CreateScannedSong creates a song together with the file it was scanned from
+func (r *LibraryRepository) CreateScannedSong(song *models.Song, source SongSource) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertSong(tx, song); err != nil {
+		return err
+	}
+	source.SongID = song.ID
+	if err := setSongSource(tx, source); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+// UpdateScannedSong replaces the metadata of a song whose file changed
+func (r *LibraryRepository) UpdateScannedSong(song *models.Song, source SongSource) error {
+	query := `
+		UPDATE songs
+		SET title = $1, artist = $2, normalized_key = $3, album = $4, track_number = $5,
+			year = $6, duration_ms = $7, genre = $8, updated_at = $9,
+			source_path = $10, source_hash = $11, source_size = $12, source_mod_time = $13,
+			source_missing_at = NULL
+		WHERE id = $14
+	`
+
+	_, err := r.db.Exec(query,
+		song.Title,
+		song.Artist,
+		normalize.SongKey(song.Title, song.Artist),
+		nullIfEmpty(song.Album),
+		nullIfZero(int64(song.TrackNumber)),
+		nullIfZero(int64(song.Year)),
+		nullIfZero(song.DurationMs),
+		nullIfEmpty(song.Genre),
+		time.Now(),
+		source.Path,
+		source.Hash,
+		source.Size,
+		source.ModTime,
+		source.SongID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating scanned song: %v", err)
+	}
+
+	return nil
+}
+
+// LinkSongSource attaches a file to a song created by other means, filling in the
+// metadata the song is missing without touching its title and artist
+func (r *LibraryRepository) LinkSongSource(song *models.Song, source SongSource) error {
+	query := `
+		UPDATE songs
+		SET album = COALESCE(album, $1), track_number = COALESCE(track_number, $2),
+			year = COALESCE(year, $3), duration_ms = COALESCE(duration_ms, $4),
+			genre = COALESCE(genre, $5), updated_at = $6,
+			source_path = $7, source_hash = $8, source_size = $9, source_mod_time = $10,
+			source_missing_at = NULL
+		WHERE id = $11
+	`
+
+	_, err := r.db.Exec(query,
+		nullIfEmpty(song.Album),
+		nullIfZero(int64(song.TrackNumber)),
+		nullIfZero(int64(song.Year)),
+		nullIfZero(song.DurationMs),
+		nullIfEmpty(song.Genre),
+		time.Now(),
+		source.Path,
+		source.Hash,
+		source.Size,
+		source.ModTime,
+		source.SongID,
+	)
+	if err != nil {
+		return fmt.Errorf("error linking song source: %v", err)
+	}
+
+	return nil
+}
+
+// TouchSongSource records the current path, size and modification time of a file
+// whose content did not change, clearing its missing mark
+func (r *LibraryRepository) TouchSongSource(source SongSource) error {
+	return setSongSource(r.db, source)
+}
+
+// MarkMissingSongs flags the songs scanned from below root whose path is not in
+// seen, returning how many songs were newly flagged
+func (r *LibraryRepository) MarkMissingSongs(root string, seen []string) (int64, error) {
+	query := `
+		UPDATE songs
+		SET source_missing_at = $1
+		WHERE starts_with(source_path, $2)
+			AND source_missing_at IS NULL
+			AND NOT (source_path = ANY($3))
+	`
+
+	result, err := r.db.Exec(query, time.Now(), root, pq.Array(seen))
+	if err != nil {
+		return 0, fmt.Errorf("error marking missing songs: %v", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// GetSongIDsBySourcePaths maps the given file paths to the songs scanned from them
+func (r *LibraryRepository) GetSongIDsBySourcePaths(paths []string) (map[string]uint, error) {
+	rows, err := r.db.Query(`SELECT source_path, id FROM songs WHERE source_path = ANY($1)`, pq.Array(paths))
+	if err != nil {
+		return nil, fmt.Errorf("error querying songs by source path: %v", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]uint)
+	for rows.Next() {
+		var path string
+		var id uint
+		if err := rows.Scan(&path, &id); err != nil {
+			return nil, fmt.Errorf("error scanning song source path: %v", err)
+		}
+		ids[path] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating songs by source path: %v", err)
+	}
+
+	return ids, nil
+}
+
+// SyncPlaylistSource creates or updates the playlist scanned from a playlist file so
+// it holds exactly the given songs in order. It reports whether the playlist was
+// created and whether anything changed.
+func (r *LibraryRepository) SyncPlaylistSource(sourcePath string, playlist *models.Playlist, songIDs []uint) (bool, bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, false, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Playlists scanned before keep their current name
+	created := false
+	err = tx.QueryRow(`SELECT id, name FROM playlists WHERE source_path = $1 FOR UPDATE`, sourcePath).Scan(&playlist.ID, &playlist.Name)
+	switch {
+	case err == sql.ErrNoRows:
+		if err := insertPlaylist(tx, playlist); err != nil {
+			return false, false, fmt.Errorf("error creating playlist: %v", err)
+		}
+		if _, err := tx.Exec(`UPDATE playlists SET source_path = $1 WHERE id = $2`, sourcePath, playlist.ID); err != nil {
+			return false, false, fmt.Errorf("error setting playlist source: %v", err)
+		}
+		created = true
+	case err != nil:
+		return false, false, fmt.Errorf("error querying playlist: %v", err)
+	}
+
+	ids := make([]int64, 0, len(songIDs))
+	seen := make(map[uint]bool, len(songIDs))
+	for _, id := range songIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, int64(id))
+		}
+	}
+
+	if !created {
+		var current []int64
+		query := `
+			SELECT COALESCE(array_agg(song_id ORDER BY added_at DESC, id ASC), '{}')
+			FROM playlist_songs
+			WHERE playlist_id = $1
+		`
+		if err := tx.QueryRow(query, playlist.ID).Scan(pq.Array(&current)); err != nil {
+			return false, false, fmt.Errorf("error querying playlist songs: %v", err)
+		}
+		if equalIDs(current, ids) {
+			return false, false, nil
+		}
+
+		if _, err := tx.Exec(`DELETE FROM playlist_songs WHERE playlist_id = $1`, playlist.ID); err != nil {
+			return false, false, fmt.Errorf("error clearing playlist songs: %v", err)
+		}
+		if _, err := tx.Exec(`UPDATE playlists SET updated_at = $1 WHERE id = $2`, time.Now(), playlist.ID); err != nil {
+			return false, false, fmt.Errorf("error updating playlist: %v", err)
+		}
+	}
+
+	// Entries share one timestamp so the playlist keeps the file order by entry ID
+	query := `
+		INSERT INTO playlist_songs (playlist_id, song_id, added_at)
+		SELECT $1, t.song_id, $3
+		FROM unnest($2::int[]) WITH ORDINALITY AS t(song_id, position)
+		ORDER BY t.position
+	`
+	if _, err := tx.Exec(query, playlist.ID, pq.Array(ids), time.Now()); err != nil {
+		return false, false, fmt.Errorf("error adding songs to playlist: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, false, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return created, true, nil
+}
+
+// setSongSource stores the file a song was scanned from and clears its missing mark
+func setSongSource(e execer, source SongSource) error {
+	query := `
+		UPDATE songs
+		SET source_path = $1, source_hash = $2, source_size = $3, source_mod_time = $4,
+			source_missing_at = NULL
+		WHERE id = $5
+	`
+
+	if _, err := e.Exec(query, source.Path, source.Hash, source.Size, source.ModTime, source.SongID); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("song source already exists")
+		}
+		return fmt.Errorf("error setting song source: %v", err)
+	}
+
+	return nil
+}
+
+// equalIDs reports whether two ID lists hold the same IDs in the same order
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"melodia/internal/database"
+	"melodia/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// LikeRepository handles database operations for the songs and playlists
+// listeners like
+type LikeRepository struct {
+	db *sql.DB
+}
+
+// NewLikeRepository creates a new like repository
+func NewLikeRepository() *LikeRepository {
+	return &LikeRepository{
+		db: database.DB,
+	}
+}
+
+// LikeSong records that a listener likes a song. Liking it again keeps the
+// original like time.
+func (r *LikeRepository) LikeSong(listenerID string, songID uint) error {
+	query := `
+		INSERT INTO song_likes (listener_id, song_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (listener_id, song_id) DO NOTHING
+	`
+	return r.like(query, listenerID, songID, "song not found")
+}
+
+// UnlikeSong removes the like of a listener from a song, if any
+func (r *LikeRepository) UnlikeSong(listenerID string, songID uint) error {
+	if _, err := r.db.Exec(`DELETE FROM song_likes WHERE listener_id = $1 AND song_id = $2`, listenerID, songID); err != nil {
+		return fmt.Errorf("error deleting song like: %v", err)
+	}
+	return nil
+}
+
+// LikePlaylist records that a listener likes a playlist. Liking it again keeps
+// the original like time.
+func (r *LikeRepository) LikePlaylist(listenerID string, playlistID uint) error {
+	query := `
+		INSERT INTO playlist_likes (listener_id, playlist_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (listener_id, playlist_id) DO NOTHING
+	`
+	return r.like(query, listenerID, playlistID, "playlist not found")
+}
+
+// UnlikePlaylist removes the like of a listener from a playlist, if any
+func (r *LikeRepository) UnlikePlaylist(listenerID string, playlistID uint) error {
+	if _, err := r.db.Exec(`DELETE FROM playlist_likes WHERE listener_id = $1 AND playlist_id = $2`, listenerID, playlistID); err != nil {
+		return fmt.Errorf("error deleting playlist like: %v", err)
+	}
+	return nil
+}
+
+// like runs a like insert, reporting notFound when the liked row does not exist
+func (r *LikeRepository) like(query, listenerID string, id uint, notFound string) error {
+	if _, err := r.db.Exec(query, listenerID, id, time.Now()); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return fmt.Errorf("%s", notFound)
+		}
+		return fmt.Errorf("error creating like: %v", err)
+	}
+	return nil
+}
+
+// GetLikedSongs retrieves up to limit songs liked by a listener, most recently
+// liked first, with added_at set to the like time. When before is set only
+// likes ordered after the like (before, beforeID) are returned.
+func (r *LikeRepository) GetLikedSongs(listenerID string, before *time.Time, beforeID int64, limit int) ([]models.PlaylistSong, error) {
+	query := `
+		SELECT s.id, s.title, s.artist, COALESCE(s.album, ''), COALESCE(s.duration_ms, 0), s.audio_key IS NOT NULL, l.created_at
+		FROM song_likes l
+		JOIN songs s ON s.id = l.song_id
+		WHERE l.listener_id = $1 AND ($2::timestamptz IS NULL OR (l.created_at, l.song_id) < ($2, $3))
+		ORDER BY l.created_at DESC, l.song_id DESC
+		LIMIT $4
+	`
+
+	rows, err := r.db.Query(query, listenerID, before, beforeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying liked songs: %v", err)
+	}
+	defer rows.Close()
+
+	songs := []models.PlaylistSong{}
+	for rows.Next() {
+		var song models.PlaylistSong
+		err := rows.Scan(&song.ID, &song.Title, &song.Artist, &song.Album, &song.DurationMs, &song.HasAudio, &song.AddedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning liked song: %v", err)
+		}
+		songs = append(songs, song)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating liked songs: %v", err)
+	}
+
+	return songs, nil
+}
+
+// LikedSongsSummary returns how many songs a listener likes and when the first
+// and latest of them were liked, nil when there are none
+func (r *LikeRepository) LikedSongsSummary(listenerID string) (int, *time.Time, *time.Time, error) {
+	var count int
+	var first, last *time.Time
+	query := `SELECT COUNT(*), MIN(created_at), MAX(created_at) FROM song_likes WHERE listener_id = $1`
+	if err := r.db.QueryRow(query, listenerID).Scan(&count, &first, &last); err != nil {
+		return 0, nil, nil, fmt.Errorf("error querying liked songs: %v", err)
+	}
+	return count, first, last, nil
+}
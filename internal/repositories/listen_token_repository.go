@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"melodia/internal/database"
+	"melodia/internal/models"
+)
+
+// ListenTokenRepository handles database operations for ListenBrainz tokens
+type ListenTokenRepository struct {
+	db *sql.DB
+}
+
+// NewListenTokenRepository creates a new listen token repository
+func NewListenTokenRepository() *ListenTokenRepository {
+	return &ListenTokenRepository{
+		db: database.DB,
+	}
+}
+
+// CreateToken stores a new token of a listener under the digest of its value
+func (r *ListenTokenRepository) CreateToken(token *models.ListenToken, hash string) error {
+	query := `
+		INSERT INTO listen_tokens (listener_id, token_hash, create_missing, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRow(query, token.ListenerID, hash, token.CreateMissing, time.Now()).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating listen token: %v", err)
+	}
+
+	return nil
+}
+
+// UseToken retrieves the token stored under a digest, recording that it was used
+func (r *ListenTokenRepository) UseToken(hash string) (*models.ListenToken, error) {
+	query := `
+		UPDATE listen_tokens
+		SET last_used_at = $2
+		WHERE token_hash = $1
+		RETURNING id, listener_id, create_missing, last_used_at, created_at
+	`
+
+	var token models.ListenToken
+	err := r.db.QueryRow(query, hash, time.Now()).
+		Scan(&token.ID, &token.ListenerID, &token.CreateMissing, &token.LastUsedAt, &token.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("token not found")
+		}
+		return nil, fmt.Errorf("error querying listen token: %v", err)
+	}
+
+	return &token, nil
+}
+
+// DeleteToken revokes a token of a listener
+func (r *ListenTokenRepository) DeleteToken(listenerID string, id uint) error {
+	result, err := r.db.Exec(`DELETE FROM listen_tokens WHERE id = $1 AND listener_id = $2`, id, listenerID)
+	if err != nil {
+		return fmt.Errorf("error deleting listen token: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("token not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"melodia/internal/database"
+	"melodia/internal/models"
+	"time"
+)
+
+// LyricsRepository handles database operations for song lyrics
+type LyricsRepository struct {
+	db *sql.DB
+}
+
+// NewLyricsRepository creates a new lyrics repository
+func NewLyricsRepository() *LyricsRepository {
+	return &LyricsRepository{
+		db: database.DB,
+	}
+}
+
+// SaveLyrics creates or replaces the lyrics of a song
+func (r *LyricsRepository) SaveLyrics(lyrics *models.Lyrics) error {
+	// First check if the song exists
+	songQuery := `SELECT id FROM songs WHERE id = $1`
+	var songExists uint
+	err := r.db.QueryRow(songQuery, lyrics.SongID).Scan(&songExists)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("song not found")
+		}
+		return fmt.Errorf("error checking song: %v", err)
+	}
+
+	query := `
+		INSERT INTO song_lyrics (song_id, format, raw, plain_text, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (song_id) DO UPDATE
+		SET format = EXCLUDED.format, raw = EXCLUDED.raw, plain_text = EXCLUDED.plain_text, updated_at = EXCLUDED.updated_at
+		RETURNING created_at, updated_at
+	`
+
+	now := time.Now()
+	err = r.db.QueryRow(query, lyrics.SongID, lyrics.Format, lyrics.Raw, lyrics.PlainText, now).
+		Scan(&lyrics.CreatedAt, &lyrics.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("error saving lyrics: %v", err)
+	}
+
+	return nil
+}
+
+// GetLyricsBySongID retrieves the lyrics of a song
+func (r *LyricsRepository) GetLyricsBySongID(songID uint) (*models.Lyrics, error) {
+	query := `
+		SELECT song_id, format, raw, plain_text, created_at, updated_at
+		FROM song_lyrics
+		WHERE song_id = $1
+	`
+
+	var lyrics models.Lyrics
+	err := r.db.QueryRow(query, songID).Scan(
+		&lyrics.SongID,
+		&lyrics.Format,
+		&lyrics.Raw,
+		&lyrics.PlainText,
+		&lyrics.CreatedAt,
+		&lyrics.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("lyrics not found")
+		}
+		return nil, fmt.Errorf("error querying lyrics: %v", err)
+	}
+
+	return &lyrics, nil
+}
+
+// DeleteLyrics removes the lyrics of a song
+func (r *LyricsRepository) DeleteLyrics(songID uint) error {
+	query := `DELETE FROM song_lyrics WHERE song_id = $1`
+
+	result, err := r.db.Exec(query, songID)
+	if err != nil {
+		return fmt.Errorf("error deleting lyrics: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("lyrics not found")
+	}
+
+	return nil
+}
+
+// SearchLyrics performs a full text search over lyrics, best matches first
+func (r *LyricsRepository) SearchLyrics(text string, limit int) ([]models.LyricsSearchResult, error) {
+	query := `
+		SELECT ` + songColumns + `,
+			ts_headline('simple', l.plain_text, q, 'MaxFragments=1, MaxWords=15, MinWords=5'),
+			ts_rank(to_tsvector('simple', l.plain_text), q) AS rank
+		FROM song_lyrics l
+		JOIN songs s ON s.id = l.song_id,
+			plainto_tsquery('simple', $1) q
+		WHERE to_tsvector('simple', l.plain_text) @@ q
+		ORDER BY rank DESC, s.id
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(query, text, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error searching lyrics: %v", err)
+	}
+	defer rows.Close()
+
+	results := []models.LyricsSearchResult{}
+	for rows.Next() {
+		var result models.LyricsSearchResult
+		if err := scanSong(rows, &result.Song, &result.Snippet, &result.Rank); err != nil {
+			return nil, fmt.Errorf("error scanning lyrics search result: %v", err)
+		}
+		results = append(results, result)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating lyrics search results: %v", err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,204 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"melodia/internal/database"
+	"melodia/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// listenLockClass namespaces the advisory locks serializing the listens of each listener
+const listenLockClass = 7_049_001
+
+// playPartitions remembers the months whose plays partition is known to exist
+var playPartitions sync.Map
+
+// PlayRepository handles database operations for play events
+type PlayRepository struct {
+	db *sql.DB
+}
+
+// NewPlayRepository creates a new play repository
+func NewPlayRepository() *PlayRepository {
+	return &PlayRepository{
+		db: database.DB,
+	}
+}
+
+// CreatePlay records a play event, copying the title and artist of its song
+func (r *PlayRepository) CreatePlay(play *models.Play) error {
+	if play.PlaylistID != nil {
+		var exists bool
+		err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM playlists WHERE id = $1)`, *play.PlaylistID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("error querying playlist: %v", err)
+		}
+		if !exists {
+			return fmt.Errorf("playlist not found")
+		}
+	}
+
+	if err := r.ensurePlayPartition(play.StartedAt); err != nil {
+		return fmt.Errorf("error creating plays partition: %v", err)
+	}
+
+	query := `
+		INSERT INTO plays (listener_id, song_id, song_title, song_artist, playlist_id, playlist_name, started_at, ms_played, completed, skipped, created_at)
+		SELECT $1, s.id, s.title, s.artist, $3, (SELECT p.name FROM playlists p WHERE p.id = $3), $4, $5, $6, $7, $8
+		FROM songs s
+		WHERE s.id = $2
+		RETURNING id, song_title, song_artist, created_at
+	`
+
+	err := r.db.QueryRow(query,
+		play.ListenerID,
+		play.SongID,
+		play.PlaylistID,
+		play.StartedAt,
+		play.MsPlayed,
+		play.Completed,
+		play.Skipped,
+		time.Now(),
+	).Scan(&play.ID, &play.SongTitle, &play.SongArtist, &play.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("song not found")
+		}
+		return fmt.Errorf("error creating play: %v", err)
+	}
+
+	return nil
+}
+
+// RecordListen records a play reported by a scrobbling client unless the
+// listener already has a play of the same song starting at the same time, as
+// clients resubmit listens they are unsure were received. Listens of a listener
+// are recorded one at a time so concurrent resubmissions can't both get in. It
+// reports whether the play was recorded.
+func (r *PlayRepository) RecordListen(play *models.Play) (bool, error) {
+	if err := r.ensurePlayPartition(play.StartedAt); err != nil {
+		return false, fmt.Errorf("error creating plays partition: %v", err)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, hashtext($2))`, listenLockClass, play.ListenerID); err != nil {
+		return false, fmt.Errorf("error locking listener plays: %v", err)
+	}
+
+	query := `
+		INSERT INTO plays (listener_id, song_id, song_title, song_artist, started_at, ms_played, completed, skipped, created_at)
+		SELECT $1, s.id, s.title, s.artist, $3, $4, $5, $6, $7
+		FROM songs s
+		WHERE s.id = $2 AND NOT EXISTS (
+			SELECT 1 FROM plays p WHERE p.listener_id = $1 AND p.started_at = $3 AND p.song_id = $2
+		)
+		RETURNING id, song_title, song_artist, created_at
+	`
+
+	err = tx.QueryRow(query,
+		play.ListenerID,
+		play.SongID,
+		play.StartedAt,
+		play.MsPlayed,
+		play.Completed,
+		play.Skipped,
+		time.Now(),
+	).Scan(&play.ID, &play.SongTitle, &play.SongArtist, &play.CreatedAt)
+	if err != nil {
+		// Also when the song was deleted since it was matched
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("error creating play: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error committing play: %v", err)
+	}
+
+	return true, nil
+}
+
+// GetPlays retrieves up to limit plays of a listener, most recent first. When
+// before is set only plays ordered after the play (beforeStartedAt, beforeID)
+// are returned, which pages through the history without skipping or repeating
+// plays recorded meanwhile.
+func (r *PlayRepository) GetPlays(listenerID string, before *time.Time, beforeID int64, limit int) ([]models.Play, error) {
+	query := `
+		SELECT p.id, p.listener_id, p.song_id, p.song_title, p.song_artist, NOT EXISTS (SELECT 1 FROM songs s WHERE s.id = p.song_id),
+			p.playlist_id, p.started_at, p.ms_played, p.completed, p.skipped, p.created_at
+		FROM plays p
+		WHERE p.listener_id = $1 AND ($2::timestamptz IS NULL OR (p.started_at, p.id) < ($2, $3))
+		ORDER BY p.started_at DESC, p.id DESC
+		LIMIT $4
+	`
+
+	rows, err := r.db.Query(query, listenerID, before, beforeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying plays: %v", err)
+	}
+	defer rows.Close()
+
+	plays := []models.Play{}
+	for rows.Next() {
+		var play models.Play
+		err := rows.Scan(
+			&play.ID,
+			&play.ListenerID,
+			&play.SongID,
+			&play.SongTitle,
+			&play.SongArtist,
+			&play.SongDeleted,
+			&play.PlaylistID,
+			&play.StartedAt,
+			&play.MsPlayed,
+			&play.Completed,
+			&play.Skipped,
+			&play.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning play: %v", err)
+		}
+		plays = append(plays, play)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating plays: %v", err)
+	}
+
+	return plays, nil
+}
+
+// ensurePlayPartition creates the monthly partition of plays holding t, named
+// plays_YYYY_MM after its UTC month, unless it already exists
+func (r *PlayRepository) ensurePlayPartition(t time.Time) error {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if _, ok := playPartitions.Load(start); ok {
+		return nil
+	}
+
+	end := start.AddDate(0, 1, 0)
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS plays_%04d_%02d PARTITION OF plays FOR VALUES FROM ('%s') TO ('%s')`,
+		start.Year(), int(start.Month()), start.Format(time.RFC3339), end.Format(time.RFC3339))
+	if _, err := r.db.Exec(query); err != nil {
+		// Another instance may have created the partition at the same time
+		pqErr, ok := err.(*pq.Error)
+		if !ok || (pqErr.Code != "42P07" && pqErr.Code != "23505") {
+			return err
+		}
+	}
+
+	playPartitions.Store(start, true)
+	return nil
+}
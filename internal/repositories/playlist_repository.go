@@ -1,47 +1,100 @@
 package repositories
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"log"
+	"melodia/internal/compose"
 	"melodia/internal/database"
 	"melodia/internal/models"
+	"melodia/internal/smartplaylist"
+	"melodia/internal/storage"
+	"path"
+	"strconv"
 	"time"
+
+	"github.com/lib/pq"
 )
 
+// playlistColumns lists the columns read by scanPlaylist, for queries aliasing playlists as p
+const playlistColumns = `
+	p.id, p.name, p.description, COALESCE(p.creator, ''), p.is_published, p.published_at,
+	COALESCE(p.cover_key, ''), p.forked_from, p.rules,
+	(SELECT COUNT(*) FROM playlists f WHERE f.forked_from = p.id),
+	(SELECT COUNT(*) FROM playlist_likes l WHERE l.playlist_id = p.id), p.created_at, p.updated_at`
+
 // PlaylistRepository handles database operations for playlists
 type PlaylistRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	blobs storage.BlobStore
 }
 
 // NewPlaylistRepository creates a new playlist repository
 func NewPlaylistRepository() *PlaylistRepository {
 	return &PlaylistRepository{
-		db: database.DB,
+		db:    database.DB,
+		blobs: storage.Blobs,
 	}
 }
 
 // CreatePlaylist creates a new playlist in the database
 func (r *PlaylistRepository) CreatePlaylist(playlist *models.Playlist) error {
-	query := `
-		INSERT INTO playlists (name, description, is_published, published_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, created_at, updated_at
-	`
+	if err := insertPlaylist(r.db, playlist); err != nil {
+		return fmt.Errorf("error creating playlist: %v", err)
+	}
 
-	now := time.Now()
-	err := r.db.QueryRow(query,
-		playlist.Name,
-		playlist.Description,
-		playlist.IsPublished,
-		playlist.PublishedAt,
-		now,
-		now,
-	).Scan(&playlist.ID, &playlist.CreatedAt, &playlist.UpdatedAt)
+	return nil
+}
 
+// PlaylistTrack is a song to add to a playlist being created. Songs without an ID
+// are inserted first; a nil AddedAt means now.
+type PlaylistTrack struct {
+	Song    *models.Song
+	AddedAt *time.Time
+}
+
+// CreatePlaylistWithSongs creates a playlist, any new songs and the playlist entries
+// in a single transaction. Tracks keep their order among equal addedAt values and
+// songs listed more than once are added once.
+func (r *PlaylistRepository) CreatePlaylistWithSongs(playlist *models.Playlist, tracks []PlaylistTrack) error {
+	tx, err := r.db.Begin()
 	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertPlaylist(tx, playlist); err != nil {
 		return fmt.Errorf("error creating playlist: %v", err)
 	}
 
+	insertQuery := `
+		INSERT INTO playlist_songs (playlist_id, song_id, added_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (playlist_id, song_id) DO NOTHING
+	`
+	for _, track := range tracks {
+		if track.Song.ID == 0 {
+			if err := insertSong(tx, track.Song); err != nil {
+				return err
+			}
+		}
+
+		addedAt := playlist.CreatedAt
+		if track.AddedAt != nil {
+			addedAt = *track.AddedAt
+		}
+		if _, err := tx.Exec(insertQuery, playlist.ID, track.Song.ID, addedAt); err != nil {
+			return fmt.Errorf("error adding song to playlist: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
 	return nil
 }
 
@@ -52,17 +105,17 @@ func (r *PlaylistRepository) GetPlaylists(published *bool) ([]models.Playlist, e
 	if published == nil || *published {
 		// Default: only published playlists, ordered by publishedAt desc
 		query = `
-			SELECT id, name, description, is_published, published_at, created_at, updated_at 
-			FROM playlists 
-			WHERE is_published = true 
-			ORDER BY published_at DESC
+			SELECT ` + playlistColumns + `
+			FROM playlists p
+			WHERE p.is_published = true
+			ORDER BY p.published_at DESC
 		`
 	} else {
 		// All playlists, ordered by created_at desc (most recent first)
 		query = `
-			SELECT id, name, description, is_published, published_at, created_at, updated_at 
-			FROM playlists 
-			ORDER BY created_at DESC
+			SELECT ` + playlistColumns + `
+			FROM playlists p
+			ORDER BY p.created_at DESC
 		`
 	}
 
@@ -75,47 +128,13 @@ func (r *PlaylistRepository) GetPlaylists(published *bool) ([]models.Playlist, e
 	var playlists []models.Playlist
 	for rows.Next() {
 		var playlist models.Playlist
-		err := rows.Scan(
-			&playlist.ID,
-			&playlist.Name,
-			&playlist.Description,
-			&playlist.IsPublished,
-			&playlist.PublishedAt,
-			&playlist.CreatedAt,
-			&playlist.UpdatedAt,
-		)
-		if err != nil {
+		if err := scanPlaylist(rows, &playlist); err != nil {
 			return nil, fmt.Errorf("error scanning playlist: %v", err)
 		}
 
-		// Load songs for this playlist
-		songsQuery := `
-			SELECT s.id, s.title, s.artist, ps.added_at
-			FROM playlist_songs ps
-			JOIN songs s ON ps.song_id = s.id
-			WHERE ps.playlist_id = $1
-			ORDER BY ps.added_at DESC
-		`
-
-		songRows, err := r.db.Query(songsQuery, playlist.ID)
+		songs, err := r.getPlaylistSongs(playlist.ID)
 		if err != nil {
-			return nil, fmt.Errorf("error querying playlist songs: %v", err)
-		}
-
-		var songs []models.PlaylistSong
-		for songRows.Next() {
-			var song models.PlaylistSong
-			err := songRows.Scan(&song.ID, &song.Title, &song.Artist, &song.AddedAt)
-			if err != nil {
-				songRows.Close()
-				return nil, fmt.Errorf("error scanning playlist song: %v", err)
-			}
-			songs = append(songs, song)
-		}
-
-		songRows.Close()
-		if err = songRows.Err(); err != nil {
-			return nil, fmt.Errorf("error iterating playlist songs: %v", err)
+			return nil, err
 		}
 
 		playlist.Songs = songs
@@ -132,64 +151,37 @@ func (r *PlaylistRepository) GetPlaylists(published *bool) ([]models.Playlist, e
 // GetPlaylistByID retrieves a playlist by its ID with songs ordered by addedAt desc
 func (r *PlaylistRepository) GetPlaylistByID(id uint) (*models.Playlist, error) {
 	// First get the playlist
-	playlistQuery := `
-		SELECT id, name, description, is_published, published_at, created_at, updated_at 
-		FROM playlists 
-		WHERE id = $1
-	`
-
-	var playlist models.Playlist
-	err := r.db.QueryRow(playlistQuery, id).Scan(
-		&playlist.ID,
-		&playlist.Name,
-		&playlist.Description,
-		&playlist.IsPublished,
-		&playlist.PublishedAt,
-		&playlist.CreatedAt,
-		&playlist.UpdatedAt,
-	)
-
+	playlist, err := r.GetPlaylistWithoutSongs(id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("playlist not found")
-		}
-		return nil, fmt.Errorf("error querying playlist: %v", err)
+		return nil, err
 	}
 
 	// Then get the songs for this playlist
-	songsQuery := `
-		SELECT s.id, s.title, s.artist, ps.added_at
-		FROM playlist_songs ps
-		JOIN songs s ON ps.song_id = s.id
-		WHERE ps.playlist_id = $1
-		ORDER BY ps.added_at DESC
-	`
-
-	songRows, err := r.db.Query(songsQuery, id)
+	songs, err := r.getPlaylistSongs(id)
 	if err != nil {
-		return nil, fmt.Errorf("error querying playlist songs: %v", err)
+		return nil, err
 	}
-	defer songRows.Close()
 
-	var songs []models.PlaylistSong
-	for songRows.Next() {
-		var song models.PlaylistSong
-		err := songRows.Scan(&song.ID, &song.Title, &song.Artist, &song.AddedAt)
-		if err != nil {
-			return nil, fmt.Errorf("error scanning playlist song: %v", err)
-		}
-		songs = append(songs, song)
-	}
+	playlist.Songs = songs
+	return playlist, nil
+}
 
-	if err = songRows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating playlist songs: %v", err)
+// GetPlaylistWithoutSongs retrieves a playlist by its ID leaving its songs empty
+func (r *PlaylistRepository) GetPlaylistWithoutSongs(id uint) (*models.Playlist, error) {
+	playlistQuery := `SELECT ` + playlistColumns + ` FROM playlists p WHERE p.id = $1`
+
+	var playlist models.Playlist
+	if err := scanPlaylist(r.db.QueryRow(playlistQuery, id), &playlist); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("playlist not found")
+		}
+		return nil, fmt.Errorf("error querying playlist: %v", err)
 	}
 
-	playlist.Songs = songs
 	return &playlist, nil
 }
 
-// DeletePlaylist deletes a playlist from the database
+// DeletePlaylist deletes a playlist from the database along with its stored cover images
 func (r *PlaylistRepository) DeletePlaylist(id uint) error {
 	query := `DELETE FROM playlists WHERE id = $1`
 
@@ -207,6 +199,13 @@ func (r *PlaylistRepository) DeletePlaylist(id uint) error {
 		return fmt.Errorf("playlist not found")
 	}
 
+	// The row is gone, so a failure here only leaves orphaned files behind
+	if r.blobs != nil {
+		if err := r.blobs.DeletePrefix(playlistBlobPrefix(id)); err != nil {
+			log.Printf("Failed to delete blobs of playlist %d: %v", id, err)
+		}
+	}
+
 	return nil
 }
 
@@ -223,16 +222,19 @@ func (r *PlaylistRepository) AddSongToPlaylist(playlistID, song_id uint) error {
 		return fmt.Errorf("error checking song: %v", err)
 	}
 
-	// Then check if the playlist exists
-	playlistQuery := `SELECT id FROM playlists WHERE id = $1`
-	var playlistExists uint
-	err = r.db.QueryRow(playlistQuery, playlistID).Scan(&playlistExists)
+	// Then check if the playlist exists and takes songs by hand
+	playlistQuery := `SELECT rules IS NOT NULL FROM playlists WHERE id = $1`
+	var smart bool
+	err = r.db.QueryRow(playlistQuery, playlistID).Scan(&smart)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("playlist not found")
 		}
 		return fmt.Errorf("error checking playlist: %v", err)
 	}
+	if smart {
+		return fmt.Errorf("playlist is smart")
+	}
 
 	// Add the song to the playlist
 	insertQuery := `
@@ -275,3 +277,798 @@ func (r *PlaylistRepository) PublishPlaylist(id uint) error {
 
 	return nil
 }
+
+// SetPlaylistCover stores a cover image and its thumbnails, replacing the previous cover.
+// Blobs are written under a new version prefix so cached URLs of the old cover stay valid
+// until the row is updated.
+func (r *PlaylistRepository) SetPlaylistCover(id uint, version, contentType string, original []byte, thumbnails map[int][]byte) error {
+	var oldKey sql.NullString
+	err := r.db.QueryRow(`SELECT cover_key FROM playlists WHERE id = $1`, id).Scan(&oldKey)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("playlist not found")
+		}
+		return fmt.Errorf("error checking playlist: %v", err)
+	}
+
+	coverKey := path.Join(playlistBlobPrefix(id), "cover", version)
+
+	if _, err := r.blobs.Put(path.Join(coverKey, "original"), bytes.NewReader(original)); err != nil {
+		return fmt.Errorf("error storing cover: %v", err)
+	}
+	for size, data := range thumbnails {
+		if _, err := r.blobs.Put(path.Join(coverKey, strconv.Itoa(size)+".jpg"), bytes.NewReader(data)); err != nil {
+			r.blobs.DeletePrefix(coverKey + "/")
+			return fmt.Errorf("error storing cover thumbnail: %v", err)
+		}
+	}
+
+	query := `
+		UPDATE playlists
+		SET cover_key = $1, cover_content_type = $2, updated_at = $3
+		WHERE id = $4
+	`
+	if _, err := r.db.Exec(query, coverKey, contentType, time.Now(), id); err != nil {
+		r.blobs.DeletePrefix(coverKey + "/")
+		return fmt.Errorf("error updating playlist cover: %v", err)
+	}
+
+	if oldKey.Valid && oldKey.String != coverKey {
+		if err := r.blobs.DeletePrefix(oldKey.String + "/"); err != nil {
+			log.Printf("Failed to delete previous cover of playlist %d: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+// GetPlaylistCover returns the blob key and MIME type of the requested cover size.
+// A size of 0 selects the original upload.
+func (r *PlaylistRepository) GetPlaylistCover(id uint, size int) (string, string, error) {
+	var coverKey, contentType sql.NullString
+	err := r.db.QueryRow(`SELECT cover_key, cover_content_type FROM playlists WHERE id = $1`, id).
+		Scan(&coverKey, &contentType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", fmt.Errorf("playlist not found")
+		}
+		return "", "", fmt.Errorf("error querying playlist cover: %v", err)
+	}
+
+	if !coverKey.Valid {
+		return "", "", fmt.Errorf("cover not found")
+	}
+
+	if size == 0 {
+		return path.Join(coverKey.String, "original"), contentType.String, nil
+	}
+
+	return path.Join(coverKey.String, strconv.Itoa(size)+".jpg"), "image/jpeg", nil
+}
+
+// DeletePlaylistCover removes the cover of a playlist
+func (r *PlaylistRepository) DeletePlaylistCover(id uint) error {
+	var coverKey sql.NullString
+	err := r.db.QueryRow(`SELECT cover_key FROM playlists WHERE id = $1`, id).Scan(&coverKey)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("playlist not found")
+		}
+		return fmt.Errorf("error checking playlist: %v", err)
+	}
+
+	if !coverKey.Valid {
+		return fmt.Errorf("cover not found")
+	}
+
+	query := `
+		UPDATE playlists
+		SET cover_key = NULL, cover_content_type = NULL, updated_at = $1
+		WHERE id = $2
+	`
+	if _, err := r.db.Exec(query, time.Now(), id); err != nil {
+		return fmt.Errorf("error deleting playlist cover: %v", err)
+	}
+
+	if err := r.blobs.DeletePrefix(coverKey.String + "/"); err != nil {
+		log.Printf("Failed to delete cover of playlist %d: %v", id, err)
+	}
+
+	return nil
+}
+
+// SetPlaylistShuffle stores the shuffled order of a playlist, replacing any
+// order stored before
+func (r *PlaylistRepository) SetPlaylistShuffle(playlistID uint, seed string, songIDs []uint) error {
+	query := `
+		INSERT INTO playlist_shuffles (playlist_id, seed, song_ids, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (playlist_id) DO UPDATE
+		SET seed = EXCLUDED.seed, song_ids = EXCLUDED.song_ids, created_at = EXCLUDED.created_at
+	`
+
+	_, err := r.db.Exec(query, playlistID, seed, pq.Array(toInt64s(songIDs)), time.Now())
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return fmt.Errorf("playlist not found")
+		}
+		return fmt.Errorf("error storing playlist shuffle: %v", err)
+	}
+
+	return nil
+}
+
+// GetPlaylistShuffle returns the seed and song order of the stored shuffle of a
+// playlist, or an empty seed when none is stored
+func (r *PlaylistRepository) GetPlaylistShuffle(playlistID uint) (string, []uint, error) {
+	var seed string
+	var songIDs []int64
+	err := r.db.QueryRow(`SELECT seed, song_ids FROM playlist_shuffles WHERE playlist_id = $1`, playlistID).
+		Scan(&seed, pq.Array(&songIDs))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil, nil
+		}
+		return "", nil, fmt.Errorf("error querying playlist shuffle: %v", err)
+	}
+
+	order := make([]uint, len(songIDs))
+	for i, id := range songIDs {
+		order[i] = uint(id)
+	}
+	return seed, order, nil
+}
+
+// DeletePlaylistShuffle forgets the stored shuffle of a playlist
+func (r *PlaylistRepository) DeletePlaylistShuffle(playlistID uint) error {
+	result, err := r.db.Exec(`DELETE FROM playlist_shuffles WHERE playlist_id = $1`, playlistID)
+	if err != nil {
+		return fmt.Errorf("error deleting playlist shuffle: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("shuffle not found")
+	}
+
+	return nil
+}
+
+// DuplicatePlaylist copies a playlist and all its entries into a new unpublished
+// playlist that records the original as its fork source, in a single transaction.
+// Entries keep their added_at unless resetAddedAt is set, in which case they all
+// get the time of the copy; either way the copy lists songs in the same order.
+func (r *PlaylistRepository) DuplicatePlaylist(sourceID uint, name string, resetAddedAt bool) (*models.Playlist, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Keep the original from being deleted or edited while it is copied
+	source := models.Playlist{}
+	var rules []byte
+	err = tx.QueryRow(`SELECT name, description, COALESCE(creator, ''), rules FROM playlists WHERE id = $1 FOR SHARE`, sourceID).
+		Scan(&source.Name, &source.Description, &source.Creator, &rules)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("playlist not found")
+		}
+		return nil, fmt.Errorf("error querying playlist: %v", err)
+	}
+
+	fork := &models.Playlist{
+		Name:        source.Name,
+		Description: source.Description,
+		Creator:     source.Creator,
+		ForkedFrom:  &sourceID,
+	}
+	// Forks of smart playlists follow the same rules
+	if rules != nil {
+		fork.Rules = &models.SmartPlaylistRules{}
+		if err := json.Unmarshal(rules, fork.Rules); err != nil {
+			return nil, fmt.Errorf("error decoding playlist rules: %v", err)
+		}
+	}
+	if name != "" {
+		fork.Name = name
+	}
+	if err := insertPlaylist(tx, fork); err != nil {
+		return nil, fmt.Errorf("error creating playlist: %v", err)
+	}
+
+	// Entries are inserted in display order so ties on added_at keep their order
+	copyQuery := `
+		INSERT INTO playlist_songs (playlist_id, song_id, added_at)
+		SELECT $1, song_id, CASE WHEN $3 THEN $4::timestamptz ELSE added_at END
+		FROM playlist_songs
+		WHERE playlist_id = $2
+		ORDER BY added_at DESC, id ASC
+	`
+	if _, err := tx.Exec(copyQuery, fork.ID, sourceID, resetAddedAt, fork.CreatedAt); err != nil {
+		return nil, fmt.Errorf("error copying playlist songs: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return r.GetPlaylistByID(fork.ID)
+}
+
+// GetForkTree retrieves a playlist and every playlist forked from it, directly or
+// through other forks, as a tree ordered by creation time
+func (r *PlaylistRepository) GetForkTree(id uint) (*models.PlaylistFork, error) {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth FROM playlists WHERE id = $1
+			UNION ALL
+			SELECT p.id, t.depth + 1
+			FROM playlists p
+			JOIN tree t ON p.forked_from = t.id
+		)
+		SELECT p.id, COALESCE(p.forked_from, 0), p.name, p.is_published,
+			(SELECT COUNT(*) FROM playlists f WHERE f.forked_from = p.id), p.created_at
+		FROM tree t
+		JOIN playlists p ON p.id = t.id
+		ORDER BY t.depth, p.created_at, p.id
+	`
+
+	rows, err := r.db.Query(query, id)
+	if err != nil {
+		return nil, fmt.Errorf("error querying fork tree: %v", err)
+	}
+	defer rows.Close()
+
+	nodes := make(map[uint]*models.PlaylistFork)
+	children := make(map[uint][]uint)
+	for rows.Next() {
+		var node models.PlaylistFork
+		var parentID uint
+		if err := rows.Scan(&node.ID, &parentID, &node.Name, &node.IsPublished, &node.ForkCount, &node.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning fork: %v", err)
+		}
+		nodes[node.ID] = &node
+		if node.ID != id {
+			children[parentID] = append(children[parentID], node.ID)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating fork tree: %v", err)
+	}
+
+	if _, ok := nodes[id]; !ok {
+		return nil, fmt.Errorf("playlist not found")
+	}
+
+	var build func(id uint) models.PlaylistFork
+	build = func(id uint) models.PlaylistFork {
+		node := *nodes[id]
+		node.Forks = make([]models.PlaylistFork, 0, len(children[id]))
+		for _, childID := range children[id] {
+			node.Forks = append(node.Forks, build(childID))
+		}
+		return node
+	}
+
+	tree := build(id)
+	return &tree, nil
+}
+
+// MissingPlaylists returns which of the given playlist IDs do not exist, in the
+// order given and without repetitions
+func (r *PlaylistRepository) MissingPlaylists(ids []uint) ([]uint, error) {
+	return r.filterPlaylistIDs(ids, `NOT EXISTS (SELECT 1 FROM playlists p WHERE p.id = u.id)`)
+}
+
+// SmartPlaylists returns which of the given playlist IDs are smart playlists, in
+// the order given and without repetitions
+func (r *PlaylistRepository) SmartPlaylists(ids []uint) ([]uint, error) {
+	return r.filterPlaylistIDs(ids, `EXISTS (SELECT 1 FROM playlists p WHERE p.id = u.id AND p.rules IS NOT NULL)`)
+}
+
+// filterPlaylistIDs returns the IDs meeting condition, which reads each ID as u.id
+func (r *PlaylistRepository) filterPlaylistIDs(ids []uint, condition string) ([]uint, error) {
+	query := `
+		SELECT u.id
+		FROM unnest($1::int[]) WITH ORDINALITY AS u(id, ord)
+		WHERE ` + condition + `
+		GROUP BY u.id
+		ORDER BY MIN(u.ord)
+	`
+
+	rows, err := r.db.Query(query, pq.Array(toInt64s(ids)))
+	if err != nil {
+		return nil, fmt.Errorf("error querying playlists: %v", err)
+	}
+	defer rows.Close()
+
+	filtered := []uint{}
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning playlist ID: %v", err)
+		}
+		filtered = append(filtered, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating playlists: %v", err)
+	}
+
+	return filtered, nil
+}
+
+// ComposePlaylistSongs returns the songs resulting from a composition without
+// storing anything. See composedSongsQuery for their order.
+func (r *PlaylistRepository) ComposePlaylistSongs(expr *models.ComposeExpression) ([]models.PlaylistSong, error) {
+	ranked, args := composedSongsQuery(expr)
+	query := ranked + `
+		SELECT s.id, s.title, s.artist, COALESCE(s.album, ''), COALESCE(s.duration_ms, 0), s.audio_key IS NOT NULL, c.added_at
+		FROM composed c
+		JOIN songs s ON s.id = c.song_id
+		ORDER BY c.ord, c.added_at DESC, c.entry_id
+	`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error composing playlists: %v", err)
+	}
+	defer rows.Close()
+
+	songs := []models.PlaylistSong{}
+	for rows.Next() {
+		var song models.PlaylistSong
+		err := rows.Scan(&song.ID, &song.Title, &song.Artist, &song.Album, &song.DurationMs, &song.HasAudio, &song.AddedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning playlist song: %v", err)
+		}
+		songs = append(songs, song)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating playlist songs: %v", err)
+	}
+
+	return songs, nil
+}
+
+// ComposePlaylist stores the result of a composition as a new playlist. Every
+// song gets the creation time of the playlist as added_at and keeps the order
+// shown by ComposePlaylistSongs.
+func (r *PlaylistRepository) ComposePlaylist(playlist *models.Playlist, expr *models.ComposeExpression) (*models.Playlist, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Keep the operands from being deleted or edited while they are read
+	ids := toInt64s(compose.Playlists(expr))
+	var found int
+	lockQuery := `SELECT COUNT(*) FROM (SELECT id FROM playlists WHERE id = ANY($1) ORDER BY id FOR SHARE) p`
+	if err := tx.QueryRow(lockQuery, pq.Array(ids)).Scan(&found); err != nil {
+		return nil, fmt.Errorf("error locking playlists: %v", err)
+	}
+	if found != countDistinct(ids) {
+		return nil, fmt.Errorf("playlist not found")
+	}
+
+	if err := insertPlaylist(tx, playlist); err != nil {
+		return nil, fmt.Errorf("error creating playlist: %v", err)
+	}
+
+	ranked, args := composedSongsQuery(expr)
+	args = append(args, playlist.ID, playlist.CreatedAt)
+	// Entries are inserted in display order so ties on added_at keep their order
+	insertQuery := ranked + fmt.Sprintf(`
+		INSERT INTO playlist_songs (playlist_id, song_id, added_at)
+		SELECT $%d, c.song_id, $%d::timestamptz
+		FROM composed c
+		ORDER BY c.ord, c.added_at DESC, c.entry_id
+	`, len(args)-1, len(args))
+	if _, err := tx.Exec(insertQuery, args...); err != nil {
+		return nil, fmt.Errorf("error composing playlists: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return r.GetPlaylistByID(playlist.ID)
+}
+
+// composedSongsQuery returns a WITH clause defining composed, one row per song in
+// the result of a composition. Each song is placed where it first appears: ord is
+// the position of the leftmost operand holding it, and added_at and entry_id
+// come from its most recent entry there.
+func composedSongsQuery(expr *models.ComposeExpression) (string, []interface{}) {
+	args := []interface{}{pq.Array(toInt64s(compose.Playlists(expr)))}
+	result, args := compose.Compile(expr, args)
+
+	return `
+		WITH result AS (` + result + `),
+		composed AS (
+			SELECT DISTINCT ON (ps.song_id) ps.song_id, o.ord, ps.added_at, ps.id AS entry_id
+			FROM unnest($1::int[]) WITH ORDINALITY AS o(playlist_id, ord)
+			JOIN playlist_songs ps ON ps.playlist_id = o.playlist_id
+			JOIN result r ON r.song_id = ps.song_id
+			ORDER BY ps.song_id, o.ord, ps.added_at DESC, ps.id
+		)`, args
+}
+
+// toInt64s converts IDs for use with pq.Array
+func toInt64s(ids []uint) []int64 {
+	converted := make([]int64, len(ids))
+	for i, id := range ids {
+		converted[i] = int64(id)
+	}
+	return converted
+}
+
+// countDistinct counts the different values in ids
+func countDistinct(ids []int64) int {
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	return len(seen)
+}
+
+// getPlaylistSongs loads the songs of a playlist ordered by addedAt desc
+func (r *PlaylistRepository) getPlaylistSongs(playlistID uint) ([]models.PlaylistSong, error) {
+	var songs []models.PlaylistSong
+	err := r.EachPlaylistSong(playlistID, func(song *models.PlaylistSong) error {
+		songs = append(songs, *song)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return songs, nil
+}
+
+// EachPlaylistSong calls fn for every song of a playlist ordered by addedAt desc as
+// rows arrive from the database. Songs added together keep the order in which they
+// were inserted. The songs of smart playlists are selected by their rules at the
+// time of the call, in the order the rules give. An error returned by fn stops the
+// iteration and is returned as is.
+func (r *PlaylistRepository) EachPlaylistSong(playlistID uint, fn func(song *models.PlaylistSong) error) error {
+	var rules []byte
+	err := r.db.QueryRow(`SELECT rules FROM playlists WHERE id = $1`, playlistID).Scan(&rules)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error querying playlist: %v", err)
+	}
+
+	query := `
+		SELECT s.id, s.title, s.artist, COALESCE(s.album, ''), COALESCE(s.duration_ms, 0), s.audio_key IS NOT NULL, ps.added_at
+		FROM playlist_songs ps
+		JOIN songs s ON ps.song_id = s.id
+		WHERE ps.playlist_id = $1
+		ORDER BY ps.added_at DESC, ps.id ASC
+	`
+	args := []interface{}{playlistID}
+	if rules != nil {
+		query, args, err = smartSongsQuery(rules)
+		if err != nil {
+			return err
+		}
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("error querying playlist songs: %v", err)
+	}
+	defer rows.Close()
+
+	var song models.PlaylistSong
+	for rows.Next() {
+		err := rows.Scan(&song.ID, &song.Title, &song.Artist, &song.Album, &song.DurationMs, &song.HasAudio, &song.AddedAt)
+		if err != nil {
+			return fmt.Errorf("error scanning playlist song: %v", err)
+		}
+		if err := fn(&song); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating playlist songs: %v", err)
+	}
+
+	return nil
+}
+
+// GetPlaylistStats computes the statistics of a playlist from a single snapshot.
+// Entries of smart playlists are the songs matching their rules, added when they
+// entered the catalog.
+func (r *PlaylistRepository) GetPlaylistStats(id uint) (*models.PlaylistStats, error) {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var rules []byte
+	var published bool
+	if err := tx.QueryRow(`SELECT rules, is_published FROM playlists WHERE id = $1`, id).Scan(&rules, &published); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("playlist not found")
+		}
+		return nil, fmt.Errorf("error querying playlist: %v", err)
+	}
+
+	// Every query reads the entries of the playlist
+	entries := `SELECT song_id, added_at FROM playlist_songs WHERE playlist_id = $1`
+	args := []interface{}{id}
+	if rules != nil {
+		query, smartArgs, err := smartSongsQuery(rules)
+		if err != nil {
+			return nil, err
+		}
+		entries = `SELECT q.id AS song_id, q.created_at AS added_at FROM (` + query + `) q`
+		args = smartArgs
+	}
+	with := `WITH entries AS (` + entries + `) `
+	idParam := "$" + strconv.Itoa(len(args)+1)
+
+	stats := &models.PlaylistStats{PlaylistID: id, IsPublished: published}
+	summaryQuery := with + `
+		SELECT count(*), COALESCE(sum(s.duration_ms), 0), COALESCE(round(avg(s.duration_ms)), 0)::bigint,
+			count(DISTINCT lower(s.artist)), count(DISTINCT e.song_id),
+			count(DISTINCT e.song_id) FILTER (WHERE EXISTS (
+				SELECT 1
+				FROM playlist_songs o
+				JOIN playlists p ON p.id = o.playlist_id
+				WHERE o.song_id = e.song_id AND p.is_published AND p.id <> ` + idParam + `
+			))
+		FROM entries e
+		JOIN songs s ON s.id = e.song_id
+	`
+	err = tx.QueryRow(summaryQuery, append(args, id)...).Scan(&stats.TrackCount, &stats.TotalDurationMs, &stats.AverageDurationMs,
+		&stats.ArtistCount, &stats.DistinctSongs, &stats.SharedSongs)
+	if err != nil {
+		return nil, fmt.Errorf("error querying playlist summary: %v", err)
+	}
+	if stats.DistinctSongs > 0 {
+		stats.SharedRatio = float64(stats.SharedSongs) / float64(stats.DistinctSongs)
+	}
+
+	artistsQuery := with + `
+		SELECT min(s.artist), count(*), count(*)::float8 / (sum(count(*)) OVER ())::float8
+		FROM entries e
+		JOIN songs s ON s.id = e.song_id
+		GROUP BY lower(s.artist)
+		ORDER BY count(*) DESC, lower(s.artist)
+	`
+	stats.Artists = []models.PlaylistArtistStat{}
+	err = queryStats(tx, artistsQuery, args, func(rows *sql.Rows) error {
+		var stat models.PlaylistArtistStat
+		if err := rows.Scan(&stat.Artist, &stat.Count, &stat.Share); err != nil {
+			return err
+		}
+		stats.Artists = append(stats.Artists, stat)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	decadesQuery := with + `
+		SELECT s.year / 10 * 10 AS decade, count(*), count(*)::float8 / (sum(count(*)) OVER ())::float8
+		FROM entries e
+		JOIN songs s ON s.id = e.song_id
+		GROUP BY decade
+		ORDER BY decade NULLS LAST
+	`
+	stats.Decades = []models.PlaylistDecadeStat{}
+	err = queryStats(tx, decadesQuery, args, func(rows *sql.Rows) error {
+		var stat models.PlaylistDecadeStat
+		var decade sql.NullInt64
+		if err := rows.Scan(&decade, &stat.Count, &stat.Share); err != nil {
+			return err
+		}
+		if decade.Valid {
+			value := int(decade.Int64)
+			stat.Decade = &value
+		}
+		stats.Decades = append(stats.Decades, stat)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	genresQuery := with + `
+		SELECT min(s.genre), count(*), count(*)::float8 / (sum(count(*)) OVER ())::float8
+		FROM entries e
+		JOIN songs s ON s.id = e.song_id
+		GROUP BY lower(s.genre)
+		ORDER BY count(*) DESC, lower(s.genre) NULLS LAST
+	`
+	stats.Genres = []models.PlaylistGenreStat{}
+	err = queryStats(tx, genresQuery, args, func(rows *sql.Rows) error {
+		var stat models.PlaylistGenreStat
+		var genre sql.NullString
+		if err := rows.Scan(&genre, &stat.Count, &stat.Share); err != nil {
+			return err
+		}
+		if genre.Valid {
+			stat.Genre = &genre.String
+		}
+		stats.Genres = append(stats.Genres, stat)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Weeks start on Monday in UTC; weeks without additions are filled in so the
+	// series can be plotted as is
+	weeksQuery := with + `,
+		weeks AS (
+			SELECT date_trunc('week', added_at AT TIME ZONE 'UTC') AS week, count(*) AS added
+			FROM entries
+			GROUP BY week
+		)
+		SELECT g.week, COALESCE(w.added, 0), sum(COALESCE(w.added, 0)) OVER (ORDER BY g.week)
+		FROM generate_series((SELECT min(week) FROM weeks), (SELECT max(week) FROM weeks), interval '1 week') AS g(week)
+		LEFT JOIN weeks w ON w.week = g.week
+		ORDER BY g.week
+	`
+	stats.WeeklyAdditions = []models.PlaylistWeekStat{}
+	err = queryStats(tx, weeksQuery, args, func(rows *sql.Rows) error {
+		var stat models.PlaylistWeekStat
+		var week time.Time
+		if err := rows.Scan(&week, &stat.Added, &stat.Total); err != nil {
+			return err
+		}
+		stat.Week = week.Format("2006-01-02")
+		stats.WeeklyAdditions = append(stats.WeeklyAdditions, stat)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// queryStats runs a statistics query, calling scan for every row
+func queryStats(tx *sql.Tx, query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("error querying playlist stats: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return fmt.Errorf("error scanning playlist stats: %v", err)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating playlist stats: %v", err)
+	}
+
+	return nil
+}
+
+// smartSongsQuery returns the query selecting the songs of a smart playlist from
+// its stored rules, in the columns read by EachPlaylistSong. Songs count as added
+// when they entered the catalog.
+func smartSongsQuery(stored []byte) (string, []interface{}, error) {
+	var rules models.SmartPlaylistRules
+	if err := json.Unmarshal(stored, &rules); err != nil {
+		return "", nil, fmt.Errorf("error decoding playlist rules: %v", err)
+	}
+
+	where, order, args, err := smartplaylist.Compile(&rules, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("error compiling playlist rules: %v", err)
+	}
+	args = append(args, smartplaylist.Limit(&rules))
+
+	query := `
+		SELECT s.id, s.title, s.artist, COALESCE(s.album, ''), COALESCE(s.duration_ms, 0), s.audio_key IS NOT NULL, s.created_at
+		FROM songs s
+		WHERE ` + where + `
+		ORDER BY ` + order + `
+		LIMIT $` + strconv.Itoa(len(args))
+	return query, args, nil
+}
+
+// insertPlaylist inserts a playlist row using db or a transaction
+func insertPlaylist(q querier, playlist *models.Playlist) error {
+	query := `
+		INSERT INTO playlists (name, description, creator, is_published, published_at, forked_from, rules, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at
+	`
+
+	var rules interface{}
+	if playlist.Rules != nil {
+		encoded, err := json.Marshal(playlist.Rules)
+		if err != nil {
+			return fmt.Errorf("error encoding playlist rules: %v", err)
+		}
+		rules = string(encoded)
+	}
+
+	now := time.Now()
+	return q.QueryRow(query,
+		playlist.Name,
+		playlist.Description,
+		nullIfEmpty(playlist.Creator),
+		playlist.IsPublished,
+		playlist.PublishedAt,
+		playlist.ForkedFrom,
+		rules,
+		now,
+		now,
+	).Scan(&playlist.ID, &playlist.CreatedAt, &playlist.UpdatedAt)
+}
+
+// scanPlaylist scans the columns listed in playlistColumns, decodes the rules of
+// smart playlists and sets the cover URLs
+func scanPlaylist(row rowScanner, playlist *models.Playlist) error {
+	var coverKey string
+	var rules []byte
+	err := row.Scan(
+		&playlist.ID,
+		&playlist.Name,
+		&playlist.Description,
+		&playlist.Creator,
+		&playlist.IsPublished,
+		&playlist.PublishedAt,
+		&coverKey,
+		&playlist.ForkedFrom,
+		&rules,
+		&playlist.ForkCount,
+		&playlist.LikeCount,
+		&playlist.CreatedAt,
+		&playlist.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if rules != nil {
+		playlist.Rules = &models.SmartPlaylistRules{}
+		if err := json.Unmarshal(rules, playlist.Rules); err != nil {
+			return fmt.Errorf("error decoding playlist rules: %v", err)
+		}
+	}
+
+	applyCoverURLs(playlist, coverKey)
+	return nil
+}
+
+// playlistBlobPrefix returns the blob key prefix holding every file of a playlist
+func playlistBlobPrefix(id uint) string {
+	return fmt.Sprintf("playlists/%d/", id)
+}
+
+// applyCoverURLs sets the cover URLs of a playlist from its stored cover key.
+// The key version is part of the URL so clients can cache covers forever.
+func applyCoverURLs(playlist *models.Playlist, coverKey string) {
+	if coverKey == "" {
+		return
+	}
+
+	base := fmt.Sprintf("/playlists/%d/cover", playlist.ID)
+	version := path.Base(coverKey)
+
+	coverURL := base + "?v=" + version
+	playlist.CoverURL = &coverURL
+	playlist.Thumbnails = make(map[string]string, len(models.PlaylistCoverSizes))
+	for _, size := range models.PlaylistCoverSizes {
+		playlist.Thumbnails[strconv.Itoa(size)] = fmt.Sprintf("%s?size=%d&v=%s", base, size, version)
+	}
+}
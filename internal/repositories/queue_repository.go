@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"melodia/internal/database"
+	"melodia/internal/models"
+	"melodia/internal/playqueue"
+
+	"github.com/lib/pq"
+)
+
+// QueueRepository handles database operations for listener play queues
+type QueueRepository struct {
+	db *sql.DB
+}
+
+// NewQueueRepository creates a new queue repository
+func NewQueueRepository() *QueueRepository {
+	return &QueueRepository{
+		db: database.DB,
+	}
+}
+
+// GetQueue retrieves the play queue of a listener with the details of its songs.
+// Listeners without a queue get an empty one.
+func (r *QueueRepository) GetQueue(listenerID string) (*models.Queue, error) {
+	// Read the queue and its items from the same snapshot
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	queue := &models.Queue{Items: []models.QueueItem{}, Repeat: playqueue.RepeatOff}
+	currentIndex := -1
+	query := `SELECT current_index, shuffle, repeat_mode, version, updated_at FROM play_queues WHERE listener_id = $1`
+	err = tx.QueryRow(query, listenerID).Scan(&currentIndex, &queue.Shuffle, &queue.Repeat, &queue.Version, &queue.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return queue, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying queue: %v", err)
+	}
+
+	itemsQuery := `
+		SELECT i.item_id, i.play_index, s.id, s.title, s.artist, COALESCE(s.album, ''), COALESCE(s.duration_ms, 0), s.audio_key IS NOT NULL
+		FROM play_queue_items i
+		JOIN songs s ON s.id = i.song_id
+		WHERE i.listener_id = $1
+		ORDER BY i.play_index
+	`
+	rows, err := tx.Query(itemsQuery, listenerID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying queue items: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.QueueItem
+		var playIndex int
+		err := rows.Scan(&item.ID, &playIndex, &item.SongID, &item.Title, &item.Artist, &item.Album, &item.DurationMs, &item.HasAudio)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning queue item: %v", err)
+		}
+		if queue.CurrentIndex == nil && currentIndex >= 0 && playIndex >= currentIndex {
+			index := len(queue.Items)
+			queue.CurrentIndex = &index
+		}
+		queue.Items = append(queue.Items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating queue items: %v", err)
+	}
+
+	return queue, nil
+}
+
+// UpdateQueue changes the play queue of a listener with update and stores the
+// result. The queue is locked meanwhile, so changes from several devices apply
+// one after the other. When version is set and the queue changed since then,
+// nothing is updated and "queue version mismatch" is returned. Errors returned
+// by update are returned as is.
+func (r *QueueRepository) UpdateQueue(listenerID string, version *int64, update func(queue *playqueue.Queue) error) (*models.Queue, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO play_queues (listener_id) VALUES ($1) ON CONFLICT (listener_id) DO NOTHING`, listenerID); err != nil {
+		return nil, fmt.Errorf("error creating queue: %v", err)
+	}
+
+	queue := playqueue.New()
+	var currentIndex int
+	var currentVersion int64
+	lockQuery := `SELECT current_index, shuffle, repeat_mode, next_item_id, version FROM play_queues WHERE listener_id = $1 FOR UPDATE`
+	err = tx.QueryRow(lockQuery, listenerID).Scan(&currentIndex, &queue.Shuffle, &queue.Repeat, &queue.NextID, &currentVersion)
+	if err != nil {
+		return nil, fmt.Errorf("error locking queue: %v", err)
+	}
+	if version != nil && *version != currentVersion {
+		return nil, fmt.Errorf("queue version mismatch")
+	}
+
+	rows, err := tx.Query(`SELECT item_id, song_id, play_index, position FROM play_queue_items WHERE listener_id = $1 ORDER BY play_index`, listenerID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying queue items: %v", err)
+	}
+	for rows.Next() {
+		var item playqueue.Item
+		var playIndex int
+		if err := rows.Scan(&item.ID, &item.SongID, &playIndex, &item.Position); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning queue item: %v", err)
+		}
+		// Items of deleted songs are gone, so the following item takes over
+		if queue.Current < 0 && currentIndex >= 0 && playIndex >= currentIndex {
+			queue.Current = len(queue.Items)
+		}
+		queue.Items = append(queue.Items, item)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating queue items: %v", err)
+	}
+
+	if err := update(queue); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM play_queue_items WHERE listener_id = $1`, listenerID); err != nil {
+		return nil, fmt.Errorf("error clearing queue items: %v", err)
+	}
+
+	itemIDs := make([]int64, len(queue.Items))
+	songIDs := make([]int64, len(queue.Items))
+	positions := make([]int64, len(queue.Items))
+	for i, item := range queue.Items {
+		itemIDs[i] = item.ID
+		songIDs[i] = int64(item.SongID)
+		positions[i] = int64(item.Position)
+	}
+	insertQuery := `
+		INSERT INTO play_queue_items (listener_id, item_id, song_id, play_index, position)
+		SELECT $1, t.item_id, t.song_id, t.play_index - 1, t.position
+		FROM unnest($2::bigint[], $3::int[], $4::int[]) WITH ORDINALITY AS t(item_id, song_id, position, play_index)
+	`
+	if _, err := tx.Exec(insertQuery, listenerID, pq.Array(itemIDs), pq.Array(songIDs), pq.Array(positions)); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return nil, fmt.Errorf("song not found")
+		}
+		return nil, fmt.Errorf("error saving queue items: %v", err)
+	}
+
+	updateQuery := `
+		UPDATE play_queues
+		SET current_index = $2, shuffle = $3, repeat_mode = $4, next_item_id = $5, version = version + 1, updated_at = $6
+		WHERE listener_id = $1
+	`
+	_, err = tx.Exec(updateQuery, listenerID, queue.Current, queue.Shuffle, queue.Repeat, queue.NextID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error saving queue: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return r.GetQueue(listenerID)
+}
@@ -0,0 +1,301 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"melodia/internal/database"
+	"melodia/internal/models"
+	"melodia/internal/radio"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// maxRadioNeighbours is how many related songs each step of a walk considers
+	maxRadioNeighbours = 100
+	// radioCatalogSample is how many random catalog songs stations fall back to
+	// when neither the current song nor the seed has anything left to play
+	radioCatalogSample = 200
+)
+
+// RadioRepository handles database operations for radio stations
+type RadioRepository struct {
+	db *sql.DB
+}
+
+// NewRadioRepository creates a new radio repository
+func NewRadioRepository() *RadioRepository {
+	return &RadioRepository{
+		db: database.DB,
+	}
+}
+
+// SongSeed returns the seed songs of a station started from a song: the song
+// itself followed by the songs most related to it
+func (r *RadioRepository) SongSeed(songID uint) ([]uint, error) {
+	query := `
+		SELECT related_song_id
+		FROM song_similarities
+		WHERE song_id = $1
+		ORDER BY jaccard DESC, related_song_id
+		LIMIT $2
+	`
+	related, err := r.songIDs(query, songID, radio.MaxSeedSongs-1)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]uint{songID}, related...), nil
+}
+
+// ArtistSeed returns the seed songs of a station started from an artist, empty
+// when the artist has no songs
+func (r *RadioRepository) ArtistSeed(artist string) ([]uint, error) {
+	query := `SELECT id FROM songs WHERE lower(artist) = lower($1) ORDER BY id LIMIT $2`
+	return r.songIDs(query, artist, radio.MaxSeedSongs)
+}
+
+func (r *RadioRepository) songIDs(query string, args ...interface{}) ([]uint, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying seed songs: %v", err)
+	}
+	defer rows.Close()
+
+	ids := []uint{}
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning seed song: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating seed songs: %v", err)
+	}
+
+	return ids, nil
+}
+
+// CreateStation stores a new station walking from the given seed songs, with a
+// random generator of its own
+func (r *RadioRepository) CreateStation(station *models.RadioStation, seedSongs []uint) error {
+	rngState, err := rand.NewPCG(rand.Uint64(), rand.Uint64()).MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("error creating station generator: %v", err)
+	}
+
+	query := `
+		INSERT INTO radio_stations (seed_type, seed, seed_song_ids, rng_state, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	now := time.Now()
+	err = r.db.QueryRow(query, station.SeedType, station.Seed, pq.Array(toInt64s(seedSongs)), rngState, now, now).
+		Scan(&station.ID, &station.CreatedAt, &station.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating station: %v", err)
+	}
+
+	station.SeedSongs = len(seedSongs)
+	station.Played = 0
+	return nil
+}
+
+// GetStation retrieves a station by its ID
+func (r *RadioRepository) GetStation(id uint) (*models.RadioStation, error) {
+	query := `
+		SELECT id, seed_type, seed, cardinality(seed_song_ids), played, created_at, updated_at
+		FROM radio_stations
+		WHERE id = $1
+	`
+
+	var station models.RadioStation
+	err := r.db.QueryRow(query, id).Scan(&station.ID, &station.SeedType, &station.Seed, &station.SeedSongs,
+		&station.Played, &station.CreatedAt, &station.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("station not found")
+		}
+		return nil, fmt.Errorf("error querying station: %v", err)
+	}
+
+	return &station, nil
+}
+
+// NextSongs advances a station by up to n songs and returns them. Each step
+// follows the songs related to the latest one, weighted by Jaccard similarity,
+// or jumps back to the seed songs now and then or when the walk runs dry. The
+// station is locked meanwhile, so concurrent calls get consecutive songs.
+// Fewer than n songs are returned only when the catalog is empty.
+func (r *RadioRepository) NextSongs(id uint, n int) ([]models.Song, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var seedIDs, recentIDs []int64
+	var rngState []byte
+	state := radio.State{}
+	lockQuery := `
+		SELECT seed_song_ids, recent_song_ids, last_artist, artist_run, rng_state
+		FROM radio_stations
+		WHERE id = $1
+		FOR UPDATE
+	`
+	err = tx.QueryRow(lockQuery, id).Scan(pq.Array(&seedIDs), pq.Array(&recentIDs), &state.LastArtist, &state.ArtistRun, &rngState)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("station not found")
+		}
+		return nil, fmt.Errorf("error locking station: %v", err)
+	}
+	for _, songID := range recentIDs {
+		state.Recent = append(state.Recent, uint(songID))
+	}
+
+	pcg := &rand.PCG{}
+	if err := pcg.UnmarshalBinary(rngState); err != nil {
+		return nil, fmt.Errorf("error restoring station generator: %v", err)
+	}
+	rng := rand.New(pcg)
+
+	// Seed and catalog songs are only loaded when a step needs them
+	var seed, catalog []radio.Candidate
+	loadSeed := func() ([]radio.Candidate, error) {
+		if seed != nil {
+			return seed, nil
+		}
+		loaded, err := radioCandidates(tx, `SELECT id, artist, 1 FROM songs WHERE id = ANY($1)`, pq.Array(seedIDs))
+		seed = loaded
+		return loaded, err
+	}
+	loadCatalog := func() ([]radio.Candidate, error) {
+		if catalog != nil {
+			return catalog, nil
+		}
+		loaded, err := radioCandidates(tx, `SELECT id, artist, 1 FROM songs ORDER BY random() LIMIT $1`, radioCatalogSample)
+		catalog = loaded
+		return loaded, err
+	}
+
+	picked := []int64{}
+	for len(picked) < n {
+		sources := []func() ([]radio.Candidate, error){loadSeed, loadCatalog}
+		if current := state.Current(); current != 0 && rng.Float64() >= radio.RestartProbability {
+			neighbours := func() ([]radio.Candidate, error) {
+				query := `
+					SELECT s.id, s.artist, ss.jaccard
+					FROM song_similarities ss
+					JOIN songs s ON s.id = ss.related_song_id
+					WHERE ss.song_id = $1
+					ORDER BY ss.jaccard DESC, s.id
+					LIMIT $2
+				`
+				return radioCandidates(tx, query, current, maxRadioNeighbours)
+			}
+			sources = append([]func() ([]radio.Candidate, error){neighbours}, sources...)
+		}
+
+		var next radio.Candidate
+		found := false
+		for _, source := range sources {
+			candidates, err := source()
+			if err != nil {
+				return nil, err
+			}
+			if next, found = radio.Pick(candidates, &state, rng); found {
+				break
+			}
+		}
+		// Small catalogs run out of songs to keep apart, so replay the one heard longest ago
+		if !found {
+			next, found = radio.Oldest(append(append([]radio.Candidate{}, seed...), catalog...), &state)
+		}
+		if !found {
+			break
+		}
+
+		state.Record(next)
+		picked = append(picked, int64(next.SongID))
+	}
+
+	recent := make([]int64, len(state.Recent))
+	for i, songID := range state.Recent {
+		recent[i] = int64(songID)
+	}
+	if rngState, err = pcg.MarshalBinary(); err != nil {
+		return nil, fmt.Errorf("error saving station generator: %v", err)
+	}
+	updateQuery := `
+		UPDATE radio_stations
+		SET recent_song_ids = $2, last_artist = $3, artist_run = $4, rng_state = $5,
+			played = played + $6, updated_at = $7
+		WHERE id = $1
+	`
+	_, err = tx.Exec(updateQuery, id, pq.Array(recent), state.LastArtist, state.ArtistRun, rngState, len(picked), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error saving station: %v", err)
+	}
+
+	songsQuery := `
+		SELECT ` + songColumns + `
+		FROM unnest($1::int[]) WITH ORDINALITY AS o(id, ord)
+		JOIN songs s ON s.id = o.id
+		ORDER BY o.ord
+	`
+	rows, err := tx.Query(songsQuery, pq.Array(picked))
+	if err != nil {
+		return nil, fmt.Errorf("error querying station songs: %v", err)
+	}
+	defer rows.Close()
+
+	songs := []models.Song{}
+	for rows.Next() {
+		var song models.Song
+		if err := scanSong(rows, &song); err != nil {
+			return nil, fmt.Errorf("error scanning station song: %v", err)
+		}
+		songs = append(songs, song)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating station songs: %v", err)
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return songs, nil
+}
+
+// radioCandidates runs a query selecting song ID, artist and weight
+func radioCandidates(tx *sql.Tx, query string, args ...interface{}) ([]radio.Candidate, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying station candidates: %v", err)
+	}
+	defer rows.Close()
+
+	candidates := []radio.Candidate{}
+	for rows.Next() {
+		var c radio.Candidate
+		if err := rows.Scan(&c.SongID, &c.Artist, &c.Weight); err != nil {
+			return nil, fmt.Errorf("error scanning station candidate: %v", err)
+		}
+		candidates = append(candidates, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating station candidates: %v", err)
+	}
+
+	return candidates, nil
+}
@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"melodia/internal/database"
+	"melodia/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// shareLinkColumns lists the columns read by scanShareLink
+const shareLinkColumns = `id, playlist_id, COALESCE(label, ''), expires_at, revoked_at, use_count, last_used_at, created_at`
+
+// ShareLinkRepository handles database operations for playlist share links
+type ShareLinkRepository struct {
+	db *sql.DB
+}
+
+// NewShareLinkRepository creates a new share link repository
+func NewShareLinkRepository() *ShareLinkRepository {
+	return &ShareLinkRepository{
+		db: database.DB,
+	}
+}
+
+// CreateShareLink stores a new share link for a playlist
+func (r *ShareLinkRepository) CreateShareLink(link *models.ShareLink) error {
+	query := `
+		INSERT INTO playlist_share_links (playlist_id, label, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRow(query, link.PlaylistID, nullIfEmpty(link.Label), link.ExpiresAt, time.Now()).Scan(&link.ID, &link.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return fmt.Errorf("playlist not found")
+		}
+		return fmt.Errorf("error creating share link: %v", err)
+	}
+
+	return nil
+}
+
+// GetShareLinks retrieves the share links of a playlist, newest first
+func (r *ShareLinkRepository) GetShareLinks(playlistID uint) ([]models.ShareLink, error) {
+	query := `SELECT ` + shareLinkColumns + ` FROM playlist_share_links WHERE playlist_id = $1 ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.db.Query(query, playlistID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying share links: %v", err)
+	}
+	defer rows.Close()
+
+	links := []models.ShareLink{}
+	for rows.Next() {
+		var link models.ShareLink
+		if err := scanShareLink(rows, &link); err != nil {
+			return nil, fmt.Errorf("error scanning share link: %v", err)
+		}
+		links = append(links, link)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating share links: %v", err)
+	}
+
+	return links, nil
+}
+
+// RevokeShareLink revokes a share link of a playlist. Revoking twice keeps the
+// original revocation time.
+func (r *ShareLinkRepository) RevokeShareLink(playlistID, linkID uint) (*models.ShareLink, error) {
+	query := `
+		UPDATE playlist_share_links
+		SET revoked_at = COALESCE(revoked_at, $1)
+		WHERE id = $2 AND playlist_id = $3
+		RETURNING ` + shareLinkColumns
+
+	var link models.ShareLink
+	if err := scanShareLink(r.db.QueryRow(query, time.Now(), linkID, playlistID), &link); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("share link not found")
+		}
+		return nil, fmt.Errorf("error revoking share link: %v", err)
+	}
+
+	return &link, nil
+}
+
+// UseShareLink records a visit through a share link and returns the shared
+// playlist ID. Revoked and expired links are rejected and not counted.
+func (r *ShareLinkRepository) UseShareLink(linkID uint) (uint, error) {
+	now := time.Now()
+	query := `
+		UPDATE playlist_share_links
+		SET use_count = use_count + 1, last_used_at = $1
+		WHERE id = $2 AND revoked_at IS NULL AND expires_at > $1
+		RETURNING playlist_id
+	`
+
+	var playlistID uint
+	err := r.db.QueryRow(query, now, linkID).Scan(&playlistID)
+	if err == nil {
+		return playlistID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("error using share link: %v", err)
+	}
+
+	var revoked bool
+	err = r.db.QueryRow(`SELECT revoked_at IS NOT NULL FROM playlist_share_links WHERE id = $1`, linkID).Scan(&revoked)
+	switch {
+	case err == sql.ErrNoRows:
+		return 0, fmt.Errorf("share link not found")
+	case err != nil:
+		return 0, fmt.Errorf("error querying share link: %v", err)
+	case revoked:
+		return 0, fmt.Errorf("share link revoked")
+	default:
+		return 0, fmt.Errorf("share link expired")
+	}
+}
+
+// scanShareLink scans the columns listed in shareLinkColumns
+func scanShareLink(row rowScanner, link *models.ShareLink) error {
+	return row.Scan(
+		&link.ID,
+		&link.PlaylistID,
+		&link.Label,
+		&link.ExpiresAt,
+		&link.RevokedAt,
+		&link.UseCount,
+		&link.LastUsedAt,
+		&link.CreatedAt,
+	)
+}
@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"melodia/internal/database"
+	"melodia/internal/models"
+)
+
+const (
+	// maxSimilarityPlaylistSize leaves out playlists with more songs than this,
+	// which say little about songs going together and add pairs quadratically
+	maxSimilarityPlaylistSize = 500
+	// similarityLockID is the advisory lock keeping refreshes from running concurrently
+	similarityLockID = 7_044_001
+)
+
+// SimilarityRepository handles database operations for song similarities
+type SimilarityRepository struct {
+	db *sql.DB
+}
+
+// NewSimilarityRepository creates a new similarity repository
+func NewSimilarityRepository() *SimilarityRepository {
+	return &SimilarityRepository{
+		db: database.DB,
+	}
+}
+
+// RefreshSimilarities rebuilds song_similarities from the published playlists.
+// Readers keep seeing the previous similarities until the new ones are
+// committed. It returns without doing anything when another refresh is running.
+func (r *SimilarityRepository) RefreshSimilarities(ctx context.Context) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, similarityLockID).Scan(&locked); err != nil {
+		return fmt.Errorf("error locking similarities: %v", err)
+	}
+	if !locked {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM song_similarities`); err != nil {
+		return fmt.Errorf("error clearing similarities: %v", err)
+	}
+
+	query := `
+		WITH eligible AS (
+			SELECT ps.playlist_id
+			FROM playlist_songs ps
+			JOIN playlists p ON p.id = ps.playlist_id
+			WHERE p.is_published
+			GROUP BY ps.playlist_id
+			HAVING COUNT(*) BETWEEN 2 AND $1
+		),
+		entries AS (
+			SELECT DISTINCT ps.playlist_id, ps.song_id
+			FROM playlist_songs ps
+			JOIN eligible e ON e.playlist_id = ps.playlist_id
+		),
+		song_counts AS (
+			SELECT song_id, COUNT(*) AS playlists FROM entries GROUP BY song_id
+		),
+		total AS (
+			SELECT COUNT(*) AS playlists FROM eligible
+		),
+		pairs AS (
+			SELECT a.song_id, b.song_id AS related_song_id, COUNT(*) AS together
+			FROM entries a
+			JOIN entries b ON b.playlist_id = a.playlist_id AND b.song_id <> a.song_id
+			GROUP BY a.song_id, b.song_id
+		)
+		INSERT INTO song_similarities (song_id, related_song_id, co_occurrences, jaccard, pmi)
+		SELECT p.song_id, p.related_song_id, p.together,
+			p.together::float8 / (ca.playlists + cb.playlists - p.together),
+			ln(p.together::float8 * t.playlists / (ca.playlists * cb.playlists))
+		FROM pairs p
+		JOIN song_counts ca ON ca.song_id = p.song_id
+		JOIN song_counts cb ON cb.song_id = p.related_song_id
+		CROSS JOIN total t
+	`
+	if _, err := tx.ExecContext(ctx, query, maxSimilarityPlaylistSize); err != nil {
+		return fmt.Errorf("error computing similarities: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+// GetRelatedSongs retrieves up to limit songs related to a song, most similar
+// first by the given metric, leaving out pairs seen together in fewer than
+// minSupport playlists
+func (r *SimilarityRepository) GetRelatedSongs(songID uint, metric string, minSupport, limit int) ([]models.RelatedSong, error) {
+	// The metric picks between two fixed columns, never user input
+	order := "ss.jaccard"
+	if metric == models.SimilarityPMI {
+		order = "ss.pmi"
+	}
+
+	query := `
+		SELECT ` + songColumns + `, ss.co_occurrences, ss.jaccard, ss.pmi
+		FROM song_similarities ss
+		JOIN songs s ON s.id = ss.related_song_id
+		WHERE ss.song_id = $1 AND ss.co_occurrences >= $2
+		ORDER BY ` + order + ` DESC, ss.co_occurrences DESC, s.id
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(query, songID, minSupport, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying related songs: %v", err)
+	}
+	defer rows.Close()
+
+	related := []models.RelatedSong{}
+	for rows.Next() {
+		var song models.RelatedSong
+		if err := scanSong(rows, &song.Song, &song.CoOccurrences, &song.Jaccard, &song.PMI); err != nil {
+			return nil, fmt.Errorf("error scanning related song: %v", err)
+		}
+		related = append(related, song)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating related songs: %v", err)
+	}
+
+	return related, nil
+}
@@ -3,76 +3,141 @@ package repositories
 import (
 	"database/sql"
 	"fmt"
+	"log"
 	"melodia/internal/database"
 	"melodia/internal/models"
+	"melodia/internal/normalize"
+	"melodia/internal/storage"
 	"time"
+
+	"github.com/lib/pq"
 )
 
+// songColumns lists the columns read by scanSong, for queries aliasing songs as s
+const songColumns = `
+	s.id, s.title, s.artist, COALESCE(s.album, ''), COALESCE(s.track_number, 0),
+	COALESCE(s.year, 0), COALESCE(s.duration_ms, 0), COALESCE(s.genre, ''), COALESCE(s.audio_key, ''),
+	COALESCE(s.audio_hash, ''), COALESCE(s.audio_content_type, ''), COALESCE(s.audio_size, 0),
+	COALESCE(s.cover_key, ''), COALESCE(s.cover_content_type, ''), s.source_missing_at IS NOT NULL,
+	(SELECT COUNT(*) FROM song_likes l WHERE l.song_id = s.id), s.created_at, s.updated_at`
+
+// songKeyLockClass namespaces the advisory locks taken on normalized keys, so
+// songs with the same key can't be created concurrently
+const songKeyLockClass = 7_026_001
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 // SongRepository handles database operations for songs
 type SongRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	blobs storage.BlobStore
 }
 
 // NewSongRepository creates a new song repository
 func NewSongRepository() *SongRepository {
 	return &SongRepository{
-		db: database.DB,
+		db:    database.DB,
+		blobs: storage.Blobs,
 	}
 }
 
 // CreateSong creates a new song in the database
 func (r *SongRepository) CreateSong(song *models.Song) error {
-	query := `
-		INSERT INTO songs (title, artist, created_at, updated_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at, updated_at
-	`
-
-	now := time.Now()
-	err := r.db.QueryRow(query, song.Title, song.Artist, now, now).
-		Scan(&song.ID, &song.CreatedAt, &song.UpdatedAt)
+	return insertSong(r.db, song)
+}
 
+// CreateSongIfNew creates a song unless one with the same normalized key exists,
+// in which case that song is returned and nothing is created. The key is locked
+// until the insert commits, so concurrent requests can't both create it.
+func (r *SongRepository) CreateSongIfNew(song *models.Song) (*models.Song, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("error creating song: %v", err)
+		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
+	defer tx.Rollback()
 
-	return nil
+	key := normalize.SongKey(song.Title, song.Artist)
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, hashtext($2))`, songKeyLockClass, key); err != nil {
+		return nil, fmt.Errorf("error locking song key: %v", err)
+	}
+
+	var existing models.Song
+	query := `SELECT ` + songColumns + ` FROM songs s WHERE s.normalized_key = $1 ORDER BY s.id LIMIT 1`
+	err = scanSong(tx.QueryRow(query, key), &existing)
+	if err == nil {
+		return &existing, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error querying song: %v", err)
+	}
+
+	if err := insertSong(tx, song); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing song: %v", err)
+	}
+	return nil, nil
 }
 
 // GetSongs retrieves all songs from the database
 func (r *SongRepository) GetSongs() ([]models.Song, error) {
-	query := `SELECT id, title, artist, created_at, updated_at FROM songs ORDER BY created_at DESC`
+	var songs []models.Song
+	err := r.EachSong(func(song *models.Song) error {
+		songs = append(songs, *song)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return songs, nil
+}
+
+// EachSong calls fn for every song in the order of GetSongs as rows arrive from the
+// database, so callers can stream the catalog without holding it in memory. An error
+// returned by fn stops the iteration and is returned as is.
+func (r *SongRepository) EachSong(fn func(song *models.Song) error) error {
+	query := `SELECT ` + songColumns + ` FROM songs s ORDER BY s.created_at DESC`
 
 	rows, err := r.db.Query(query)
 	if err != nil {
-		return nil, fmt.Errorf("error querying songs: %v", err)
+		return fmt.Errorf("error querying songs: %v", err)
 	}
 	defer rows.Close()
 
-	var songs []models.Song
+	var song models.Song
 	for rows.Next() {
-		var song models.Song
-		err := rows.Scan(&song.ID, &song.Title, &song.Artist, &song.CreatedAt, &song.UpdatedAt)
-		if err != nil {
-			return nil, fmt.Errorf("error scanning song: %v", err)
+		song = models.Song{}
+		if err := scanSong(rows, &song); err != nil {
+			return fmt.Errorf("error scanning song: %v", err)
+		}
+		if err := fn(&song); err != nil {
+			return err
 		}
-		songs = append(songs, song)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating songs: %v", err)
+		return fmt.Errorf("error iterating songs: %v", err)
 	}
 
-	return songs, nil
+	return nil
 }
 
 // GetSongByID retrieves a song by its ID
 func (r *SongRepository) GetSongByID(id uint) (*models.Song, error) {
-	query := `SELECT id, title, artist, created_at, updated_at FROM songs WHERE id = $1`
+	query := `SELECT ` + songColumns + ` FROM songs s WHERE s.id = $1`
 
 	var song models.Song
-	err := r.db.QueryRow(query, id).
-		Scan(&song.ID, &song.Title, &song.Artist, &song.CreatedAt, &song.UpdatedAt)
+	err := scanSong(r.db.QueryRow(query, id), &song)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -88,13 +153,14 @@ func (r *SongRepository) GetSongByID(id uint) (*models.Song, error) {
 func (r *SongRepository) UpdateSong(song *models.Song) error {
 	query := `
 		UPDATE songs 
-		SET title = $1, artist = $2, updated_at = $3
-		WHERE id = $4
+		SET title = $1, artist = $2, normalized_key = $3, genre = $4, updated_at = $5
+		WHERE id = $6
 		RETURNING created_at, updated_at
 	`
 
 	now := time.Now()
-	err := r.db.QueryRow(query, song.Title, song.Artist, now, song.ID).
+	key := normalize.SongKey(song.Title, song.Artist)
+	err := r.db.QueryRow(query, song.Title, song.Artist, key, nullIfEmpty(song.Genre), now, song.ID).
 		Scan(&song.CreatedAt, &song.UpdatedAt)
 
 	if err != nil {
@@ -108,23 +174,703 @@ func (r *SongRepository) UpdateSong(song *models.Song) error {
 	return nil
 }
 
-// DeleteSong deletes a song from the database
+// DeleteSong deletes a song from the database along with stored files no other song uses
 func (r *SongRepository) DeleteSong(id uint) error {
-	query := `DELETE FROM songs WHERE id = $1`
+	query := `DELETE FROM songs WHERE id = $1 RETURNING COALESCE(audio_key, ''), COALESCE(cover_key, '')`
 
-	result, err := r.db.Exec(query, id)
+	var audioKey, coverKey string
+	err := r.db.QueryRow(query, id).Scan(&audioKey, &coverKey)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("song not found")
+		}
 		return fmt.Errorf("error deleting song: %v", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	r.DeleteUnreferencedBlobs(audioKey, coverKey)
+	return nil
+}
+
+// FindSongByNormalizedKey retrieves the oldest song matching a normalized title/artist key
+func (r *SongRepository) FindSongByNormalizedKey(key string) (*models.Song, error) {
+	query := `
+		SELECT ` + songColumns + `
+		FROM songs s
+		WHERE s.normalized_key = $1
+		ORDER BY s.id
+		LIMIT 1
+	`
+
+	var song models.Song
+	err := scanSong(r.db.QueryRow(query, key), &song)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("song not found")
+		}
+		return nil, fmt.Errorf("error querying song: %v", err)
+	}
+
+	return &song, nil
+}
+
+// GetDuplicateSongs retrieves groups of songs sharing the same normalized key.
+// Keys are backfilled when the server starts and kept current on every write.
+func (r *SongRepository) GetDuplicateSongs() ([]models.DuplicateSongGroup, error) {
+	query := `
+		SELECT ` + songColumns + `, s.normalized_key
+		FROM songs s
+		WHERE s.normalized_key IN (
+			SELECT normalized_key FROM songs
+			GROUP BY normalized_key
+			HAVING COUNT(*) > 1
+		)
+		ORDER BY s.normalized_key, s.id
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying duplicate songs: %v", err)
+	}
+	defer rows.Close()
+
+	groups := []models.DuplicateSongGroup{}
+	for rows.Next() {
+		var key string
+		var song models.Song
+		if err := scanSong(rows, &song, &key); err != nil {
+			return nil, fmt.Errorf("error scanning duplicate song: %v", err)
+		}
+
+		if len(groups) == 0 || groups[len(groups)-1].Key != key {
+			groups = append(groups, models.DuplicateSongGroup{Key: key})
+		}
+		last := &groups[len(groups)-1]
+		last.Songs = append(last.Songs, song)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating duplicate songs: %v", err)
+	}
+
+	return groups, nil
+}
+
+// MergeSongs folds the duplicate songs into the canonical one in a single transaction.
+// Playlist entries are re-pointed to the canonical song keeping the earliest added_at,
+// and the duplicates are deleted afterwards. Unless force is set, every duplicate
+// must have the normalized key of the canonical song, so a mistyped ID can't
+// fold an unrelated song away.
+func (r *SongRepository) MergeSongs(canonicalID uint, duplicateIDs []uint, force bool) (*models.MergeSongsResult, error) {
+	ids := make([]int64, 0, len(duplicateIDs))
+	for _, id := range duplicateIDs {
+		if id == canonicalID {
+			return nil, fmt.Errorf("cannot merge a song into itself")
+		}
+		ids = append(ids, int64(id))
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Lock every song involved so concurrent merges can't interleave
+	lockQuery := `SELECT id, normalized_key FROM songs WHERE id = $1 OR id = ANY($2) ORDER BY id FOR UPDATE`
+	rows, err := tx.Query(lockQuery, canonicalID, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("error locking songs: %v", err)
+	}
+	found := map[uint]sql.NullString{}
+	for rows.Next() {
+		var id uint
+		var key sql.NullString
+		if err := rows.Scan(&id, &key); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning song: %v", err)
+		}
+		found[id] = key
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating songs: %v", err)
+	}
+
+	canonicalKey, ok := found[canonicalID]
+	if !ok {
+		return nil, fmt.Errorf("song not found")
+	}
+	for _, id := range duplicateIDs {
+		key, ok := found[id]
+		if !ok {
+			return nil, fmt.Errorf("duplicate song not found")
+		}
+		if (!key.Valid || key != canonicalKey) && !force {
+			return nil, fmt.Errorf("songs are not duplicates")
+		}
+	}
+
+	// Re-point playlist entries, collapsing rows that would violate UNIQUE(playlist_id, song_id)
+	repointQuery := `
+		INSERT INTO playlist_songs (playlist_id, song_id, added_at)
+		SELECT playlist_id, $1, MIN(added_at)
+		FROM playlist_songs
+		WHERE song_id = ANY($2)
+		GROUP BY playlist_id
+		ON CONFLICT (playlist_id, song_id)
+		DO UPDATE SET added_at = LEAST(playlist_songs.added_at, EXCLUDED.added_at)
+	`
+	result, err := tx.Exec(repointQuery, canonicalID, pq.Array(ids))
 	if err != nil {
-		return fmt.Errorf("error getting rows affected: %v", err)
+		return nil, fmt.Errorf("error re-pointing playlist songs: %v", err)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("song not found")
+	playlistsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	// Queued duplicates are queued as the canonical song instead
+	if _, err := tx.Exec(`UPDATE play_queue_items SET song_id = $1 WHERE song_id = ANY($2)`, canonicalID, pq.Array(ids)); err != nil {
+		return nil, fmt.Errorf("error re-pointing queued songs: %v", err)
+	}
+
+	// Listeners who liked a duplicate like the canonical song instead, since
+	// their earliest like
+	likesQuery := `
+		INSERT INTO song_likes (listener_id, song_id, created_at)
+		SELECT listener_id, $1, MIN(created_at)
+		FROM song_likes
+		WHERE song_id = ANY($2)
+		GROUP BY listener_id
+		ON CONFLICT (listener_id, song_id)
+		DO UPDATE SET created_at = LEAST(song_likes.created_at, EXCLUDED.created_at)
+	`
+	if _, err := tx.Exec(likesQuery, canonicalID, pq.Array(ids)); err != nil {
+		return nil, fmt.Errorf("error re-pointing song likes: %v", err)
+	}
+
+	// Listening history of the duplicates counts for the canonical song. The
+	// title and artist copied into each play are kept as they were played.
+	if _, err := tx.Exec(`UPDATE plays SET song_id = $1 WHERE song_id = ANY($2)`, canonicalID, pq.Array(ids)); err != nil {
+		return nil, fmt.Errorf("error re-pointing plays: %v", err)
+	}
+
+	// Deleting the duplicates cascades to their old playlist_songs and song_likes rows
+	deleteQuery := `DELETE FROM songs WHERE id = ANY($1) RETURNING COALESCE(audio_key, ''), COALESCE(cover_key, '')`
+	rows, err = tx.Query(deleteQuery, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("error deleting duplicate songs: %v", err)
+	}
+	var deletedKeys [][2]string
+	for rows.Next() {
+		var keys [2]string
+		if err := rows.Scan(&keys[0], &keys[1]); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning deleted song: %v", err)
+		}
+		deletedKeys = append(deletedKeys, keys)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deleted songs: %v", err)
+	}
+
+	var song models.Song
+	updateQuery := `UPDATE songs s SET updated_at = $1 WHERE s.id = $2 RETURNING ` + songColumns
+	if err := scanSong(tx.QueryRow(updateQuery, time.Now(), canonicalID), &song); err != nil {
+		return nil, fmt.Errorf("error updating canonical song: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing merge: %v", err)
+	}
+
+	// Files of the duplicates may still be shared with the canonical song or others
+	for _, keys := range deletedKeys {
+		r.DeleteUnreferencedBlobs(keys[0], keys[1])
+	}
+
+	return &models.MergeSongsResult{
+		Song:              song,
+		MergedSongIDs:     duplicateIDs,
+		PlaylistsAffected: playlistsAffected,
+	}, nil
+}
+
+// BackfillNormalizedKeys computes the normalized key for songs created before it existed
+func (r *SongRepository) BackfillNormalizedKeys() error {
+	rows, err := r.db.Query(`SELECT id, title, artist FROM songs WHERE normalized_key IS NULL`)
+	if err != nil {
+		return fmt.Errorf("error querying songs without normalized key: %v", err)
+	}
+
+	var ids []int64
+	var keys []string
+	for rows.Next() {
+		var id int64
+		var title, artist string
+		if err := rows.Scan(&id, &title, &artist); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning song: %v", err)
+		}
+		ids = append(ids, id)
+		keys = append(keys, normalize.SongKey(title, artist))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating songs: %v", err)
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	updateQuery := `
+		UPDATE songs s
+		SET normalized_key = v.key
+		FROM unnest($1::int[], $2::text[]) AS v(id, key)
+		WHERE s.id = v.id
+	`
+	if _, err := r.db.Exec(updateQuery, pq.Array(ids), pq.Array(keys)); err != nil {
+		return fmt.Errorf("error updating normalized keys: %v", err)
 	}
 
 	return nil
 }
+
+// FindSongByAudioHash retrieves the song whose audio file has the given content hash
+func (r *SongRepository) FindSongByAudioHash(hash string) (*models.Song, error) {
+	query := `SELECT ` + songColumns + ` FROM songs s WHERE s.audio_hash = $1`
+
+	var song models.Song
+	err := scanSong(r.db.QueryRow(query, hash), &song)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("song not found")
+		}
+		return nil, fmt.Errorf("error querying song: %v", err)
+	}
+
+	return &song, nil
+}
+
+// AttachSongAudio stores the audio fields of a song, filling in tag metadata
+// (album, track number, year, duration, cover) only where the song has none yet
+func (r *SongRepository) AttachSongAudio(song *models.Song) error {
+	query := `
+		UPDATE songs s
+		SET audio_key = $1, audio_hash = $2, audio_content_type = $3, audio_size = $4,
+			album = COALESCE(s.album, $5),
+			track_number = COALESCE(s.track_number, $6),
+			year = COALESCE(s.year, $7),
+			duration_ms = COALESCE($8, s.duration_ms),
+			cover_key = COALESCE(s.cover_key, $9),
+			cover_content_type = COALESCE(s.cover_content_type, $10),
+			updated_at = $11
+		WHERE s.id = $12
+		RETURNING ` + songColumns
+
+	err := scanSong(r.db.QueryRow(query,
+		song.AudioKey,
+		song.AudioHash,
+		song.AudioContentType,
+		song.AudioSize,
+		nullIfEmpty(song.Album),
+		nullIfZero(int64(song.TrackNumber)),
+		nullIfZero(int64(song.Year)),
+		nullIfZero(song.DurationMs),
+		nullIfEmpty(song.CoverKey),
+		nullIfEmpty(song.CoverContentType),
+		time.Now(),
+		song.ID,
+	), song)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("song not found")
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("song audio already exists")
+		}
+		return fmt.Errorf("error attaching song audio: %v", err)
+	}
+
+	return nil
+}
+
+// SongBatch runs bulk song operations within a single transaction, one statement
+// per call regardless of the number of songs
+type SongBatch struct {
+	repo *SongRepository
+	tx   *sql.Tx
+	// deletedKeys holds the blob keys of deleted songs, cleaned up after commit
+	deletedKeys [][2]string
+}
+
+// BeginBatch starts a bulk song operation. The caller must Commit or Rollback it.
+func (r *SongRepository) BeginBatch() (*SongBatch, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	return &SongBatch{repo: r, tx: tx}, nil
+}
+
+// InsertSongs inserts the songs whose normalized key is not in the catalog yet,
+// counting songs inserted earlier in the same batch. Inserted songs get their ID
+// set; for every other song the returned slice holds the ID of the song it duplicates.
+func (b *SongBatch) InsertSongs(songs []*models.Song) ([]uint, error) {
+	keys := make([]string, len(songs))
+	for i, song := range songs {
+		keys[i] = normalize.SongKey(song.Title, song.Artist)
+	}
+
+	// Lock the keys like CreateSongIfNew does, in a fixed order so batches
+	// sharing keys can't deadlock
+	lockQuery := `SELECT pg_advisory_xact_lock($1, h) FROM (SELECT DISTINCT hashtext(k) AS h FROM unnest($2::text[]) AS k ORDER BY h) keys`
+	if _, err := b.tx.Exec(lockQuery, songKeyLockClass, pq.Array(keys)); err != nil {
+		return nil, fmt.Errorf("error locking song keys: %v", err)
+	}
+
+	existing := make(map[string]uint)
+	rows, err := b.tx.Query(`
+		SELECT normalized_key, MIN(id)
+		FROM songs
+		WHERE normalized_key = ANY($1)
+		GROUP BY normalized_key
+	`, pq.Array(keys))
+	if err != nil {
+		return nil, fmt.Errorf("error checking existing songs: %v", err)
+	}
+	for rows.Next() {
+		var key string
+		var id uint
+		if err := rows.Scan(&key, &id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning existing song: %v", err)
+		}
+		existing[key] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating existing songs: %v", err)
+	}
+
+	duplicateOf := make([]uint, len(songs))
+	firstInBatch := make(map[string]int)
+	var titles, artists, newKeys, albums, genres []string
+	var trackNumbers, years, durations []int64
+	for i, key := range keys {
+		if id, found := existing[key]; found {
+			duplicateOf[i] = id
+			continue
+		}
+		if _, found := firstInBatch[key]; found {
+			continue
+		}
+		firstInBatch[key] = i
+
+		song := songs[i]
+		titles = append(titles, song.Title)
+		artists = append(artists, song.Artist)
+		newKeys = append(newKeys, key)
+		albums = append(albums, song.Album)
+		genres = append(genres, song.Genre)
+		trackNumbers = append(trackNumbers, int64(song.TrackNumber))
+		years = append(years, int64(song.Year))
+		durations = append(durations, song.DurationMs)
+	}
+
+	if len(newKeys) > 0 {
+		query := `
+			INSERT INTO songs (title, artist, normalized_key, album, track_number, year, duration_ms,
+				genre, created_at, updated_at)
+			SELECT t.title, t.artist, t.key, NULLIF(t.album, ''), NULLIF(t.track_number, 0),
+				NULLIF(t.year, 0), NULLIF(t.duration_ms, 0), NULLIF(t.genre, ''), $9::timestamptz, $9::timestamptz
+			FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::int[], $6::int[], $7::bigint[], $8::text[])
+				AS t(title, artist, key, album, track_number, year, duration_ms, genre)
+			RETURNING id, normalized_key, created_at, updated_at
+		`
+
+		rows, err := b.tx.Query(query,
+			pq.Array(titles),
+			pq.Array(artists),
+			pq.Array(newKeys),
+			pq.Array(albums),
+			pq.Array(trackNumbers),
+			pq.Array(years),
+			pq.Array(durations),
+			pq.Array(genres),
+			time.Now(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error inserting songs: %v", err)
+		}
+		for rows.Next() {
+			var key string
+			var id uint
+			var createdAt, updatedAt time.Time
+			if err := rows.Scan(&id, &key, &createdAt, &updatedAt); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("error scanning inserted song: %v", err)
+			}
+			song := songs[firstInBatch[key]]
+			song.ID, song.CreatedAt, song.UpdatedAt = id, createdAt, updatedAt
+			applySongURLs(song)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating inserted songs: %v", err)
+		}
+	}
+
+	// Later repeats of a song new to this batch duplicate the row just inserted
+	for i, key := range keys {
+		if duplicateOf[i] == 0 && firstInBatch[key] != i {
+			duplicateOf[i] = songs[firstInBatch[key]].ID
+		}
+	}
+
+	return duplicateOf, nil
+}
+
+// UpdateSongs sets the title, artist and genre of the given songs, keeping the
+// current genre of the songs whose keepGenre entry is set. The returned slice
+// tells for each song whether it existed; updated songs are reloaded in place.
+func (b *SongBatch) UpdateSongs(songs []*models.Song, keepGenre []bool) ([]bool, error) {
+	ids := make([]int64, len(songs))
+	titles := make([]string, len(songs))
+	artists := make([]string, len(songs))
+	keys := make([]string, len(songs))
+	genres := make([]sql.NullString, len(songs))
+	for i, song := range songs {
+		ids[i] = int64(song.ID)
+		titles[i] = song.Title
+		artists[i] = song.Artist
+		keys[i] = normalize.SongKey(song.Title, song.Artist)
+		if !keepGenre[i] {
+			genres[i] = sql.NullString{String: song.Genre, Valid: true}
+		}
+	}
+
+	query := `
+		WITH updated AS (
+			UPDATE songs s
+			SET title = t.title, artist = t.artist, normalized_key = t.key,
+				genre = CASE WHEN t.genre IS NULL THEN s.genre ELSE NULLIF(t.genre, '') END,
+				updated_at = $6::timestamptz
+			FROM unnest($1::int[], $2::text[], $3::text[], $4::text[], $5::text[]) AS t(id, title, artist, key, genre)
+			WHERE s.id = t.id
+			RETURNING s.*
+		)
+		SELECT ` + songColumns + `
+		FROM updated s
+	`
+
+	rows, err := b.tx.Query(query, pq.Array(ids), pq.Array(titles), pq.Array(artists), pq.Array(keys),
+		pq.Array(genres), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error updating songs: %v", err)
+	}
+	defer rows.Close()
+
+	updated := make(map[uint]models.Song, len(songs))
+	for rows.Next() {
+		var song models.Song
+		if err := scanSong(rows, &song); err != nil {
+			return nil, fmt.Errorf("error scanning updated song: %v", err)
+		}
+		updated[song.ID] = song
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating updated songs: %v", err)
+	}
+
+	found := make([]bool, len(songs))
+	for i, song := range songs {
+		if row, ok := updated[song.ID]; ok {
+			*song = row
+			found[i] = true
+		}
+	}
+	return found, nil
+}
+
+// DeleteSongs deletes the songs with the given IDs and reports for each ID whether
+// it existed. Stored files no other song uses are removed once the batch commits.
+func (b *SongBatch) DeleteSongs(ids []uint) ([]bool, error) {
+	values := make([]int64, len(ids))
+	for i, id := range ids {
+		values[i] = int64(id)
+	}
+
+	query := `
+		DELETE FROM songs
+		WHERE id = ANY($1)
+		RETURNING id, COALESCE(audio_key, ''), COALESCE(cover_key, '')
+	`
+
+	rows, err := b.tx.Query(query, pq.Array(values))
+	if err != nil {
+		return nil, fmt.Errorf("error deleting songs: %v", err)
+	}
+	defer rows.Close()
+
+	deleted := make(map[uint]bool, len(ids))
+	for rows.Next() {
+		var id uint
+		var audioKey, coverKey string
+		if err := rows.Scan(&id, &audioKey, &coverKey); err != nil {
+			return nil, fmt.Errorf("error scanning deleted song: %v", err)
+		}
+		deleted[id] = true
+		b.deletedKeys = append(b.deletedKeys, [2]string{audioKey, coverKey})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deleted songs: %v", err)
+	}
+
+	found := make([]bool, len(ids))
+	for i, id := range ids {
+		found[i] = deleted[id]
+	}
+	return found, nil
+}
+
+// Commit makes the changes of the batch permanent
+func (b *SongBatch) Commit() error {
+	if err := b.tx.Commit(); err != nil {
+		return fmt.Errorf("error committing batch: %v", err)
+	}
+
+	for _, keys := range b.deletedKeys {
+		b.repo.DeleteUnreferencedBlobs(keys[0], keys[1])
+	}
+	return nil
+}
+
+// Rollback discards every change of the batch
+func (b *SongBatch) Rollback() error {
+	return b.tx.Rollback()
+}
+
+// insertSong inserts a song row using db or a transaction
+func insertSong(q querier, song *models.Song) error {
+	query := `
+		INSERT INTO songs (title, artist, normalized_key, album, track_number, year, duration_ms,
+			genre, audio_key, audio_hash, audio_content_type, audio_size, cover_key, cover_content_type,
+			created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		RETURNING id, created_at, updated_at
+	`
+
+	now := time.Now()
+	key := normalize.SongKey(song.Title, song.Artist)
+	err := q.QueryRow(query,
+		song.Title,
+		song.Artist,
+		key,
+		nullIfEmpty(song.Album),
+		nullIfZero(int64(song.TrackNumber)),
+		nullIfZero(int64(song.Year)),
+		nullIfZero(song.DurationMs),
+		nullIfEmpty(song.Genre),
+		nullIfEmpty(song.AudioKey),
+		nullIfEmpty(song.AudioHash),
+		nullIfEmpty(song.AudioContentType),
+		nullIfZero(song.AudioSize),
+		nullIfEmpty(song.CoverKey),
+		nullIfEmpty(song.CoverContentType),
+		now,
+		now,
+	).Scan(&song.ID, &song.CreatedAt, &song.UpdatedAt)
+
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("song audio already exists")
+		}
+		return fmt.Errorf("error creating song: %v", err)
+	}
+
+	applySongURLs(song)
+	return nil
+}
+
+// DeleteUnreferencedBlobs removes stored files once no song points to them anymore,
+// either after deleting songs or when an upload does not end up in a song.
+// Covers are content addressed and may be shared by every song of an album.
+func (r *SongRepository) DeleteUnreferencedBlobs(audioKey, coverKey string) {
+	if r.blobs == nil {
+		return
+	}
+
+	for column, key := range map[string]string{"audio_key": audioKey, "cover_key": coverKey} {
+		if key == "" {
+			continue
+		}
+
+		var inUse bool
+		query := `SELECT EXISTS (SELECT 1 FROM songs WHERE ` + column + ` = $1)`
+		if err := r.db.QueryRow(query, key).Scan(&inUse); err != nil || inUse {
+			continue
+		}
+
+		if err := r.blobs.Delete(key); err != nil {
+			log.Printf("Failed to delete blob %s: %v", key, err)
+		}
+	}
+}
+
+// scanSong scans the columns listed in songColumns, followed by any extra destinations
+func scanSong(row rowScanner, song *models.Song, extra ...interface{}) error {
+	dest := []interface{}{
+		&song.ID,
+		&song.Title,
+		&song.Artist,
+		&song.Album,
+		&song.TrackNumber,
+		&song.Year,
+		&song.DurationMs,
+		&song.Genre,
+		&song.AudioKey,
+		&song.AudioHash,
+		&song.AudioContentType,
+		&song.AudioSize,
+		&song.CoverKey,
+		&song.CoverContentType,
+		&song.FileMissing,
+		&song.LikeCount,
+		&song.CreatedAt,
+		&song.UpdatedAt,
+	}
+
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	applySongURLs(song)
+	return nil
+}
+
+// applySongURLs sets the URLs derived from a song's stored files
+func applySongURLs(song *models.Song) {
+	song.HasAudio = song.AudioKey != ""
+	song.CoverURL = nil
+	if song.CoverKey != "" {
+		coverURL := fmt.Sprintf("/songs/%d/cover", song.ID)
+		song.CoverURL = &coverURL
+	}
+}
+
+// nullIfEmpty stores empty strings as NULL
+func nullIfEmpty(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// nullIfZero stores zero numbers as NULL
+func nullIfZero(value int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: value, Valid: value != 0}
+}
@@ -13,26 +13,122 @@ func SetupRoutes() *gin.Engine {
 	// Initialize controllers
 	songController := controllers.NewSongController()
 	playlistController := controllers.NewPlaylistController()
+	lyricsController := controllers.NewLyricsController()
+	streamController := controllers.NewStreamController()
+	interchangeController := controllers.NewPlaylistInterchangeController()
+	shareLinkController := controllers.NewShareLinkController()
+	playController := controllers.NewPlayController()
+	chartController := controllers.NewChartController()
+	queueController := controllers.NewQueueController()
+	recommendationController := controllers.NewRecommendationController()
+	radioController := controllers.NewRadioController()
+	listenBrainzController := controllers.NewListenBrainzController()
+	likeController := controllers.NewLikeController()
 
 	// Songs routes
 	songs := router.Group("/songs")
 	{
 		songs.POST("", songController.CreateSong)
 		songs.GET("", songController.GetSongs)
+		songs.GET("/duplicates", songController.GetDuplicateSongs)
+		songs.POST("/upload", songController.UploadSong)
+		songs.POST("/import", songController.ImportSongs)
+		songs.POST("/bulk", songController.BulkCreateSongs)
+		songs.PATCH("/bulk", songController.BulkUpdateSongs)
+		songs.DELETE("/bulk", songController.BulkDeleteSongs)
 		songs.GET("/:id", songController.GetSong)
 		songs.PUT("/:id", songController.UpdateSong)
 		songs.DELETE("/:id", songController.DeleteSong)
+		songs.POST("/:id/merge", songController.MergeSongs)
+		songs.GET("/:id/related", recommendationController.GetRelatedSongs)
+		songs.GET("/:id/cover", songController.GetSongCover)
+		songs.POST("/:id/stream-url", streamController.CreateStreamURL)
+		songs.GET("/:id/stream", streamController.StreamSong)
+		songs.HEAD("/:id/stream", streamController.StreamSong)
+		songs.GET("/:id/lyrics", lyricsController.GetLyrics)
+		songs.PUT("/:id/lyrics", lyricsController.UpdateLyrics)
+		songs.DELETE("/:id/lyrics", lyricsController.DeleteLyrics)
 	}
 
+	// Lyrics routes
+	router.GET("/lyrics/search", lyricsController.SearchLyrics)
+
 	// Playlists routes
 	playlists := router.Group("/playlists")
 	{
 		playlists.POST("", playlistController.CreatePlaylist)
 		playlists.GET("", playlistController.GetPlaylists)
+		playlists.POST("/import", interchangeController.ImportPlaylist)
+		playlists.POST("/compose", playlistController.ComposePlaylist)
 		playlists.GET("/:id", playlistController.GetPlaylist)
 		playlists.DELETE("/:id", playlistController.DeletePlaylist)
 		playlists.POST("/:id/songs", playlistController.AddSongToPlaylist)
 		playlists.POST("/:id/publish", playlistController.PublishPlaylist)
+		playlists.POST("/:id/duplicate", playlistController.DuplicatePlaylist)
+		playlists.POST("/:id/shuffle", playlistController.ShufflePlaylist)
+		playlists.DELETE("/:id/shuffle", playlistController.DeletePlaylistShuffle)
+		playlists.GET("/:id/forks", playlistController.GetPlaylistForks)
+		playlists.GET("/:id/stats", playlistController.GetPlaylistStats)
+		playlists.GET("/:id/export", interchangeController.ExportPlaylist)
+		playlists.PUT("/:id/cover", playlistController.UploadPlaylistCover)
+		playlists.GET("/:id/cover", playlistController.GetPlaylistCover)
+		playlists.DELETE("/:id/cover", playlistController.DeletePlaylistCover)
+		playlists.POST("/:id/share-links", shareLinkController.CreateShareLink)
+		playlists.GET("/:id/share-links", shareLinkController.GetShareLinks)
+		playlists.DELETE("/:id/share-links/:linkId", shareLinkController.RevokeShareLink)
+	}
+
+	// Shared playlists routes
+	router.GET("/shared/:token", shareLinkController.GetSharedPlaylist)
+
+	// Plays routes
+	router.POST("/plays", playController.CreatePlay)
+	router.GET("/plays", playController.GetPlays)
+
+	// Likes of the requesting listener
+	me := router.Group("/me")
+	{
+		me.GET("/likes/songs", likeController.GetLikedSongs)
+		me.PUT("/likes/songs/:id", likeController.LikeSong)
+		me.DELETE("/likes/songs/:id", likeController.UnlikeSong)
+		me.PUT("/likes/playlists/:id", likeController.LikePlaylist)
+		me.DELETE("/likes/playlists/:id", likeController.UnlikePlaylist)
+	}
+
+	// ListenBrainz compatible routes, at the paths scrobbling clients expect
+	router.POST("/1/submit-listens", listenBrainzController.SubmitListens)
+	router.GET("/1/validate-token", listenBrainzController.ValidateToken)
+	router.POST("/listenbrainz/tokens", listenBrainzController.CreateListenToken)
+	router.DELETE("/listenbrainz/tokens/:id", listenBrainzController.DeleteListenToken)
+
+	// Charts routes
+	charts := router.Group("/charts")
+	{
+		charts.GET("/songs", chartController.GetSongChart)
+		charts.GET("/artists", chartController.GetArtistChart)
+		charts.GET("/playlists", chartController.GetPlaylistChart)
+	}
+
+	// Queue routes
+	queue := router.Group("/queue")
+	{
+		queue.GET("", queueController.GetQueue)
+		queue.PATCH("", queueController.UpdateQueueModes)
+		queue.DELETE("", queueController.ClearQueue)
+		queue.POST("/items", queueController.Enqueue)
+		queue.DELETE("/items/:itemId", queueController.RemoveQueueItem)
+		queue.PUT("/items/:itemId/index", queueController.MoveQueueItem)
+		queue.PUT("/current", queueController.SetQueueCurrent)
+		queue.POST("/next", queueController.NextInQueue)
+		queue.POST("/previous", queueController.PreviousInQueue)
+	}
+
+	// Radio routes
+	radio := router.Group("/radio")
+	{
+		radio.POST("", radioController.CreateStation)
+		radio.GET("/:id", radioController.GetStation)
+		radio.GET("/:id/next", radioController.NextSongs)
 	}
 
 	return router
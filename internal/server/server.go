@@ -1,18 +1,30 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"melodia/internal/database"
+	"melodia/internal/jobs"
+	"melodia/internal/repositories"
 	"melodia/internal/router"
+	"melodia/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+const (
+	// chartRefreshInterval is how often chart rollups pick up new plays
+	chartRefreshInterval = 5 * time.Minute
+	// similarityRefreshInterval is how often related songs are recomputed from playlists
+	similarityRefreshInterval = time.Hour
+)
+
 // Start initializes and starts the server
 func Start() {
 	// Initialize database
@@ -25,6 +37,22 @@ func Start() {
 		log.Fatalf("Failed to create database tables: %v", err)
 	}
 
+	// Initialize blob storage for uploaded files
+	if err := storage.InitStorage(); err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	// Compute duplicate detection keys for songs created before they existed
+	if err := repositories.NewSongRepository().BackfillNormalizedKeys(); err != nil {
+		log.Fatalf("Failed to backfill song keys: %v", err)
+	}
+
+	// Keep chart rollups up to date with new plays
+	go jobs.Every(context.Background(), "chart rollups", chartRefreshInterval, repositories.NewChartRepository().RefreshRollups)
+
+	// Recompute related songs from published playlists
+	go jobs.Every(context.Background(), "song similarities", similarityRefreshInterval, repositories.NewSimilarityRepository().RefreshSimilarities)
+
 	// Load environment variables
 	host := os.Getenv("HOST")
 	if host == "" {
@@ -0,0 +1,131 @@
+// Package shuffle orders songs at random while keeping the songs of each artist
+// as far apart as possible. The order depends only on the songs and a seed, so
+// every device shuffling the same playlist with the same seed agrees.
+package shuffle
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxSeedLength is the longest seed accepted, in bytes
+const MaxSeedLength = 64
+
+// NewSeed returns a random seed
+func NewSeed() string {
+	return strconv.FormatUint(rand.Uint64(), 36)
+}
+
+// Spread returns the order in which to play songs by the given artists, as
+// indexes into artists. Artists are merged one at a time from the one with the
+// fewest songs to the one with the most, each spreading its shuffled songs
+// evenly over the songs merged before, starting at a random offset. Songs by
+// one artist only end up next to each other when the artist has more songs
+// than all others together plus one. Artists are compared case-insensitively.
+func Spread(artists []string, seed string) []int {
+	rng := newSource(seed)
+
+	// Group songs by artist in order of first appearance, so the walk through
+	// the generator does not depend on map iteration order
+	groups := [][]int{}
+	groupOf := map[string]int{}
+	for i, artist := range artists {
+		key := strings.ToLower(strings.TrimSpace(artist))
+		g, ok := groupOf[key]
+		if !ok {
+			g = len(groups)
+			groupOf[key] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	// Artists with the same number of songs are merged in random order
+	rng.shuffle(len(groups), func(i, j int) { groups[i], groups[j] = groups[j], groups[i] })
+	sort.SliceStable(groups, func(a, b int) bool {
+		return len(groups[a]) < len(groups[b])
+	})
+
+	order := make([]int, 0, len(artists))
+	for _, group := range groups {
+		rng.shuffle(len(group), func(i, j int) { group[i], group[j] = group[j], group[i] })
+		order = merge(order, group, rng.float())
+	}
+	return order
+}
+
+// merge spreads the songs of group evenly over order. The n songs of order sit
+// at positions (i+1)/(n+1) and the k songs of group at (offset+j)/k, so a
+// single song lands in each of the n+1 gaps with the same chance, and the songs
+// of group are never next to each other when k <= n+1.
+func merge(order, group []int, offset float64) []int {
+	n, k := len(order), len(group)
+	merged := make([]int, 0, n+k)
+	i, j := 0, 0
+	for i < n || j < k {
+		if j == k || (i < n && float64(i+1)/float64(n+1) < (offset+float64(j))/float64(k)) {
+			merged = append(merged, order[i])
+			i++
+		} else {
+			merged = append(merged, group[j])
+			j++
+		}
+	}
+	return merged
+}
+
+// source draws numbers straight from PCG, whose output for a seed is fixed,
+// rather than through rand.Rand, whose algorithms may change between Go releases
+// and with them every order already shared between devices
+type source struct {
+	pcg *rand.PCG
+}
+
+// newSource returns a generator fully determined by seed
+func newSource(seed string) source {
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	sum := h.Sum64()
+	return source{rand.NewPCG(sum, sum^0x9E3779B97F4A7C15)}
+}
+
+// shuffle is a Fisher-Yates shuffle of n elements
+func (s source) shuffle(n int, swap func(i, j int)) {
+	for i := n - 1; i > 0; i-- {
+		swap(i, int(s.pcg.Uint64()%uint64(i+1)))
+	}
+}
+
+// float returns a number in [0, 1)
+func (s source) float() float64 {
+	return float64(s.pcg.Uint64()>>11) / (1 << 53)
+}
+
+// Restore returns the order of a stored shuffle for the songs a playlist holds
+// now, as indexes into songIDs: songs in the order they were shuffled in,
+// followed by songs added since in their playlist order. Songs removed since
+// are left out.
+func Restore(songIDs, stored []uint) []int {
+	index := make(map[uint]int, len(songIDs))
+	for i, id := range songIDs {
+		index[id] = i
+	}
+
+	order := make([]int, 0, len(songIDs))
+	placed := make([]bool, len(songIDs))
+	for _, id := range stored {
+		if i, ok := index[id]; ok && !placed[i] {
+			order = append(order, i)
+			placed[i] = true
+		}
+	}
+	for i := range songIDs {
+		if !placed[i] {
+			order = append(order, i)
+		}
+	}
+	return order
+}
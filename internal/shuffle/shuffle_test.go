@@ -0,0 +1,156 @@
+package shuffle
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// playlist returns the artists of a playlist with songs songs by each artist,
+// grouped by artist as playlists often are
+func playlist(songs int, artists ...string) []string {
+	var result []string
+	for _, artist := range artists {
+		for i := 0; i < songs; i++ {
+			result = append(result, artist)
+		}
+	}
+	return result
+}
+
+// adjacent counts the neighbouring songs by the same artist in an order
+func adjacent(artists []string, order []int) int {
+	count := 0
+	for i := 1; i < len(order); i++ {
+		if artists[order[i]] == artists[order[i-1]] {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSpreadIsPermutation(t *testing.T) {
+	artists := []string{"A", "B", "a ", "C", "B", "A", "D"}
+	order := Spread(artists, "seed")
+
+	sorted := append([]int{}, order...)
+	sort.Ints(sorted)
+	if !reflect.DeepEqual(sorted, []int{0, 1, 2, 3, 4, 5, 6}) {
+		t.Errorf("Expected a permutation of every song, got %v", order)
+	}
+
+	if len(Spread(nil, "seed")) != 0 {
+		t.Error("Expected an empty order for an empty playlist")
+	}
+}
+
+func TestSpreadIsDeterministic(t *testing.T) {
+	artists := playlist(5, "A", "B", "C", "D")
+
+	if first, second := Spread(artists, "device"), Spread(artists, "device"); !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the same order for the same seed, got %v and %v", first, second)
+	}
+
+	// Pinned so a change in the algorithm, which would reorder every stored shuffle, is noticed
+	expected := []int{4, 8, 19, 12, 9, 2, 13, 18, 7, 3, 11, 17, 14, 0, 5, 16, 10, 1, 6, 15}
+	if got := Spread(artists, "pinned"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected pinned order %v, got %v", expected, got)
+	}
+
+	distinct := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		distinct[strconv.Itoa(Spread(artists, strconv.Itoa(i))[0])] = true
+	}
+	if len(distinct) < 10 {
+		t.Errorf("Expected different seeds to start with different songs, got %d distinct first songs", len(distinct))
+	}
+}
+
+func TestSpreadKeepsArtistsApart(t *testing.T) {
+	artists := playlist(10, "A", "B", "C")
+	naive := rand.New(rand.NewPCG(1, 2))
+
+	spreadAdjacent, naiveAdjacent := 0, 0
+	for i := 0; i < 1000; i++ {
+		spreadAdjacent += adjacent(artists, Spread(artists, strconv.Itoa(i)))
+		naiveAdjacent += adjacent(artists, naive.Perm(len(artists)))
+	}
+
+	// A uniform shuffle puts about 9 same-artist pairs side by side in these
+	// 30 songs; spreading should all but remove them
+	if naiveAdjacent < 8000 {
+		t.Fatalf("Expected a uniform shuffle to pair about 9000 songs, got %d", naiveAdjacent)
+	}
+	if spreadAdjacent > naiveAdjacent/20 {
+		t.Errorf("Expected far fewer same-artist neighbours than %d, got %d", naiveAdjacent, spreadAdjacent)
+	}
+}
+
+func TestSpreadSpacesDominantArtist(t *testing.T) {
+	// Half the songs by one artist: the only way to keep them apart is to alternate
+	artists := append(playlist(6, "A"), "B", "C", "D", "E", "F", "G")
+
+	worst := 0
+	for i := 0; i < 1000; i++ {
+		order := Spread(artists, strconv.Itoa(i))
+		run := 0
+		for _, index := range order {
+			if artists[index] == "A" {
+				run++
+				if run > worst {
+					worst = run
+				}
+			} else {
+				run = 0
+			}
+		}
+	}
+	if worst > 1 {
+		t.Errorf("Expected the dominant artist never to play twice in a row, got %d", worst)
+	}
+}
+
+func TestSpreadIsUniformPerPosition(t *testing.T) {
+	// With every song by a different artist spreading is a plain shuffle, so
+	// every song should start the order about as often
+	artists := []string{"A", "B", "C", "D", "E", "F"}
+	const runs = 12000
+	counts := make([]int, len(artists))
+	for i := 0; i < runs; i++ {
+		counts[Spread(artists, strconv.Itoa(i))[0]]++
+	}
+	assertUniform(t, counts, runs)
+
+	// Songs of the same artist are shuffled among themselves too
+	artists = playlist(3, "A", "B")
+	counts = make([]int, len(artists))
+	for i := 0; i < runs; i++ {
+		counts[Spread(artists, strconv.Itoa(i))[0]]++
+	}
+	assertUniform(t, counts, runs)
+}
+
+// assertUniform runs a chi-squared test on how often each song came first
+func assertUniform(t *testing.T, counts []int, runs int) {
+	t.Helper()
+	expected := float64(runs) / float64(len(counts))
+	chi := 0.0
+	for _, count := range counts {
+		d := float64(count) - expected
+		chi += d * d / expected
+	}
+	// 99.9th percentile of chi-squared with 5 degrees of freedom
+	if chi > 20.52 {
+		t.Errorf("Expected every song to come first about %.0f times, got %v (chi-squared %.1f)", expected, counts, chi)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	// Song 5 was removed and songs 8 and 9 were added since the shuffle
+	order := Restore([]uint{9, 8, 1, 2, 3}, []uint{3, 5, 1, 2})
+	if !reflect.DeepEqual(order, []int{4, 2, 3, 0, 1}) {
+		t.Errorf("Expected shuffled songs first and new ones after, got %v", order)
+	}
+}
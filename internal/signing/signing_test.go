@@ -0,0 +1,65 @@
+package signing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	expires := time.Now().Add(time.Minute)
+	signature := Sign("/songs/1/stream", expires)
+
+	if err := Verify("/songs/1/stream", expires.Unix(), signature); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+
+	if err := Verify("/songs/2/stream", expires.Unix(), signature); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature for another resource, got %v", err)
+	}
+
+	if err := Verify("/songs/1/stream", expires.Unix()+60, signature); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature for a tampered expiry, got %v", err)
+	}
+
+	if err := Verify("/songs/1/stream", expires.Unix(), "not-base64!"); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature for garbage, got %v", err)
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	expires := time.Now().Add(-time.Minute)
+	signature := Sign("/songs/1/stream", expires)
+
+	if err := Verify("/songs/1/stream", expires.Unix(), signature); err != ErrExpired {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	token := NewToken("share-link", 42, time.Now().Add(time.Hour))
+
+	id, err := ParseToken("share-link", token)
+	if err != nil || id != 42 {
+		t.Fatalf("Expected id 42, got %d (%v)", id, err)
+	}
+
+	if _, err := ParseToken("radio", token); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature for another purpose, got %v", err)
+	}
+
+	tampered := "43" + token[2:]
+	if _, err := ParseToken("share-link", tampered); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature for a tampered id, got %v", err)
+	}
+
+	for _, garbage := range []string{"", "42", "42.x.sig", "x.1.sig", "1.2.3.4"} {
+		if _, err := ParseToken("share-link", garbage); err != ErrInvalidSignature {
+			t.Errorf("Expected ErrInvalidSignature for %q, got %v", garbage, err)
+		}
+	}
+
+	expired := NewToken("share-link", 42, time.Now().Add(-time.Minute))
+	if _, err := ParseToken("share-link", expired); err != ErrExpired {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+}
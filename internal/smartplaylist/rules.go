@@ -0,0 +1,297 @@
+// Package smartplaylist validates the rules of smart playlists and compiles them
+// into a SQL condition and ordering over songs
+package smartplaylist
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"melodia/internal/models"
+)
+
+// Comparison operators of a rule
+const (
+	OpEq            = "eq"
+	OpNeq           = "neq"
+	OpContains      = "contains"
+	OpNotContains   = "not_contains"
+	OpIn            = "in"
+	OpNotIn         = "not_in"
+	OpLt            = "lt"
+	OpLte           = "lte"
+	OpGt            = "gt"
+	OpGte           = "gte"
+	OpWithinDays    = "within_days"
+	OpOlderThanDays = "older_than_days"
+)
+
+const (
+	// MaxDepth is how deeply all and any can be nested
+	MaxDepth = 5
+	// MaxConditions is how many field conditions the rules can hold
+	MaxConditions = 50
+	// MaxValues is how many values an in or not_in condition can list
+	MaxValues = 100
+	// MaxLimit is the most songs a smart playlist can hold
+	MaxLimit = 1000
+	// MaxDays is the most days within_days and older_than_days can look back,
+	// keeping the date they compare with in range
+	MaxDays = 36500
+	// DefaultSort is the order of the songs when the rules give none
+	DefaultSort = "-created_at"
+)
+
+type fieldKind int
+
+const (
+	textField fieldKind = iota
+	numberField
+	dateField
+	boolField
+)
+
+type field struct {
+	kind   fieldKind
+	column string
+}
+
+// fields maps the fields rules can compare to the song column they read. Missing
+// text and numbers compare as empty and zero.
+var fields = map[string]field{
+	"title":        {textField, "s.title"},
+	"artist":       {textField, "s.artist"},
+	"album":        {textField, "COALESCE(s.album, '')"},
+	"genre":        {textField, "COALESCE(s.genre, '')"},
+	"year":         {numberField, "COALESCE(s.year, 0)"},
+	"duration_ms":  {numberField, "COALESCE(s.duration_ms, 0)"},
+	"track_number": {numberField, "COALESCE(s.track_number, 0)"},
+	"created_at":   {dateField, "s.created_at"},
+	"has_audio":    {boolField, "(s.audio_key IS NOT NULL)"},
+}
+
+// ops lists the operators each kind of field accepts
+var ops = map[fieldKind][]string{
+	textField:   {OpEq, OpNeq, OpContains, OpNotContains, OpIn, OpNotIn},
+	numberField: {OpEq, OpNeq, OpLt, OpLte, OpGt, OpGte},
+	dateField:   {OpWithinDays, OpOlderThanDays},
+	boolField:   {OpEq},
+}
+
+var numberOperators = map[string]string{
+	OpEq:  "=",
+	OpNeq: "<>",
+	OpLt:  "<",
+	OpLte: "<=",
+	OpGt:  ">",
+	OpGte: ">=",
+}
+
+// sorts maps each sort key to the column it orders by
+var sorts = map[string]string{
+	"created_at":  "s.created_at",
+	"title":       "lower(s.title)",
+	"artist":      "lower(s.artist)",
+	"year":        "COALESCE(s.year, 0)",
+	"duration_ms": "COALESCE(s.duration_ms, 0)",
+}
+
+// Validate checks that rules are well formed and small enough to run
+func Validate(rules *models.SmartPlaylistRules) error {
+	_, _, _, err := Compile(rules, nil)
+	return err
+}
+
+// Compile returns the condition songs must meet and the ORDER BY list of rules,
+// both over the songs table aliased s, appending the condition parameters to args
+func Compile(rules *models.SmartPlaylistRules, args []interface{}) (string, string, []interface{}, error) {
+	if rules.Limit < 0 || rules.Limit > MaxLimit {
+		return "", "", nil, fmt.Errorf("limit must be between 1 and %d, or 0 for %d", MaxLimit, MaxLimit)
+	}
+
+	order, err := compileSort(rules.Sort)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	c := compiler{args: args}
+	where, err := c.compile(&rules.Match, 1)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return where, order, c.args, nil
+}
+
+// Limit returns how many songs rules select at most
+func Limit(rules *models.SmartPlaylistRules) int {
+	if rules.Limit == 0 {
+		return MaxLimit
+	}
+	return rules.Limit
+}
+
+func compileSort(sort string) (string, error) {
+	if sort == "" {
+		sort = DefaultSort
+	}
+	key, direction := sort, "ASC"
+	if strings.HasPrefix(sort, "-") {
+		key, direction = sort[1:], "DESC"
+	}
+	column, ok := sorts[key]
+	if !ok {
+		return "", fmt.Errorf("unknown sort %q, expected created_at, title, artist, year or duration_ms, optionally prefixed with -", sort)
+	}
+	return column + " " + direction + ", s.id " + direction, nil
+}
+
+type compiler struct {
+	args       []interface{}
+	conditions int
+}
+
+func (c *compiler) compile(rule *models.SmartRule, depth int) (string, error) {
+	if depth > MaxDepth {
+		return "", fmt.Errorf("rules cannot be nested more than %d levels deep", MaxDepth)
+	}
+
+	groups := 0
+	if rule.All != nil {
+		groups++
+	}
+	if rule.Any != nil {
+		groups++
+	}
+	if rule.Field != "" {
+		groups++
+	}
+	if groups != 1 {
+		return "", fmt.Errorf("each rule must have exactly one of all, any or field")
+	}
+
+	switch {
+	case rule.All != nil:
+		return c.compileGroup(rule.All, " AND ", depth)
+	case rule.Any != nil:
+		return c.compileGroup(rule.Any, " OR ", depth)
+	}
+	return c.compileCondition(rule)
+}
+
+func (c *compiler) compileGroup(rules []models.SmartRule, joiner string, depth int) (string, error) {
+	if len(rules) == 0 {
+		return "", fmt.Errorf("all and any need at least one rule")
+	}
+	parts := make([]string, len(rules))
+	for i := range rules {
+		part, err := c.compile(&rules[i], depth+1)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+	return "(" + strings.Join(parts, joiner) + ")", nil
+}
+
+func (c *compiler) compileCondition(rule *models.SmartRule) (string, error) {
+	c.conditions++
+	if c.conditions > MaxConditions {
+		return "", fmt.Errorf("rules cannot hold more than %d conditions", MaxConditions)
+	}
+
+	f, ok := fields[rule.Field]
+	if !ok {
+		return "", fmt.Errorf("unknown field %q", rule.Field)
+	}
+	if !accepts(f.kind, rule.Op) {
+		return "", fmt.Errorf("field %s does not support op %q, expected one of %s", rule.Field, rule.Op, strings.Join(ops[f.kind], ", "))
+	}
+	if len(rule.Value) == 0 || bytes.Equal(rule.Value, []byte("null")) {
+		return "", fmt.Errorf("field %s needs a value", rule.Field)
+	}
+
+	switch f.kind {
+	case textField:
+		return c.compileText(rule, f.column)
+	case numberField:
+		var n int64
+		if err := json.Unmarshal(rule.Value, &n); err != nil {
+			return "", fmt.Errorf("field %s needs a whole number", rule.Field)
+		}
+		return fmt.Sprintf("%s %s %s", f.column, numberOperators[rule.Op], c.arg(n)), nil
+	case dateField:
+		var days int
+		if err := json.Unmarshal(rule.Value, &days); err != nil || days < 1 || days > MaxDays {
+			return "", fmt.Errorf("field %s needs a positive number of days up to %d", rule.Field, MaxDays)
+		}
+		operator := ">="
+		if rule.Op == OpOlderThanDays {
+			operator = "<"
+		}
+		return fmt.Sprintf("%s %s now() - %s * interval '1 day'", f.column, operator, c.arg(days)), nil
+	default:
+		var b bool
+		if err := json.Unmarshal(rule.Value, &b); err != nil {
+			return "", fmt.Errorf("field %s needs true or false", rule.Field)
+		}
+		return fmt.Sprintf("%s = %s", f.column, c.arg(b)), nil
+	}
+}
+
+// compileText compares text case-insensitively
+func (c *compiler) compileText(rule *models.SmartRule, column string) (string, error) {
+	column = "lower(" + column + ")"
+
+	if rule.Op == OpIn || rule.Op == OpNotIn {
+		var values []string
+		if err := json.Unmarshal(rule.Value, &values); err != nil || len(values) == 0 {
+			return "", fmt.Errorf("field %s needs a list of strings for %s", rule.Field, rule.Op)
+		}
+		if len(values) > MaxValues {
+			return "", fmt.Errorf("%s cannot list more than %d values", rule.Op, MaxValues)
+		}
+		params := make([]string, len(values))
+		for i, value := range values {
+			params[i] = c.arg(strings.ToLower(value))
+		}
+		operator := "IN"
+		if rule.Op == OpNotIn {
+			operator = "NOT IN"
+		}
+		return fmt.Sprintf("%s %s (%s)", column, operator, strings.Join(params, ", ")), nil
+	}
+
+	var value string
+	if err := json.Unmarshal(rule.Value, &value); err != nil {
+		return "", fmt.Errorf("field %s needs a string", rule.Field)
+	}
+	value = strings.ToLower(value)
+
+	switch rule.Op {
+	case OpEq:
+		return fmt.Sprintf("%s = %s", column, c.arg(value)), nil
+	case OpNeq:
+		return fmt.Sprintf("%s <> %s", column, c.arg(value)), nil
+	case OpContains:
+		return fmt.Sprintf("strpos(%s, %s) > 0", column, c.arg(value)), nil
+	default:
+		return fmt.Sprintf("strpos(%s, %s) = 0", column, c.arg(value)), nil
+	}
+}
+
+// arg appends a parameter and returns its placeholder
+func (c *compiler) arg(value interface{}) string {
+	c.args = append(c.args, value)
+	return "$" + strconv.Itoa(len(c.args))
+}
+
+func accepts(kind fieldKind, op string) bool {
+	for _, candidate := range ops[kind] {
+		if candidate == op {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package smartplaylist
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"melodia/internal/models"
+)
+
+func parse(t *testing.T, raw string) *models.SmartPlaylistRules {
+	t.Helper()
+	var rules models.SmartPlaylistRules
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		t.Fatalf("Invalid test rules: %v", err)
+	}
+	return &rules
+}
+
+func TestCompile(t *testing.T) {
+	rules := parse(t, `{
+		"match": {"all": [
+			{"any": [
+				{"field": "artist", "op": "in", "value": ["Queen", "ABBA"]},
+				{"field": "genre", "op": "eq", "value": "Rock"}
+			]},
+			{"field": "created_at", "op": "within_days", "value": 30},
+			{"field": "duration_ms", "op": "lt", "value": 240000}
+		]},
+		"sort": "title",
+		"limit": 25
+	}`)
+
+	where, order, args, err := Compile(rules, []interface{}{"existing"})
+	if err != nil {
+		t.Fatalf("Expected valid rules, got %v", err)
+	}
+
+	expected := "((lower(s.artist) IN ($2, $3) OR lower(COALESCE(s.genre, '')) = $4) AND " +
+		"s.created_at >= now() - $5 * interval '1 day' AND " +
+		"COALESCE(s.duration_ms, 0) < $6)"
+	if where != expected {
+		t.Errorf("Unexpected condition:\n%s", where)
+	}
+	if order != "lower(s.title) ASC, s.id ASC" {
+		t.Errorf("Unexpected order %q", order)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"existing", "queen", "abba", "rock", 30, int64(240000)}) {
+		t.Errorf("Unexpected args %v", args)
+	}
+	if got := Limit(rules); got != 25 {
+		t.Errorf("Expected limit 25, got %d", got)
+	}
+}
+
+func TestCompileDefaults(t *testing.T) {
+	rules := parse(t, `{"match": {"field": "has_audio", "op": "eq", "value": true}}`)
+
+	where, order, args, err := Compile(rules, nil)
+	if err != nil {
+		t.Fatalf("Expected valid rules, got %v", err)
+	}
+	if where != "(s.audio_key IS NOT NULL) = $1" {
+		t.Errorf("Unexpected condition %q", where)
+	}
+	if order != "s.created_at DESC, s.id DESC" {
+		t.Errorf("Unexpected order %q", order)
+	}
+	if !reflect.DeepEqual(args, []interface{}{true}) {
+		t.Errorf("Unexpected args %v", args)
+	}
+	if got := Limit(rules); got != MaxLimit {
+		t.Errorf("Expected limit %d, got %d", MaxLimit, got)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	deep := `{"field": "title", "op": "eq", "value": "a"}`
+	for i := 0; i < MaxDepth; i++ {
+		deep = `{"all": [` + deep + `]}`
+	}
+
+	many := make([]string, MaxConditions+1)
+	for i := range many {
+		many[i] = `{"field": "year", "op": "gt", "value": 1990}`
+	}
+
+	tests := []struct {
+		name     string
+		rules    string
+		contains string
+	}{
+		{"unknown field", `{"match": {"field": "mood", "op": "eq", "value": "x"}}`, "unknown field"},
+		{"wrong op for field", `{"match": {"field": "year", "op": "contains", "value": 1}}`, "does not support op"},
+		{"missing value", `{"match": {"field": "title", "op": "eq"}}`, "needs a value"},
+		{"text value not string", `{"match": {"field": "title", "op": "eq", "value": 3}}`, "needs a string"},
+		{"in without list", `{"match": {"field": "artist", "op": "in", "value": "Queen"}}`, "needs a list"},
+		{"fractional number", `{"match": {"field": "year", "op": "eq", "value": 1.5}}`, "whole number"},
+		{"non positive days", `{"match": {"field": "created_at", "op": "within_days", "value": 0}}`, "positive number of days"},
+		{"too many days", `{"match": {"field": "created_at", "op": "older_than_days", "value": 100000000}}`, "positive number of days"},
+		{"empty group", `{"match": {"any": []}}`, "at least one rule"},
+		{"field and group", `{"match": {"all": [], "field": "title", "op": "eq", "value": "a"}}`, "exactly one"},
+		{"empty rule", `{"match": {}}`, "exactly one"},
+		{"too deep", `{"match": ` + deep + `}`, "nested"},
+		{"too many conditions", `{"match": {"all": [` + strings.Join(many, ",") + `]}}`, "conditions"},
+		{"unknown sort", `{"match": {"field": "title", "op": "eq", "value": "a"}, "sort": "plays"}`, "unknown sort"},
+		{"limit too large", `{"match": {"field": "title", "op": "eq", "value": "a"}, "limit": 5000}`, "limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(parse(t, tt.rules))
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("Expected error containing %q, got %q", tt.contains, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package songcsv
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Song fields that can be read from a CSV column
+const (
+	FieldTitle       = "title"
+	FieldArtist      = "artist"
+	FieldAlbum       = "album"
+	FieldTrackNumber = "track_number"
+	FieldYear        = "year"
+	FieldDurationMs  = "duration_ms"
+)
+
+// Fields lists every mappable field, required ones first
+var Fields = []string{FieldTitle, FieldArtist, FieldAlbum, FieldTrackNumber, FieldYear, FieldDurationMs}
+
+// headerAliases are the column names recognized for each field when no explicit
+// mapping is given. Matching ignores case and surrounding spaces.
+var headerAliases = map[string][]string{
+	FieldTitle:       {"title", "name", "song", "song title", "track title", "track name"},
+	FieldArtist:      {"artist", "artist name", "performer", "artists"},
+	FieldAlbum:       {"album", "album name", "album title", "release"},
+	FieldTrackNumber: {"track_number", "track number", "track no", "track", "tracknumber", "#"},
+	FieldYear:        {"year", "release year"},
+	FieldDurationMs:  {"duration_ms", "duration", "length"},
+}
+
+// Row is a song read from a CSV record. Err is set when the record cannot be
+// turned into a song; the other fields then hold whatever could be read.
+type Row struct {
+	// Line is the line of the record in the file, counting the header as line 1
+	Line        int
+	Title       string
+	Artist      string
+	Album       string
+	TrackNumber int
+	Year        int
+	DurationMs  int64
+	Err         error
+}
+
+// Reader streams songs from a CSV file with a header row
+type Reader struct {
+	csv     *csv.Reader
+	columns map[string]int
+}
+
+// NewReader reads the header of a CSV file and resolves the column of each field.
+// mapping overrides the column name used for a field; delimiter 0 means a comma.
+// A UTF-8 byte order mark at the start of the file is skipped.
+func NewReader(r io.Reader, mapping map[string]string, delimiter rune) (*Reader, error) {
+	buffered := bufio.NewReader(r)
+	if bom, err := buffered.Peek(3); err == nil && string(bom) == "\xef\xbb\xbf" {
+		buffered.Discard(3)
+	}
+
+	reader := csv.NewReader(buffered)
+	if delimiter != 0 {
+		reader.Comma = delimiter
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("file is empty")
+		}
+		return nil, fmt.Errorf("invalid header: %v", err)
+	}
+
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if _, exists := positions[key]; !exists {
+			positions[key] = i
+		}
+	}
+
+	columns := make(map[string]int, len(Fields))
+	for field, column := range mapping {
+		if _, known := headerAliases[field]; !known {
+			return nil, fmt.Errorf("unknown field %q in column mapping", field)
+		}
+		i, found := positions[strings.ToLower(strings.TrimSpace(column))]
+		if !found {
+			return nil, fmt.Errorf("column %q mapped to %s not found in header", column, field)
+		}
+		columns[field] = i
+	}
+	for _, field := range Fields {
+		if _, mapped := columns[field]; mapped {
+			continue
+		}
+		for _, alias := range headerAliases[field] {
+			if i, found := positions[alias]; found {
+				columns[field] = i
+				break
+			}
+		}
+	}
+
+	for _, field := range []string{FieldTitle, FieldArtist} {
+		if _, found := columns[field]; !found {
+			return nil, fmt.Errorf("no column found for required field %s", field)
+		}
+	}
+
+	return &Reader{csv: reader, columns: columns}, nil
+}
+
+// Next returns the next row, or io.EOF at the end of the file. Malformed records
+// are returned as rows with Err set so reading can continue; any other error
+// means the input itself failed and reading must stop.
+func (r *Reader) Next() (*Row, error) {
+	record, err := r.csv.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return &Row{Line: parseErr.StartLine, Err: fmt.Errorf("malformed CSV record: %v", parseErr.Err)}, nil
+		}
+		return nil, err
+	}
+
+	line, _ := r.csv.FieldPos(0)
+	row := &Row{Line: line}
+
+	for _, value := range record {
+		if !utf8.ValidString(value) {
+			row.Err = fmt.Errorf("record is not valid UTF-8")
+			return row, nil
+		}
+	}
+
+	row.Title = r.value(record, FieldTitle)
+	row.Artist = r.value(record, FieldArtist)
+	row.Album = r.value(record, FieldAlbum)
+
+	if value := r.value(record, FieldTrackNumber); value != "" {
+		// Spreadsheets often export "3/12" for track 3 of 12
+		value, _, _ = strings.Cut(value, "/")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 1 {
+			row.Err = fmt.Errorf("track_number must be a positive integer")
+			return row, nil
+		}
+		row.TrackNumber = n
+	}
+
+	if value := r.value(record, FieldYear); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1000 || n > 9999 {
+			row.Err = fmt.Errorf("year must be a four digit number")
+			return row, nil
+		}
+		row.Year = n
+	}
+
+	if value := r.value(record, FieldDurationMs); value != "" {
+		ms, err := ParseDuration(value)
+		if err != nil {
+			row.Err = err
+			return row, nil
+		}
+		row.DurationMs = ms
+	}
+
+	return row, nil
+}
+
+// value returns the trimmed cell of a field, empty when the field is not mapped
+// or the record is shorter than the header
+func (r *Reader) value(record []string, field string) string {
+	i, mapped := r.columns[field]
+	if !mapped || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// ParseDuration parses a duration given either in milliseconds or as m:ss / h:mm:ss
+func ParseDuration(value string) (int64, error) {
+	if !strings.Contains(value, ":") {
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || ms < 0 {
+			return 0, fmt.Errorf("duration must be milliseconds or m:ss")
+		}
+		return ms, nil
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("duration must be milliseconds or m:ss")
+	}
+
+	var seconds int64
+	for i, part := range parts {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || n < 0 || (i > 0 && (n > 59 || len(part) != 2)) {
+			return 0, fmt.Errorf("duration must be milliseconds or m:ss")
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds * 1000, nil
+}
@@ -0,0 +1,132 @@
+package songcsv
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, r *Reader) []*Row {
+	t.Helper()
+
+	var rows []*Row
+	for {
+		row, err := r.Next()
+		if err == io.EOF {
+			return rows
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestReaderWithBOMAndAliases(t *testing.T) {
+	data := "\xef\xbb\xbfName, Artist ,Album,Track,Year,Length\n" +
+		"Bohemian Rhapsody,Queen,A Night at the Opera,11/12,1975,5:55\n" +
+		"\"Hello, Goodbye\",The Beatles,,,,\n"
+
+	r, err := NewReader(strings.NewReader(data), nil, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	rows := readAll(t, r)
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(rows))
+	}
+
+	first := rows[0]
+	if first.Err != nil {
+		t.Fatalf("Expected valid row, got %v", first.Err)
+	}
+	if first.Line != 2 || first.Title != "Bohemian Rhapsody" || first.Artist != "Queen" || first.Album != "A Night at the Opera" {
+		t.Errorf("Unexpected row: %+v", first)
+	}
+	if first.TrackNumber != 11 || first.Year != 1975 || first.DurationMs != 355000 {
+		t.Errorf("Unexpected numeric fields: %+v", first)
+	}
+
+	if rows[1].Title != "Hello, Goodbye" || rows[1].Line != 3 {
+		t.Errorf("Expected quoted title on line 3, got %+v", rows[1])
+	}
+}
+
+func TestReaderColumnMapping(t *testing.T) {
+	data := "Tema;Intérprete\nDe Música Ligera;Soda Stereo\n"
+
+	r, err := NewReader(strings.NewReader(data), map[string]string{"title": "tema", "artist": "Intérprete"}, ';')
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	rows := readAll(t, r)
+	if len(rows) != 1 || rows[0].Title != "De Música Ligera" || rows[0].Artist != "Soda Stereo" {
+		t.Errorf("Unexpected rows: %+v", rows)
+	}
+}
+
+func TestReaderHeaderErrors(t *testing.T) {
+	tests := map[string]struct {
+		data    string
+		mapping map[string]string
+	}{
+		"empty file":      {"", nil},
+		"missing artist":  {"title,album\n", nil},
+		"unknown field":   {"title,artist\n", map[string]string{"genre": "title"}},
+		"unmapped column": {"title,artist\n", map[string]string{"title": "name"}},
+	}
+
+	for name, tt := range tests {
+		if _, err := NewReader(strings.NewReader(tt.data), tt.mapping, 0); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestReaderInvalidRows(t *testing.T) {
+	data := "title,artist,year,duration_ms\n" +
+		"Song,Artist,19x5,\n" +
+		"Song,Artist,,-4\n" +
+		"Song,Artist\xff,,\n" +
+		"Short row\n"
+
+	r, err := NewReader(strings.NewReader(data), nil, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	rows := readAll(t, r)
+	if len(rows) != 4 {
+		t.Fatalf("Expected 4 rows, got %d", len(rows))
+	}
+	for i, row := range rows[:3] {
+		if row.Err == nil {
+			t.Errorf("Row %d: expected error", i)
+		}
+	}
+	if rows[3].Err != nil || rows[3].Title != "Short row" || rows[3].Artist != "" {
+		t.Errorf("Expected short row to parse with empty artist, got %+v", rows[3])
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]int64{
+		"215000":  215000,
+		"3:35":    215000,
+		"1:02:03": 3723000,
+	}
+	for input, expected := range tests {
+		got, err := ParseDuration(input)
+		if err != nil || got != expected {
+			t.Errorf("ParseDuration(%q) = %d, %v; expected %d", input, got, err, expected)
+		}
+	}
+
+	for _, input := range []string{"3:5", "3:75", "abc", "1:2:3:4"} {
+		if _, err := ParseDuration(input); err == nil {
+			t.Errorf("ParseDuration(%q): expected error", input)
+		}
+	}
+}
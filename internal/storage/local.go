@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalBlobStore stores blobs as files under a root directory
+type LocalBlobStore struct {
+	root string
+}
+
+// NewLocalBlobStore creates a local filesystem blob store rooted at root
+func NewLocalBlobStore(root string) (*LocalBlobStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating storage directory: %v", err)
+	}
+
+	return &LocalBlobStore{root: root}, nil
+}
+
+// Put writes the blob to a temporary file and renames it into place,
+// so readers never observe partially written content
+func (s *LocalBlobStore) Put(key string, r io.Reader) (int64, error) {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		return 0, fmt.Errorf("error creating blob directory: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".upload-*")
+	if err != nil {
+		return 0, fmt.Errorf("error creating blob: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("error writing blob: %v", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("error writing blob: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), fullPath); err != nil {
+		return 0, fmt.Errorf("error storing blob: %v", err)
+	}
+
+	return written, nil
+}
+
+// Open opens the blob file for reading
+func (s *LocalBlobStore) Open(key string) (io.ReadSeekCloser, BlobInfo, error) {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, BlobInfo{}, ErrNotFound
+		}
+		return nil, BlobInfo{}, fmt.Errorf("error opening blob: %v", err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, BlobInfo{}, fmt.Errorf("error reading blob info: %v", err)
+	}
+
+	return file, BlobInfo{Key: key, Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+// Stat returns the size and modification time of the blob file
+func (s *LocalBlobStore) Stat(key string) (BlobInfo, error) {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+
+	stat, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return BlobInfo{}, ErrNotFound
+		}
+		return BlobInfo{}, fmt.Errorf("error reading blob info: %v", err)
+	}
+
+	return BlobInfo{Key: key, Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+// Delete removes the blob file
+func (s *LocalBlobStore) Delete(key string) error {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error deleting blob: %v", err)
+	}
+
+	return nil
+}
+
+// DeletePrefix removes the directory holding every blob under prefix.
+// Prefixes are expected to end at a key segment boundary, e.g. "playlists/3/".
+func (s *LocalBlobStore) DeletePrefix(prefix string) error {
+	fullPath, err := s.path(strings.TrimSuffix(prefix, "/"))
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(fullPath); err != nil {
+		return fmt.Errorf("error deleting blobs: %v", err)
+	}
+
+	return nil
+}
+
+// path maps a key to a file under the root, rejecting keys that escape it
+func (s *LocalBlobStore) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" || cleaned != "/"+key {
+		return "", fmt.Errorf("invalid blob key: %q", key)
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
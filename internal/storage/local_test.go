@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	store, err := NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	written, err := store.Put("playlists/1/cover/original.png", strings.NewReader("image"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if written != 5 {
+		t.Errorf("Expected 5 bytes written, got %d", written)
+	}
+
+	reader, info, err := store.Open("playlists/1/cover/original.png")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+
+	if string(content) != "image" || info.Size != 5 {
+		t.Errorf("Expected stored content to round trip, got %q (%d bytes)", content, info.Size)
+	}
+
+	if err := store.DeletePrefix("playlists/1/"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := store.Stat("playlists/1/cover/original.png"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestLocalBlobStoreRejectsEscapingKeys(t *testing.T) {
+	store, _ := NewLocalBlobStore(t.TempDir())
+
+	for _, key := range []string{"../outside", "a/../../b", "", "/absolute"} {
+		if _, err := store.Put(key, strings.NewReader("x")); err == nil {
+			t.Errorf("Expected error for key %q", key)
+		}
+	}
+}
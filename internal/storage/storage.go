@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// ErrNotFound is returned when a blob does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// BlobInfo describes a stored blob
+type BlobInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore stores opaque binary objects addressed by slash separated keys
+type BlobStore interface {
+	// Put stores the content read from r under key, replacing any previous blob
+	Put(key string, r io.Reader) (int64, error)
+	// Open returns a seekable reader for the blob stored under key
+	Open(key string) (io.ReadSeekCloser, BlobInfo, error)
+	// Stat returns information about the blob stored under key
+	Stat(key string) (BlobInfo, error)
+	// Delete removes the blob stored under key, missing blobs are ignored
+	Delete(key string) error
+	// DeletePrefix removes every blob whose key starts with prefix
+	DeletePrefix(prefix string) error
+}
+
+var Blobs BlobStore
+
+// InitStorage initializes the blob store
+func InitStorage() error {
+	root := os.Getenv("STORAGE_PATH")
+	if root == "" {
+		root = "./data/blobs"
+	}
+
+	store, err := NewLocalBlobStore(root)
+	if err != nil {
+		return fmt.Errorf("error initializing blob storage: %v", err)
+	}
+
+	Blobs = store
+	log.Printf("Blob storage initialized at %s", root)
+	return nil
+}
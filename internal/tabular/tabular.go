@@ -0,0 +1,139 @@
+// Package tabular negotiates and writes row-oriented response formats, so list
+// endpoints can stream large results as CSV or newline-delimited JSON
+package tabular
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FormatJSON is the regular JSON envelope response
+	FormatJSON = "json"
+	// FormatCSV writes a header row followed by one record per row
+	FormatCSV = "csv"
+	// FormatNDJSON writes one JSON object per line
+	FormatNDJSON = "ndjson"
+)
+
+// ContentTypes maps each format to the Content-Type of its responses
+var ContentTypes = map[string]string{
+	FormatJSON:   "application/json; charset=utf-8",
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+// mediaTypes maps the media types accepted for each format, in order of preference
+var mediaTypes = []struct {
+	mediaType string
+	format    string
+}{
+	{"application/json", FormatJSON},
+	{"text/csv", FormatCSV},
+	{"application/x-ndjson", FormatNDJSON},
+	{"application/ndjson", FormatNDJSON},
+	{"application/jsonl", FormatNDJSON},
+}
+
+// Negotiate picks the format for an Accept header. The highest quality wins, an
+// exact media type beats a wildcard and JSON wins remaining ties. It returns ""
+// when the header accepts none of the formats; an empty header means JSON.
+func Negotiate(accept string) string {
+	if strings.TrimSpace(accept) == "" {
+		return FormatJSON
+	}
+
+	best := ""
+	bestQuality, bestSpecificity := 0.0, -1
+	for _, offer := range mediaTypes {
+		quality, specificity := match(accept, offer.mediaType)
+		if quality > bestQuality || (quality == bestQuality && quality > 0 && specificity > bestSpecificity) {
+			best, bestQuality, bestSpecificity = offer.format, quality, specificity
+		}
+	}
+	return best
+}
+
+// match returns the quality the Accept header gives to a media type, taken from
+// its most specific matching range, and how specific that range is
+func match(accept, mediaType string) (float64, int) {
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	quality, specificity := 0.0, -1
+
+	for _, part := range strings.Split(accept, ",") {
+		rangeType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		var rangeSpecificity int
+		switch {
+		case rangeType == mediaType:
+			rangeSpecificity = 2
+		case rangeType == mainType+"/*":
+			rangeSpecificity = 1
+		case rangeType == "*/*":
+			rangeSpecificity = 0
+		default:
+			continue
+		}
+		if rangeSpecificity < specificity {
+			continue
+		}
+
+		rangeQuality := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil && parsed >= 0 && parsed <= 1 {
+				rangeQuality = parsed
+			}
+		}
+		quality, specificity = rangeQuality, rangeSpecificity
+	}
+
+	return quality, specificity
+}
+
+// Encoder writes rows as CSV or NDJSON
+type Encoder struct {
+	format string
+	csv    *csv.Writer
+	json   *json.Encoder
+}
+
+// NewEncoder creates an encoder for FormatCSV or FormatNDJSON, writing the CSV
+// header right away
+func NewEncoder(w io.Writer, format string, header []string) (*Encoder, error) {
+	e := &Encoder{format: format}
+	if format == FormatCSV {
+		e.csv = csv.NewWriter(w)
+		if err := e.csv.Write(header); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+
+	e.json = json.NewEncoder(w)
+	e.json.SetEscapeHTML(false)
+	return e, nil
+}
+
+// Encode writes one row, as record for CSV or as the JSON encoding of value
+func (e *Encoder) Encode(value interface{}, record []string) error {
+	if e.csv != nil {
+		return e.csv.Write(record)
+	}
+	return e.json.Encode(value)
+}
+
+// Flush writes any buffered rows to the underlying writer
+func (e *Encoder) Flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+	return nil
+}
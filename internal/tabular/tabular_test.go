@@ -0,0 +1,66 @@
+package tabular
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"", FormatJSON},
+		{"*/*", FormatJSON},
+		{"application/json", FormatJSON},
+		{"text/csv", FormatCSV},
+		{"text/*", FormatCSV},
+		{"application/x-ndjson", FormatNDJSON},
+		{"application/ndjson", FormatNDJSON},
+		{"text/csv, */*", FormatCSV},
+		{"text/csv;q=0.5, application/json", FormatJSON},
+		{"application/json;q=0.2, application/x-ndjson;q=0.8", FormatNDJSON},
+		{"application/json;q=0, */*", FormatCSV},
+		{"text/html, image/png", ""},
+		{"application/*", FormatJSON},
+		{"not a media type, text/csv", FormatCSV},
+	}
+
+	for _, test := range tests {
+		if got := Negotiate(test.accept); got != test.expected {
+			t.Errorf("Negotiate(%q) = %q, expected %q", test.accept, got, test.expected)
+		}
+	}
+}
+
+func TestEncoderCSV(t *testing.T) {
+	var buf bytes.Buffer
+	encoder, err := NewEncoder(&buf, FormatCSV, []string{"id", "title"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := encoder.Encode(nil, []string{"1", `Say "Hello", World`}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := "id,title\n1,\"Say \"\"Hello\"\", World\"\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestEncoderNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	encoder, _ := NewEncoder(&buf, FormatNDJSON, nil)
+	encoder.Encode(map[string]string{"title": "Rock & Roll"}, nil)
+	encoder.Encode(map[string]int{"id": 2}, nil)
+	encoder.Flush()
+
+	expected := "{\"title\":\"Rock & Roll\"}\n{\"id\":2}\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}